@@ -7,6 +7,7 @@ package core
 import "C" //nolint
 
 import (
+	"context"
 	"unsafe"
 )
 
@@ -26,11 +27,20 @@ type AppDescriptor struct {
 
 // NewAdmin creates a new admin session.
 func NewAdmin(URL string, IDToken string) (*Admin, error) {
-	url := C.CString(URL)
-	token := C.CString(IDToken)
-	defer C.free(unsafe.Pointer(url))
-	defer C.free(unsafe.Pointer(token))
-	result, err := await(C.tanker_admin_connect(url, token))
+	return NewAdminContext(context.Background(), URL, IDToken)
+}
+
+// NewAdminContext is like NewAdmin but stops waiting when ctx is done.
+func NewAdminContext(ctx context.Context, URL string, IDToken string) (*Admin, error) {
+	args := &nativeArgs{}
+	defer args.release()
+	url := args.cString(URL)
+	token := args.secretCString(IDToken)
+	result, err := awaitContext(ctx, "NewAdmin", args, func() *C.tanker_future_t {
+		return C.tanker_admin_connect(url, token)
+	}, func(result unsafe.Pointer) {
+		_, _ = await(C.tanker_admin_destroy((*C.tanker_admin_t)(result)))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -43,9 +53,20 @@ func NewAdmin(URL string, IDToken string) (*Admin, error) {
 
 // NewApp creates a Tanker application on the Tanker server.
 func (adm Admin) NewApp(Name string) (*AppDescriptor, error) {
-	name := C.CString(Name)
-	defer C.free(unsafe.Pointer(name))
-	result, err := await(C.tanker_admin_create_app(adm.admin, name))
+	return adm.NewAppContext(context.Background(), Name)
+}
+
+// NewAppContext is like NewApp but stops waiting when ctx is done.
+// The application may still be created after ctx is done.
+func (adm Admin) NewAppContext(ctx context.Context, Name string) (*AppDescriptor, error) {
+	args := &nativeArgs{}
+	defer args.release()
+	name := args.cString(Name)
+	result, err := awaitContext(ctx, "NewApp", args, func() *C.tanker_future_t {
+		return C.tanker_admin_create_app(adm.admin, name)
+	}, func(result unsafe.Pointer) {
+		C.tanker_admin_app_descriptor_free((*C.tanker_app_descriptor_t)(result))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +84,18 @@ func (adm Admin) NewApp(Name string) (*AppDescriptor, error) {
 
 // DeleteApp destroys the application on the Tanker server.
 func (adm Admin) DeleteApp(AppID string) error {
-	appID := C.CString(AppID)
-	defer C.free(unsafe.Pointer(appID))
-	_, err := await(C.tanker_admin_delete_app(adm.admin, appID))
+	return adm.DeleteAppContext(context.Background(), AppID)
+}
+
+// DeleteAppContext is like DeleteApp but stops waiting when ctx is done.
+// The application may still be deleted after ctx is done.
+func (adm Admin) DeleteAppContext(ctx context.Context, AppID string) error {
+	args := &nativeArgs{}
+	defer args.release()
+	appID := args.cString(AppID)
+	_, err := awaitContext(ctx, "DeleteApp", args, func() *C.tanker_future_t {
+		return C.tanker_admin_delete_app(adm.admin, appID)
+	}, nil)
 	if err != nil {
 		return err
 	}
@@ -74,15 +104,20 @@ func (adm Admin) DeleteApp(AppID string) error {
 
 // Update updates a Tanker application's settings.
 func (adm Admin) Update(AppID string, OidcClientId string, OidcProvider string) error {
-	appID := C.CString(AppID)
-	oidcClientId := C.CString(OidcClientId)
-	oidcProvider := C.CString(OidcProvider)
-	defer func() {
-		C.free(unsafe.Pointer(appID))
-		C.free(unsafe.Pointer(oidcClientId))
-		C.free(unsafe.Pointer(oidcProvider))
-	}()
-	_, err := await(C.tanker_admin_app_update(adm.admin, appID, oidcClientId, oidcProvider))
+	return adm.UpdateContext(context.Background(), AppID, OidcClientId, OidcProvider)
+}
+
+// UpdateContext is like Update but stops waiting when ctx is done.
+// The application may still be updated after ctx is done.
+func (adm Admin) UpdateContext(ctx context.Context, AppID string, OidcClientId string, OidcProvider string) error {
+	args := &nativeArgs{}
+	defer args.release()
+	appID := args.cString(AppID)
+	oidcClientId := args.cString(OidcClientId)
+	oidcProvider := args.cString(OidcProvider)
+	_, err := awaitContext(ctx, "Update", args, func() *C.tanker_future_t {
+		return C.tanker_admin_app_update(adm.admin, appID, oidcClientId, oidcProvider)
+	}, nil)
 	return err
 }
 
@@ -95,19 +130,26 @@ func (adm Admin) Destroy() {
 // same as the one in the ProvisionalIdentity you want the verification code for. The Tanker application
 // must be a test application.
 func (app *AppDescriptor) GetVerificationCode(Url string, Email string) (*string, error) {
-	url := C.CString(Url)
-	appID := C.CString(app.ID)
-	authToken := C.CString(app.AuthToken)
-	email := C.CString(Email)
-	defer C.free(unsafe.Pointer(url))
-	defer C.free(unsafe.Pointer(appID))
-	defer C.free(unsafe.Pointer(authToken))
-	defer C.free(unsafe.Pointer(email))
-	result, err := await(C.tanker_get_verification_code(url, appID, authToken, email))
-	defer C.free(result)
+	return app.GetVerificationCodeContext(context.Background(), Url, Email)
+}
+
+// GetVerificationCodeContext is like GetVerificationCode but stops waiting when ctx is done.
+func (app *AppDescriptor) GetVerificationCodeContext(ctx context.Context, Url string, Email string) (*string, error) {
+	args := &nativeArgs{}
+	defer args.release()
+	url := args.cString(Url)
+	appID := args.cString(app.ID)
+	authToken := args.secretCString(app.AuthToken)
+	email := args.cString(Email)
+	result, err := awaitContext(ctx, "GetVerificationCode", args, func() *C.tanker_future_t {
+		return C.tanker_get_verification_code(url, appID, authToken, email)
+	}, func(result unsafe.Pointer) {
+		freeSecret((*C.char)(result))
+	})
 	if err != nil {
 		return nil, err
 	}
+	defer freeSecret((*C.char)(result))
 	code := C.GoString((*C.char)(result))
 	return &code, nil
 }
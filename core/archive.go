@@ -0,0 +1,318 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+)
+
+// Layout of an archive:
+//  magic (4 bytes) | version (1 byte) | encrypted entries | encrypted index |
+//  index offset (8 bytes, little endian) | index size (8 bytes, little endian) | magic (4 bytes)
+// The index is the JSON encoding of the entries, it is read first thanks to
+// the trailer so that entries can be decrypted without reading the others.
+const (
+	archiveVersion     = 1
+	archiveHeaderSize  = 4 + 1
+	archiveTrailerSize = 8 + 8 + 4
+)
+
+var archiveMagic = []byte("TKAR")
+
+// ArchiveOptions configures the encryption of the entries added with
+// ArchiveWriter.Add() and of the index of an archive.
+type ArchiveOptions struct {
+	// Session, if set, encrypts the entries and the index with the encryption
+	// session, EncryptionOptions is then ignored.
+	Session EncryptionSessionClient
+	// EncryptionOptions is used to encrypt each entry with its own key.
+	EncryptionOptions *EncryptionOptions
+}
+
+// ArchiveEntry describes an entry of an archive.
+type ArchiveEntry struct {
+	// Name identifies the entry, it must be unique within an archive.
+	Name        string            `json:"name"`
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	// ResourceID, Offset and Size are set by the ArchiveWriter, Offset and
+	// Size locate the encrypted data in the archive.
+	ResourceID string `json:"resource_id"`
+	Offset     int64  `json:"offset"`
+	Size       int64  `json:"size"`
+}
+
+type archiveIndex struct {
+	Entries []ArchiveEntry `json:"entries"`
+}
+
+// ArchiveWriter writes an archive of encrypted entries and their metadata. The
+// metadata is stored in an encrypted index, written by Close(). An ArchiveWriter
+// must not be used concurrently, and fails for good once a write has failed.
+//
+//	archive := core.NewArchiveWriter(tanker, file, nil)
+//	err := archive.Add(core.ArchiveEntry{Name: "scan.png", ContentType: "image/png"}, scan)
+//	...
+//	err = archive.Close()
+type ArchiveWriter struct {
+	tanker  Client
+	dst     io.Writer
+	options ArchiveOptions
+
+	offset  int64
+	entries []ArchiveEntry
+	names   map[string]bool
+	err     error
+	closed  bool
+}
+
+// NewArchiveWriter creates an ArchiveWriter writing to dst. Nil options encrypt
+// each entry with default EncryptionOptions.
+func NewArchiveWriter(t Client, dst io.Writer, options *ArchiveOptions) *ArchiveWriter {
+	w := &ArchiveWriter{tanker: t, dst: dst, names: map[string]bool{}}
+	if options != nil {
+		w.options = *options
+	}
+	return w
+}
+
+func (w *ArchiveWriter) encrypt(ctx context.Context, reader io.Reader) (Stream, error) {
+	if w.options.Session != nil {
+		return w.options.Session.StreamEncryptContext(ctx, reader)
+	}
+	return w.tanker.StreamEncryptContext(ctx, reader, w.options.EncryptionOptions)
+}
+
+// write writes data to dst, and makes its failure final.
+func (w *ArchiveWriter) write(src io.Reader) (int64, error) {
+	if w.offset == 0 {
+		header := append(append([]byte{}, archiveMagic...), archiveVersion)
+		n, err := w.dst.Write(header)
+		w.offset += int64(n)
+		if err != nil {
+			w.err = err
+			return 0, err
+		}
+	}
+	n, err := io.Copy(w.dst, src)
+	w.offset += n
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// check fails if the entry cannot be added.
+func (w *ArchiveWriter) check(operation string, entry ArchiveEntry) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return newError(ErrorPreconditionFailed, operation, "the archive has been closed")
+	}
+	if entry.Name == "" || w.names[entry.Name] {
+		return newError(ErrorInvalidArgument, operation, "entry names must be unique and not empty")
+	}
+	return nil
+}
+
+func (w *ArchiveWriter) addEntry(entry ArchiveEntry, resourceID string, encryptedData io.Reader) error {
+	entry.ResourceID = resourceID
+	entry.Offset = w.offset
+	if entry.Offset == 0 {
+		entry.Offset = archiveHeaderSize
+	}
+	size, err := w.write(encryptedData)
+	if err != nil {
+		return err
+	}
+	entry.Size = size
+	w.names[entry.Name] = true
+	w.entries = append(w.entries, entry)
+	return nil
+}
+
+// Add encrypts the data read from clearData according to the ArchiveOptions,
+// and adds it to the archive as the given entry.
+func (w *ArchiveWriter) Add(entry ArchiveEntry, clearData io.Reader) error {
+	return w.AddContext(context.Background(), entry, clearData)
+}
+
+// AddContext is like Add but stops waiting for the stream creation when ctx is done.
+func (w *ArchiveWriter) AddContext(ctx context.Context, entry ArchiveEntry, clearData io.Reader) error {
+	if err := w.check("Add", entry); err != nil {
+		return err
+	}
+	stream, err := w.encrypt(ctx, clearData)
+	if err != nil {
+		return err
+	}
+	defer stream.Close() // nolint: errcheck
+	return w.AddStream(entry, stream)
+}
+
+// AddEncrypted adds data encrypted with Encrypt() to the archive as the given entry.
+func (w *ArchiveWriter) AddEncrypted(entry ArchiveEntry, encryptedData []byte) error {
+	if err := w.check("AddEncrypted", entry); err != nil {
+		return err
+	}
+	resourceID, err := w.tanker.GetResourceId(encryptedData)
+	if err != nil {
+		return err
+	}
+	return w.addEntry(entry, *resourceID, bytes.NewReader(encryptedData))
+}
+
+// AddStream adds the data of a stream returned by StreamEncrypt() to the archive as
+// the given entry. The stream is read until its end, but not closed.
+func (w *ArchiveWriter) AddStream(entry ArchiveEntry, stream Stream) error {
+	if err := w.check("AddStream", entry); err != nil {
+		return err
+	}
+	resourceID, err := stream.GetResourceID()
+	if err != nil {
+		return err
+	}
+	return w.addEntry(entry, *resourceID, stream)
+}
+
+// Entries returns the entries added so far.
+func (w *ArchiveWriter) Entries() []ArchiveEntry {
+	return append([]ArchiveEntry{}, w.entries...)
+}
+
+// Close writes the encrypted index of the archive. The destination writer is not closed.
+func (w *ArchiveWriter) Close() error {
+	return w.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but stops waiting for the encryption of the index when ctx is done.
+func (w *ArchiveWriter) CloseContext(ctx context.Context) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return nil
+	}
+	index, err := json.Marshal(archiveIndex{Entries: w.Entries()})
+	if err != nil {
+		return err
+	}
+	stream, err := w.encrypt(ctx, bytes.NewReader(index))
+	if err != nil {
+		return err
+	}
+	defer stream.Close() // nolint: errcheck
+	// Writes the archive header if there is no entry
+	if _, err = w.write(bytes.NewReader(nil)); err != nil {
+		return err
+	}
+	indexOffset := w.offset
+	indexSize, err := w.write(stream)
+	if err != nil {
+		return err
+	}
+	trailer := make([]byte, archiveTrailerSize)
+	binary.LittleEndian.PutUint64(trailer[0:8], uint64(indexOffset))
+	binary.LittleEndian.PutUint64(trailer[8:16], uint64(indexSize))
+	copy(trailer[16:], archiveMagic)
+	if _, err = w.write(bytes.NewReader(trailer)); err != nil {
+		return err
+	}
+	w.closed = true
+	return nil
+}
+
+// ArchiveReader reads the entries of an archive written by an ArchiveWriter. Only
+// the index and the entries which are opened are read from the source.
+type ArchiveReader struct {
+	tanker  Client
+	src     io.ReaderAt
+	entries []ArchiveEntry
+}
+
+// OpenArchive decrypts the index of the archive of the given size read from src.
+func OpenArchive(t Client, src io.ReaderAt, size int64) (*ArchiveReader, error) {
+	return OpenArchiveContext(context.Background(), t, src, size)
+}
+
+// OpenArchiveContext is like OpenArchive but stops waiting for the decryption of the index when ctx is done.
+func OpenArchiveContext(ctx context.Context, t Client, src io.ReaderAt, size int64) (*ArchiveReader, error) {
+	if size < archiveHeaderSize+archiveTrailerSize {
+		return nil, newError(ErrorInvalidArgument, "OpenArchive", "not an archive")
+	}
+	header := make([]byte, archiveHeaderSize)
+	if _, err := src.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, archiveTrailerSize)
+	if _, err := src.ReadAt(trailer, size-archiveTrailerSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(header[:4], archiveMagic) || !bytes.Equal(trailer[16:], archiveMagic) {
+		return nil, newError(ErrorInvalidArgument, "OpenArchive", "not an archive")
+	}
+	if header[4] != archiveVersion {
+		return nil, newError(ErrorInvalidArgument, "OpenArchive", "unsupported archive version")
+	}
+	indexOffset := int64(binary.LittleEndian.Uint64(trailer[0:8]))
+	indexSize := int64(binary.LittleEndian.Uint64(trailer[8:16]))
+	dataEnd := size - archiveTrailerSize
+	if indexOffset < archiveHeaderSize || indexSize <= 0 || indexOffset > dataEnd || indexSize > dataEnd-indexOffset {
+		return nil, newError(ErrorDecryptionFailed, "OpenArchive", "corrupted archive")
+	}
+
+	stream, err := t.StreamDecryptContext(ctx, io.NewSectionReader(src, indexOffset, indexSize))
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close() // nolint: errcheck
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return nil, err
+	}
+	var index archiveIndex
+	if err = json.Unmarshal(data, &index); err != nil {
+		return nil, newError(ErrorDecryptionFailed, "OpenArchive", "invalid archive index: "+err.Error())
+	}
+	for _, entry := range index.Entries {
+		if entry.Offset < archiveHeaderSize || entry.Size <= 0 || entry.Offset > indexOffset || entry.Size > indexOffset-entry.Offset {
+			return nil, newError(ErrorDecryptionFailed, "OpenArchive", "corrupted archive")
+		}
+	}
+	return &ArchiveReader{tanker: t, src: src, entries: index.Entries}, nil
+}
+
+// Entries returns the entries of the archive, in the order they were added.
+func (r *ArchiveReader) Entries() []ArchiveEntry {
+	return append([]ArchiveEntry{}, r.entries...)
+}
+
+// Entry returns the entry of the given name.
+func (r *ArchiveReader) Entry(name string) (*ArchiveEntry, error) {
+	for i := range r.entries {
+		if r.entries[i].Name == name {
+			entry := r.entries[i]
+			return &entry, nil
+		}
+	}
+	return nil, newError(ErrorInvalidArgument, "Open", "no such entry: "+name)
+}
+
+// Open creates a stream decrypting the entry of the given name.
+func (r *ArchiveReader) Open(name string) (Stream, error) {
+	return r.OpenContext(context.Background(), name)
+}
+
+// OpenContext is like Open but stops waiting for the stream creation when ctx is done.
+// Reading from the returned Stream is not affected by ctx.
+func (r *ArchiveReader) OpenContext(ctx context.Context, name string) (Stream, error) {
+	entry, err := r.Entry(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.tanker.StreamDecryptContext(ctx, io.NewSectionReader(r.src, entry.Offset, entry.Size))
+}
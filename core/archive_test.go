@@ -0,0 +1,132 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+	"github.com/TankerHQ/sdk-go/v2/helpers"
+)
+
+var _ = Describe("Archives", func() {
+
+	var (
+		alice        helpers.User
+		aliceLaptop  *helpers.Device
+		aliceSession *core.Tanker
+	)
+
+	BeforeEach(func() {
+		alice = TestApp.CreateUser()
+		aliceLaptop, _ = alice.CreateDevice()
+		aliceSession, _ = aliceLaptop.Start()
+	})
+
+	AfterEach(func() {
+		Expect(aliceSession.Stop()).To(Succeed())
+	})
+
+	It("Writes and reads archives", func() {
+		var archive bytes.Buffer
+		writer := core.NewArchiveWriter(aliceSession, &archive, nil)
+		Expect(writer.Add(core.ArchiveEntry{Name: "a.txt", ContentType: "text/plain"}, bytes.NewReader([]byte("added")))).To(Succeed())
+		encrypted, err := aliceSession.Encrypt([]byte("encrypted"), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.AddEncrypted(core.ArchiveEntry{Name: "b.bin", Metadata: map[string]string{"k": "v"}}, encrypted)).To(Succeed())
+		session, err := aliceSession.CreateEncryptionSession(nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer session.Destroy()
+		stream, err := session.StreamEncrypt(bytes.NewReader([]byte("streamed")))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.AddStream(core.ArchiveEntry{Name: "c"}, stream)).To(Succeed())
+		Expect(stream.Close()).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		reader, err := core.OpenArchive(aliceSession, bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Entries()).To(Equal(writer.Entries()))
+		entries := reader.Entries()
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].ContentType).To(Equal("text/plain"))
+		Expect(entries[1].Metadata).To(Equal(map[string]string{"k": "v"}))
+		Expect(entries[2].ResourceID).To(Equal(session.GetResourceId()))
+		for name, content := range map[string]string{"a.txt": "added", "b.bin": "encrypted", "c": "streamed"} {
+			entry, err := reader.Open(name)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.ReadAll(entry)).To(Equal([]byte(content)))
+			Expect(entry.Close()).To(Succeed())
+		}
+		_, err = reader.Open("missing")
+		expectErrorCode(err, core.ErrorInvalidArgument)
+	})
+
+	It("Encrypts the entries and the index with an encryption session", func() {
+		session, err := aliceSession.CreateEncryptionSession(nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer session.Destroy()
+		var archive bytes.Buffer
+		writer := core.NewArchiveWriter(aliceSession, &archive, &core.ArchiveOptions{Session: session})
+		Expect(writer.Add(core.ArchiveEntry{Name: "a"}, bytes.NewReader([]byte("a")))).To(Succeed())
+		Expect(writer.Add(core.ArchiveEntry{Name: "b"}, bytes.NewReader([]byte("b")))).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		for _, entry := range writer.Entries() {
+			Expect(entry.ResourceID).To(Equal(session.GetResourceId()))
+		}
+		reader, err := core.OpenArchive(aliceSession, bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Entries()).To(HaveLen(2))
+	})
+
+	It("Writes archives without entries", func() {
+		var archive bytes.Buffer
+		writer := core.NewArchiveWriter(aliceSession, &archive, nil)
+		Expect(writer.Close()).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		reader, err := core.OpenArchive(aliceSession, bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Entries()).To(BeEmpty())
+	})
+
+	It("Rejects invalid entries and the entries added once closed", func() {
+		var archive bytes.Buffer
+		writer := core.NewArchiveWriter(aliceSession, &archive, nil)
+		Expect(writer.Add(core.ArchiveEntry{Name: "a"}, bytes.NewReader([]byte("a")))).To(Succeed())
+		expectErrorCode(writer.Add(core.ArchiveEntry{Name: "a"}, bytes.NewReader(nil)), core.ErrorInvalidArgument)
+		expectErrorCode(writer.Add(core.ArchiveEntry{}, bytes.NewReader(nil)), core.ErrorInvalidArgument)
+		Expect(writer.Close()).To(Succeed())
+		expectErrorCode(writer.Add(core.ArchiveEntry{Name: "b"}, bytes.NewReader(nil)), core.ErrorPreconditionFailed)
+		Expect(writer.Entries()).To(HaveLen(1))
+	})
+
+	It("Rejects the data which is not a readable archive", func() {
+		var archive bytes.Buffer
+		writer := core.NewArchiveWriter(aliceSession, &archive, nil)
+		Expect(writer.Add(core.ArchiveEntry{Name: "a"}, bytes.NewReader([]byte("a")))).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+		data := archive.Bytes()
+
+		encrypted, err := aliceSession.Encrypt([]byte("encrypted"), nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = core.OpenArchive(aliceSession, bytes.NewReader(encrypted), int64(len(encrypted)))
+		expectErrorCode(err, core.ErrorInvalidArgument)
+
+		// The index offset of the trailer points past the end of the data
+		corrupted := append([]byte{}, data...)
+		binary.LittleEndian.PutUint64(corrupted[len(corrupted)-20:], uint64(len(corrupted)))
+		_, err = core.OpenArchive(aliceSession, bytes.NewReader(corrupted), int64(len(corrupted)))
+		expectErrorCode(err, core.ErrorDecryptionFailed)
+
+		bob := TestApp.CreateUser()
+		bobLaptop, _ := bob.CreateDevice()
+		bobSession, _ := bobLaptop.Start()
+		defer bobSession.Stop() // nolint: errcheck
+		_, err = core.OpenArchive(bobSession, bytes.NewReader(data), int64(len(data)))
+		expectErrorCode(err, core.ErrorInvalidArgument)
+	})
+})
@@ -1,19 +1,29 @@
 package core
 
 /*
+#include <stdlib.h>
 #include <ctanker.h>
 
 void* tanker_then_handler_proxy(tanker_future_t*, void *v);
+void* tanker_then_awaiter_proxy(tanker_future_t*, void *v);
 
 static void _tanker_future_then(tanker_future_t *fut, void* user_data) {
 	tanker_future_t *thenFut = tanker_future_then(fut, tanker_then_handler_proxy, user_data);
 	tanker_future_destroy(fut);
 	tanker_future_destroy(thenFut);
 }
+
+static void _tanker_future_then_awaiter(tanker_future_t *fut, void* user_data) {
+	tanker_future_t *thenFut = tanker_future_then(fut, tanker_then_awaiter_proxy, user_data);
+	tanker_future_destroy(fut);
+	tanker_future_destroy(thenFut);
+}
 */
 import "C"
 
 import (
+	"context"
+	"runtime"
 	"unsafe"
 
 	gopointer "github.com/mattn/go-pointer"
@@ -30,19 +40,28 @@ type resultChan chan futureResult
 func tanker_then_handler_proxy(fut *C.tanker_future_t, v unsafe.Pointer) unsafe.Pointer {
 	tan := (gopointer.Restore(v)).(*resultChan)
 	gopointer.Unref(v)
+	*tan <- getFutureResult(fut)
+	return nil
+}
+
+//export tanker_then_awaiter_proxy
+func tanker_then_awaiter_proxy(fut *C.tanker_future_t, v unsafe.Pointer) unsafe.Pointer {
+	a := (gopointer.Restore(v)).(*awaiter)
+	a.results <- getFutureResult(fut)
+	return nil
+}
+
+func getFutureResult(fut *C.tanker_future_t) futureResult {
 	err := C.tanker_future_get_error(fut)
 	if err != nil {
-		terror := newError(ErrorCode(err.code), C.GoString(err.message))
-		*tan <- futureResult{err: terror}
-		return nil
+		return futureResult{err: newError(ErrorCode(err.code), "", C.GoString(err.message))}
 	}
-	*tan <- futureResult{result: C.tanker_future_get_voidptr(fut)}
-	return nil
+	return futureResult{result: C.tanker_future_get_voidptr(fut)}
 }
 
 // await kind of awaits for a tanker_future_t to complete, this is dark magic, beware.
 func await(future *C.tanker_future_t) (unsafe.Pointer, error) {
-	tan := make(resultChan)
+	tan := make(resultChan, 1)
 
 	C._tanker_future_then(future, gopointer.Save(&tan))
 	result := <-tan
@@ -51,3 +70,180 @@ func await(future *C.tanker_future_t) (unsafe.Pointer, error) {
 	}
 	return result.result, nil
 }
+
+// getExpected returns the result of a tanker_expected_t, which unlike a future
+// is ready as soon as it is returned, and destroys it.
+func getExpected(expected *C.tanker_expected_t) (unsafe.Pointer, error) {
+	result := getFutureResult(expected)
+	C.tanker_future_destroy(expected)
+	return result.result, result.err
+}
+
+// awaitFunc waits for the completion of the native operation returned by
+// start, it lets the same code be run with await() or awaitContext().
+type awaitFunc func(start func() *C.tanker_future_t) (unsafe.Pointer, error)
+
+// awaitWith returns an awaitFunc calling await(), naming the errors after operation.
+func awaitWith(operation string) awaitFunc {
+	return func(start func() *C.tanker_future_t) (unsafe.Pointer, error) {
+		result, err := await(start())
+		return result, withOperation(err, operation)
+	}
+}
+
+// awaitContextWith returns an awaitFunc calling awaitContext() with args.
+func awaitContextWith(ctx context.Context, operation string, args *nativeArgs) awaitFunc {
+	return func(start func() *C.tanker_future_t) (unsafe.Pointer, error) {
+		return awaitContext(ctx, operation, args, start, nil)
+	}
+}
+
+// nativeArgs owns the arguments of a native operation: the C memory it reads
+// and the Go buffers it reads from or writes to. The caller defers release(),
+// which frees them when it returns, unless awaitContext() gave up on the
+// operation: they are then freed once the operation completes.
+type nativeArgs struct {
+	frees    []func()
+	abandons []func()
+	pinned   []interface{}
+	detached bool
+}
+
+// onRelease registers free to be called when the arguments are released.
+func (a *nativeArgs) onRelease(free func()) {
+	a.frees = append(a.frees, free)
+}
+
+// onAbandon registers abandon to be called if awaitContext() gives up on the
+// operation, once the operation completes, whatever its result. It releases
+// what the operation uses and the caller would have kept on success.
+func (a *nativeArgs) onAbandon(abandon func()) {
+	a.abandons = append(a.abandons, abandon)
+}
+
+// pin keeps values alive until the arguments are released.
+func (a *nativeArgs) pin(values ...interface{}) {
+	a.pinned = append(a.pinned, values...)
+}
+
+// alloc returns size bytes of zeroed C memory.
+func (a *nativeArgs) alloc(size C.size_t) unsafe.Pointer {
+	pointer := C.calloc(1, size)
+	a.onRelease(func() { C.free(pointer) })
+	return pointer
+}
+
+// cString returns a C copy of value.
+func (a *nativeArgs) cString(value string) *C.char {
+	cvalue := C.CString(value)
+	a.onRelease(func() { C.free(unsafe.Pointer(cvalue)) })
+	return cvalue
+}
+
+// secretCString is like cString, but zeroes the copy when it is freed.
+func (a *nativeArgs) secretCString(value string) *C.char {
+	cvalue := C.CString(value)
+	a.onRelease(func() { freeSecret(cvalue) })
+	return cvalue
+}
+
+// cArray returns a C copy of values, nil if values is empty.
+func (a *nativeArgs) cArray(values []string) **C.char {
+	array := toCArray(values)
+	size := len(values)
+	a.onRelease(func() { freeCArray(array, size) })
+	return array
+}
+
+// release frees the arguments, unless awaitContext() detached them from the caller.
+func (a *nativeArgs) release() {
+	if a.detached {
+		return
+	}
+	a.free()
+}
+
+func (a *nativeArgs) free() {
+	if a.detached {
+		for _, abandon := range a.abandons {
+			abandon()
+		}
+	}
+	for i := len(a.frees) - 1; i >= 0; i-- {
+		a.frees[i]()
+	}
+	runtime.KeepAlive(a.pinned)
+	a.frees = nil
+	a.abandons = nil
+	a.pinned = nil
+}
+
+// awaiter awaits futures one after the other, reusing the same channel and
+// gopointer handle. It spares the allocations of await() on hot paths, such as
+// the reads of a stream. release() must be called once the awaiter is no longer used.
+type awaiter struct {
+	results resultChan
+	handle  unsafe.Pointer
+}
+
+func newAwaiter() *awaiter {
+	a := &awaiter{results: make(resultChan, 1)}
+	a.handle = gopointer.Save(a)
+	return a
+}
+
+// await must not be called concurrently.
+func (a *awaiter) await(future *C.tanker_future_t) (unsafe.Pointer, error) {
+	C._tanker_future_then_awaiter(future, a.handle)
+	result := <-a.results
+	return result.result, result.err
+}
+
+func (a *awaiter) release() {
+	gopointer.Unref(a.handle)
+}
+
+// awaitContext starts the native operation returned by start and awaits it until
+// it completes or ctx is done, whichever comes first. In the latter case an
+// ErrorOperationCanceled error wrapping ctx.Err() is returned. The returned
+// errors are named after operation.
+//
+// The native library has no API to cancel a running operation: once ctx is
+// done, the operation keeps running in the background. The ownership of args,
+// which start must use for every C allocation and Go buffer the operation
+// reads or writes, is then handed to a goroutine which hands the result of the
+// operation, if any, to release once it completes, and then frees them. args
+// may be nil if the operation takes no such argument.
+func awaitContext(ctx context.Context, operation string, args *nativeArgs, start func() *C.tanker_future_t, release func(unsafe.Pointer)) (unsafe.Pointer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newCanceledError(operation, err)
+	}
+	tan := make(resultChan, 1)
+
+	C._tanker_future_then(start(), gopointer.Save(&tan))
+	select {
+	case result := <-tan:
+		return result.result, withOperation(result.err, operation)
+	case <-ctx.Done():
+	}
+	select {
+	case result := <-tan:
+		// The operation completed concurrently, do not throw its result away
+		return result.result, withOperation(result.err, operation)
+	default:
+	}
+	if args != nil {
+		args.detached = true
+	}
+	go func() {
+		result := <-tan
+		runtime.KeepAlive(start)
+		if result.err == nil && release != nil {
+			release(result.result)
+		}
+		if args != nil {
+			args.free()
+		}
+	}()
+	return nil, newCanceledError(operation, ctx.Err())
+}
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"sync"
+)
+
+// The default batch sizes of BulkShare(), which stay within the limits of the
+// Tanker server for a single Share().
+const (
+	DefaultShareBatchResources  = 100
+	DefaultShareBatchRecipients = 100
+	defaultShareWorkers         = 4
+)
+
+// BulkShareOptions configures BulkShare().
+type BulkShareOptions struct {
+	// MaxResources is the maximum number of resource IDs shared by a batch,
+	// zero means DefaultShareBatchResources.
+	MaxResources int
+	// MaxRecipients is the maximum number of users and groups a batch shares
+	// with, zero means DefaultShareBatchRecipients.
+	MaxRecipients int
+	// Workers is the number of batches shared concurrently, zero means 4.
+	Workers int
+	// Retry, if set, retries the batches failing with a retryable error. It
+	// replaces TankerOptions.Retry for the batches shared with a *Tanker, so
+	// that the attempts are not multiplied. When nil, the batches are shared with
+	// ShareContext(), and retried like any Share().
+	Retry *RetryPolicy
+	// Checkpoint, if set, is the checkpoint of a previous BulkShare() with the
+	// same arguments, the batches it records as done are skipped.
+	Checkpoint *ShareCheckpoint
+	// OnBatch, if set, is called after each batch with its result and the
+	// current checkpoint, so that the checkpoint can be persisted. The calls
+	// are serialized.
+	OnBatch func(batch ShareBatch, checkpoint ShareCheckpoint)
+}
+
+// ShareCheckpoint records the batches of a BulkShare() which are done, so that
+// an interrupted bulk share can be resumed. It can be marshaled to JSON.
+type ShareCheckpoint struct {
+	// Fingerprint identifies the resource IDs, the recipients and the batch
+	// sizes of the bulk share.
+	Fingerprint string `json:"fingerprint"`
+	// Done lists the indexes of the batches which are done, in increasing order.
+	Done []int `json:"done"`
+}
+
+// ShareBatch is the result of a batch of a BulkShare().
+type ShareBatch struct {
+	// Index is the position of the batch in the bulk share.
+	Index          int
+	ResourceIDs    []string
+	SharingOptions SharingOptions
+	// Skipped is true if the batch was done according to the checkpoint.
+	Skipped bool
+	// Err is the error of the last attempt of the batch, nil if it succeeded.
+	// It is an ErrorOperationCanceled error if the batch was not attempted.
+	Err error
+}
+
+// onceSharer is implemented by the clients which retry ShareContext(), to share
+// with a single attempt when BulkShareOptions.Retry retries the batches.
+type onceSharer interface {
+	shareOnce(ctx context.Context, resourceIDs []string, sharingOptions SharingOptions) error
+}
+
+// BulkShareReport is the result of a BulkShare().
+type BulkShareReport struct {
+	// Batches are the results of the batches, ordered by index.
+	Batches    []ShareBatch
+	checkpoint ShareCheckpoint
+}
+
+// Failed returns the resource IDs which have not been shared with every
+// recipient, in the order they were passed.
+func (r *BulkShareReport) Failed() []string {
+	failed := make(map[string]bool)
+	var ordered []string
+	for _, batch := range r.Batches {
+		if batch.Err == nil {
+			continue
+		}
+		for _, resourceID := range batch.ResourceIDs {
+			if !failed[resourceID] {
+				failed[resourceID] = true
+				ordered = append(ordered, resourceID)
+			}
+		}
+	}
+	return ordered
+}
+
+// Succeeded returns the resource IDs which have been shared with every
+// recipient.
+func (r *BulkShareReport) Succeeded() []string {
+	failed := make(map[string]bool)
+	for _, resourceID := range r.Failed() {
+		failed[resourceID] = true
+	}
+	seen := make(map[string]bool)
+	var succeeded []string
+	for _, batch := range r.Batches {
+		for _, resourceID := range batch.ResourceIDs {
+			if !failed[resourceID] && !seen[resourceID] {
+				seen[resourceID] = true
+				succeeded = append(succeeded, resourceID)
+			}
+		}
+	}
+	return succeeded
+}
+
+// Checkpoint returns the checkpoint to pass to BulkShareOptions to resume the
+// bulk share, it records the batches which succeeded.
+func (r *BulkShareReport) Checkpoint() ShareCheckpoint {
+	return ShareCheckpoint{Fingerprint: r.checkpoint.Fingerprint, Done: append([]int{}, r.checkpoint.Done...)}
+}
+
+// BulkShare shares a large number of resources with a large number of recipients.
+// Unlike Share(), it splits the resource IDs and the recipients into batches, which
+// are shared concurrently and independently: a failed batch does not prevent the
+// others from being shared. The report tells which resources were shared, the
+// returned error is the error of the first failed batch.
+//
+//	report, err := core.BulkShare(tanker, resourceIDs, core.SharingOptions{ShareWithUsers: members}, nil)
+//	if err != nil {
+//		retryLater(report.Failed(), report.Checkpoint())
+//	}
+func BulkShare(t Client, resourceIDs []string, sharingOptions SharingOptions, options *BulkShareOptions) (*BulkShareReport, error) {
+	return BulkShareContext(context.Background(), t, resourceIDs, sharingOptions, options)
+}
+
+// BulkShareContext is like BulkShare but stops starting batches when ctx is done.
+// The batches which were not attempted are reported with an ErrorOperationCanceled error.
+func BulkShareContext(ctx context.Context, t Client, resourceIDs []string, sharingOptions SharingOptions, options *BulkShareOptions) (*BulkShareReport, error) {
+	if options == nil {
+		options = &BulkShareOptions{}
+	}
+	if len(resourceIDs) == 0 {
+		return nil, newError(ErrorInvalidArgument, "BulkShare", "resourceIDs must not be nil nor empty")
+	}
+	if len(sharingOptions.ShareWithUsers) == 0 && len(sharingOptions.ShareWithGroups) == 0 {
+		return nil, newError(ErrorInvalidArgument, "BulkShare", "no recipients to share with")
+	}
+	maxResources := positiveOr(options.MaxResources, DefaultShareBatchResources)
+	maxRecipients := positiveOr(options.MaxRecipients, DefaultShareBatchRecipients)
+	fingerprint := shareFingerprint(resourceIDs, sharingOptions, maxResources, maxRecipients)
+	done := make(map[int]bool)
+	if options.Checkpoint != nil {
+		if options.Checkpoint.Fingerprint != fingerprint {
+			return nil, newError(ErrorInvalidArgument, "BulkShare", "the checkpoint is not the one of this bulk share")
+		}
+		for _, index := range options.Checkpoint.Done {
+			done[index] = true
+		}
+	}
+
+	report := &BulkShareReport{
+		Batches:    splitShare(resourceIDs, sharingOptions, maxResources, maxRecipients),
+		checkpoint: ShareCheckpoint{Fingerprint: fingerprint},
+	}
+	var mutex sync.Mutex
+	finish := func(batch *ShareBatch) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if batch.Err == nil {
+			report.checkpoint.Done = append(report.checkpoint.Done, batch.Index)
+			sort.Ints(report.checkpoint.Done)
+		}
+		if options.OnBatch != nil {
+			options.OnBatch(*batch, report.Checkpoint())
+		}
+	}
+
+	share := t.ShareContext
+	if sharer, ok := t.(onceSharer); ok && options.Retry != nil {
+		share = sharer.shareOnce
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < positiveOr(options.Workers, defaultShareWorkers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				batch := &report.Batches[index]
+				batch.Err = doWithRetry(ctx, options.Retry, "BulkShare", func() error {
+					return share(ctx, batch.ResourceIDs, batch.SharingOptions)
+				})
+				finish(batch)
+			}
+		}()
+	}
+	next := 0
+feed:
+	for ; next < len(report.Batches); next++ {
+		if done[next] {
+			report.Batches[next].Skipped = true
+			finish(&report.Batches[next])
+			continue
+		}
+		select {
+		case indexes <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	for ; next < len(report.Batches); next++ {
+		if done[next] {
+			report.Batches[next].Skipped = true
+			finish(&report.Batches[next])
+		} else {
+			report.Batches[next].Err = newCanceledError("BulkShare", ctx.Err())
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	for _, batch := range report.Batches {
+		if batch.Err != nil {
+			return report, batch.Err
+		}
+	}
+	return report, nil
+}
+
+func positiveOr(value int, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// splitShare returns the batches sharing every chunk of resource IDs with every
+// chunk of recipients.
+func splitShare(resourceIDs []string, sharingOptions SharingOptions, maxResources int, maxRecipients int) []ShareBatch {
+	var recipients []SharingOptions
+	users, groups := sharingOptions.ShareWithUsers, sharingOptions.ShareWithGroups
+	for len(users) > 0 || len(groups) > 0 {
+		var chunk SharingOptions
+		n := minInt(len(users), maxRecipients)
+		chunk.ShareWithUsers, users = users[:n:n], users[n:]
+		if n < maxRecipients {
+			m := minInt(len(groups), maxRecipients-n)
+			chunk.ShareWithGroups, groups = groups[:m:m], groups[m:]
+		}
+		recipients = append(recipients, chunk)
+	}
+	var batches []ShareBatch
+	for _, chunk := range recipients {
+		for start := 0; start < len(resourceIDs); start += maxResources {
+			end := minInt(start+maxResources, len(resourceIDs))
+			batches = append(batches, ShareBatch{
+				Index:          len(batches),
+				ResourceIDs:    resourceIDs[start:end:end],
+				SharingOptions: chunk,
+			})
+		}
+	}
+	return batches
+}
+
+// shareFingerprint hashes the arguments which determine the batches of a bulk share.
+func shareFingerprint(resourceIDs []string, sharingOptions SharingOptions, maxResources int, maxRecipients int) string {
+	hash := sha256.New()
+	hashStrings(hash, resourceIDs)
+	hashStrings(hash, sharingOptions.ShareWithUsers)
+	hashStrings(hash, sharingOptions.ShareWithGroups)
+	sizes := make([]byte, 16)
+	binary.BigEndian.PutUint64(sizes[:8], uint64(maxResources))
+	binary.BigEndian.PutUint64(sizes[8:], uint64(maxRecipients))
+	hash.Write(sizes) // nolint: errcheck
+	return hex.EncodeToString(hash.Sum(nil))
+}
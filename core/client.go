@@ -0,0 +1,37 @@
+package core
+
+import (
+	"io"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// Client is the set of operations available on a Tanker instance.
+// It is satisfied by *Tanker, code depending on it rather than on *Tanker
+// can be unit tested with the fakes of the coremock and coretest packages.
+type Client = coretypes.Client
+
+// EncryptionSessionClient is the set of operations available on an encryption session.
+// It is satisfied by *EncryptionSession.
+type EncryptionSessionClient = coretypes.EncryptionSessionClient
+
+// Stream is the set of operations available on an encryption or decryption stream.
+// It is satisfied by *OutputStream.
+type Stream = coretypes.Stream
+
+// StreamWriter is the set of operations available on an encrypting or decrypting writer.
+// It is satisfied by *InputStream.
+type StreamWriter = coretypes.StreamWriter
+
+// SeekableStream is the set of operations available on a random-access decryption stream.
+// It is satisfied by *RandomAccessStream.
+type SeekableStream = coretypes.SeekableStream
+
+var (
+	_ Client                  = (*Tanker)(nil)
+	_ EncryptionSessionClient = (*EncryptionSession)(nil)
+	_ Stream                  = (*OutputStream)(nil)
+	_ io.WriterTo             = (*OutputStream)(nil)
+	_ StreamWriter            = (*InputStream)(nil)
+	_ SeekableStream          = (*RandomAccessStream)(nil)
+)
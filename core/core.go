@@ -7,48 +7,63 @@ package core
 */
 import "C"
 import (
+	"context"
 	"fmt"
 	"unsafe"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
 ) //nolint
 
 // Status represents the Tanker current status.
-type Status uint32
+type Status = coretypes.Status
 
 const (
-	StatusStopped Status = iota
-	StatusReady
-	StatusIdentityRegistrationNeeded
-	StatusIdentityVerificationNeeded
+	StatusStopped                    = coretypes.StatusStopped
+	StatusReady                      = coretypes.StatusReady
+	StatusIdentityRegistrationNeeded = coretypes.StatusIdentityRegistrationNeeded
+	StatusIdentityVerificationNeeded = coretypes.StatusIdentityVerificationNeeded
 )
 
 // EncryptionOptions contains user and group recipients to share with during an @Encrypt()
-type EncryptionOptions struct {
-	// ShareWithUsers is a list of the public identities to share with
-	ShareWithUsers []string
-	// ShareWithGroups is a list of group IDs to share with
-	ShareWithGroups []string
-	// ShareWithSelf must be true to allow the author to decrypt the resource
-	ShareWithSelf bool
-}
+type EncryptionOptions = coretypes.EncryptionOptions
+
+// Padding is the padding scheme of the clear data. Besides PaddingAuto and
+// PaddingOff, any value greater than 1 pads the clear data to a multiple of
+// that value:
+//
+//	options := core.NewEncryptionOptions()
+//	options.PaddingStep = 500
+type Padding = coretypes.Padding
+
+const (
+	// PaddingAuto pads the clear data with a scheme chosen by the native library,
+	// which limits the overhead while hiding the exact length
+	PaddingAuto = coretypes.PaddingAuto
+	// PaddingOff disables the padding
+	PaddingOff = coretypes.PaddingOff
+)
+
+// The statuses and paddings must match the ones of the native library
+var (
+	_ = [1]struct{}{}[StatusStopped-C.TANKER_STATUS_STOPPED]
+	_ = [1]struct{}{}[StatusReady-C.TANKER_STATUS_READY]
+	_ = [1]struct{}{}[StatusIdentityRegistrationNeeded-C.TANKER_STATUS_IDENTITY_REGISTRATION_NEEDED]
+	_ = [1]struct{}{}[StatusIdentityVerificationNeeded-C.TANKER_STATUS_IDENTITY_VERIFICATION_NEEDED]
+	_ = [1]struct{}{}[PaddingAuto-C.TANKER_PADDING_AUTO]
+	_ = [1]struct{}{}[PaddingOff-C.TANKER_PADDING_OFF]
+)
 
 // NewEncryptionOptions creates EncryptionOptions with default values
 func NewEncryptionOptions() EncryptionOptions {
-	return EncryptionOptions{
-		ShareWithSelf: true,
-	}
+	return coretypes.NewEncryptionOptions()
 }
 
 // SharingOptions contains user and group recipients to share with with @Share()
-type SharingOptions struct {
-	// ShareWithUsers is a list of the public identities to share with
-	ShareWithUsers []string
-	// ShareWithGroups is a list of group IDs to share with
-	ShareWithGroups []string
-}
+type SharingOptions = coretypes.SharingOptions
 
 // NewSharingOptions creates SharingOptions with default values
 func NewSharingOptions() SharingOptions {
-	return SharingOptions{}
+	return coretypes.NewSharingOptions()
 }
 
 // unsafeANSIToString transforms a *C.char to a GoString. The *C.char is free'd.
@@ -62,6 +77,10 @@ func unsafeANSIToString(pointer unsafe.Pointer) string {
 // Tanker represents a Tanker instance.
 type Tanker struct {
 	instance *C.tanker_t
+	events   *eventDispatcher
+	retry    *RetryPolicy
+	streams  StreamOptions
+	observer Observer
 }
 
 // initializeTanker initializes the native library.
@@ -71,27 +90,8 @@ func initializeTanker() {
 	C.tanker_init()
 }
 
-// EventHandler defines the function object type used by RegisterEventHandler().
-type EventHandler func()
-
-// EventType represents the type of event one can register and be notified of.
-type EventType uint32
-
-const (
-	EventSessionClosed EventType = C.TANKER_EVENT_SESSION_CLOSED
-	EventDeviceRevoked EventType = C.TANKER_EVENT_DEVICE_REVOKED
-)
-
-// RegisterEventHandler registers an event handler for the given EventType.
-func (t *Tanker) RegisterEventHandler(event EventType, handler EventHandler) error {
-	panic("Not Implemented")
-}
-
 // DeviceDescription contains the id of a device and whether this device has been revoked.
-type DeviceDescription struct {
-	DeviceID  string
-	IsRevoked bool
-}
+type DeviceDescription = coretypes.DeviceDescription
 
 // Version returns the current version of this SDK.
 func Version() string {
@@ -107,47 +107,59 @@ func NativeVersion() string {
 // PrehashPassword allows to hash a password before sending it to your
 // application server, read the documentation for more detail
 func PrehashPassword(password string) (string, error) {
-	chashed, err := await(C.tanker_prehash_password(C.CString(password)))
+	return PrehashPasswordContext(context.Background(), password)
+}
+
+// PrehashPasswordContext is like PrehashPassword but stops waiting when ctx is done.
+func PrehashPasswordContext(ctx context.Context, password string) (string, error) {
+	args := &nativeArgs{}
+	defer args.release()
+	cpassword := args.secretCString(password)
+	chashed, err := awaitContext(ctx, "PrehashPassword", args, func() *C.tanker_future_t {
+		return C.tanker_prehash_password(cpassword)
+	}, releaseSecret)
 	if err != nil {
 		return "", err
 	}
-	hashed := C.GoString((*C.char)(unsafe.Pointer(chashed)))
-	return hashed, nil
+	return unsafeSecretToString(chashed), nil
 }
 
 // TankerOptions defines the options needed to create a new Tanker
 // instance with NewTanker().
-type TankerOptions struct {
-	// The Application ID you want to use.
-	AppID string
-	// An existing filesystem path to store persistent user data.
-	WritablePath string
-	// The url of the Tanker service. Should be left to nil.
-	Url *string
-}
+type TankerOptions = coretypes.TankerOptions
 
 // NewTanker creates a new a Tanker instance.
-//  session, err := core.NewTanker(core.TankerOptions{"<your app ID>", "/home/user/.config/fancyname/", nil})
+//  session, err := core.NewTanker(core.TankerOptions{AppID: "<your app ID>", WritablePath: "/home/user/.config/fancyname/"})
 func NewTanker(options TankerOptions) (*Tanker, error) {
+	return NewTankerContext(context.Background(), options)
+}
+
+// NewTankerContext is like NewTanker but stops waiting when ctx is done.
+func NewTankerContext(ctx context.Context, options TankerOptions) (*Tanker, error) {
+	if options.Retry != nil {
+		if err := validateRetryPolicy(options.Retry); err != nil {
+			return nil, err
+		}
+	}
 	initializeTanker()
 
-	cappID := C.CString(options.AppID)
+	args := &nativeArgs{}
+	defer args.release()
+	cappID := args.secretCString(options.AppID)
 	url := (*C.char)(unsafe.Pointer(uintptr(0)))
 	if options.Url != nil {
-		url = C.CString(*options.Url)
+		url = args.cString(*options.Url)
 	}
-	cwritablePath := C.CString(options.WritablePath)
-	sdkgo := C.CString("sdk-go")
-	version := C.CString(Version())
-	defer func() {
-		C.free(unsafe.Pointer(cappID))
-		C.free(unsafe.Pointer(url))
-		C.free(unsafe.Pointer(cwritablePath))
-		C.free(unsafe.Pointer(sdkgo))
-		C.free(unsafe.Pointer(version))
-	}()
-	this := Tanker{}
-	coptions := &C.tanker_options_t{
+	cwritablePath := args.cString(options.WritablePath)
+	sdkgo := args.cString("sdk-go")
+	version := args.cString(Version())
+	this := Tanker{streams: options.Streams, observer: options.Observer}
+	if options.Retry != nil {
+		retry := *options.Retry
+		this.retry = &retry
+	}
+	coptions := (*C.tanker_options_t)(args.alloc(C.sizeof_tanker_options_t))
+	*coptions = C.tanker_options_t{
 		version:       2,
 		app_id:        cappID,
 		url:           url,
@@ -155,11 +167,21 @@ func NewTanker(options TankerOptions) (*Tanker, error) {
 		sdk_type:      sdkgo,
 		sdk_version:   version,
 	}
-	result, err := await(C.tanker_create(coptions))
+	result, err := awaitContext(ctx, "NewTanker", args, func() *C.tanker_future_t {
+		return C.tanker_create(coptions)
+	}, func(result unsafe.Pointer) {
+		_, _ = await(C.tanker_destroy((*C.tanker_t)(result)))
+	})
 	if err != nil {
 		return nil, err
 	}
 	this.instance = (*C.tanker_t)(result)
+	this.events = newEventDispatcher()
+	if err = this.events.connect(this.instance); err != nil {
+		_, _ = await(C.tanker_destroy(this.instance))
+		this.events.close()
+		return nil, err
+	}
 
 	return &this, nil
 }
@@ -170,6 +192,7 @@ func NewTanker(options TankerOptions) (*Tanker, error) {
 // you'll need to create a new one.
 func (t *Tanker) Destroy() error {
 	_, err := await(C.tanker_destroy(t.instance))
+	t.events.close()
 	return err
 }
 
@@ -182,20 +205,38 @@ func (t *Tanker) Destroy() error {
 //  }
 // The Tanker status must be StatusStopped before calling Start().
 func (t *Tanker) Start(identity string) (Status, error) {
-	cidentity := C.CString(identity)
-	result, err := await(C.tanker_start(t.instance, cidentity))
-	defer C.free(unsafe.Pointer(cidentity))
+	return t.StartContext(context.Background(), identity)
+}
+
+// StartContext is like Start but stops waiting when ctx is done.
+func (t *Tanker) StartContext(ctx context.Context, identity string) (status Status, err error) {
+	observation := observe(t.observer, "Start", 0, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	cidentity := args.secretCString(identity)
+	result, err := awaitContext(ctx, "Start", args, func() *C.tanker_future_t {
+		return C.tanker_start(t.instance, cidentity)
+	}, nil)
 	if err != nil {
 		return StatusStopped, err
 	}
-	status := (Status)((uintptr)(result))
-	return status, nil
+	return (Status)((uintptr)(result)), nil
 }
 
 // Stop stops the current Tanker Session. This session can either
 // be destroyed with Destroy() or be restarted with Start().
 func (t *Tanker) Stop() error {
-	_, err := await(C.tanker_stop(t.instance))
+	return t.StopContext(context.Background())
+}
+
+// StopContext is like Stop but stops waiting when ctx is done.
+func (t *Tanker) StopContext(ctx context.Context) (err error) {
+	observation := observe(t.observer, "Stop", 0, 0)
+	defer func() { observation.end(0, err) }()
+	_, err = awaitContext(ctx, "Stop", nil, func() *C.tanker_future_t {
+		return C.tanker_stop(t.instance)
+	}, nil)
 	return err
 }
 
@@ -207,7 +248,14 @@ func (t *Tanker) GetStatus() Status {
 // GetDeviceID retrieves the current Tanker device's ID. Each device
 // has its own ID and can be identified as such.
 func (t *Tanker) GetDeviceID() (*string, error) {
-	result, err := await(C.tanker_device_id(t.instance))
+	return t.GetDeviceIDContext(context.Background())
+}
+
+// GetDeviceIDContext is like GetDeviceID but stops waiting when ctx is done.
+func (t *Tanker) GetDeviceIDContext(ctx context.Context) (*string, error) {
+	result, err := awaitContext(ctx, "GetDeviceID", nil, func() *C.tanker_future_t {
+		return C.tanker_device_id(t.instance)
+	}, releaseBuffer)
 	if err != nil {
 		return nil, err
 	}
@@ -218,75 +266,205 @@ func (t *Tanker) GetDeviceID() (*string, error) {
 // Encrypt encrypts the passed []byte and returns the result. To share the resulting
 // encrypted resource with either or both individuals and groups, fill the EncryptionOptions parameter.
 func (t *Tanker) Encrypt(clearData []byte, options *EncryptionOptions) ([]byte, error) {
+	return t.EncryptContext(context.Background(), clearData, options)
+}
+
+// EncryptContext is like Encrypt but stops waiting when ctx is done.
+func (t *Tanker) EncryptContext(ctx context.Context, clearData []byte, options *EncryptionOptions) (encryptedData []byte, err error) {
+	observation := observe(t.observer, "Encrypt", encryptionRecipients(options), int64(len(clearData)))
+	defer func() { observation.end(int64(len(encryptedData)), err) }()
 	if clearData == nil {
-		return nil, newError(ErrorInvalidArgument, "clearData must not be nil")
+		return nil, newError(ErrorInvalidArgument, "Encrypt", "clearData must not be nil")
 	}
-	var cClearData unsafe.Pointer
-	if len(clearData) == 0 {
-		cClearData = C.CBytes(clearData)
-	} else {
-		cClearData = unsafe.Pointer(&clearData[0])
+	encryptedData = make([]byte, EncryptedSize(len(clearData), options))
+	args := &nativeArgs{}
+	defer args.release()
+	if err := t.encrypt(awaitContextWith(ctx, "Encrypt", args), args, encryptedData, clearData, options); err != nil {
+		return nil, err
 	}
-	encryptedSize := C.tanker_encrypted_size(C.uint64_t(len(clearData)))
+	return encryptedData, nil
+}
 
-	encryptedData := make([]byte, encryptedSize)
-	var coptions *C.tanker_encrypt_options_t = nil
+// EncryptTo is like Encrypt, but appends the encrypted data to dst and returns the
+// extended buffer, in the style of append(). dst is only reallocated if its capacity
+// is lower than len(dst)+EncryptedSize(), so that buffers can be reused:
+//
+//	buffer, err = tanker.EncryptTo(buffer[:0], clearData, &options)
+//
+// On error, dst is returned unchanged.
+func (t *Tanker) EncryptTo(dst []byte, clearData []byte, options *EncryptionOptions) (extended []byte, err error) {
+	observation := observe(t.observer, "EncryptTo", encryptionRecipients(options), int64(len(clearData)))
+	defer func() { observation.end(int64(len(extended)-len(dst)), err) }()
+	if clearData == nil {
+		return dst, newError(ErrorInvalidArgument, "EncryptTo", "clearData must not be nil")
+	}
+	extended, encryptedData := grow(dst, EncryptedSize(len(clearData), options))
+	args := &nativeArgs{}
+	defer args.release()
+	if err := t.encrypt(awaitWith("EncryptTo"), args, encryptedData, clearData, options); err != nil {
+		return dst, err
+	}
+	return extended, nil
+}
+
+// EncryptedSize returns the size of the data encrypted by Encrypt() from clear data
+// of the given size, according to the padding of the EncryptionOptions.
+func EncryptedSize(clearSize int, options *EncryptionOptions) int {
+	paddingStep := PaddingAuto
 	if options != nil {
-		coptions = convertEncryptionOptions(*options)
-		defer freeCArray(coptions.share_with_users, len(options.ShareWithUsers))
-		defer freeCArray(coptions.share_with_groups, len(options.ShareWithGroups))
+		paddingStep = options.PaddingStep
 	}
-	_, err := await(
-		C.tanker_encrypt(
+	return int(C.tanker_encrypted_size(C.uint64_t(clearSize), C.uint32_t(paddingStep)))
+}
+
+// encrypt encrypts clearData to encryptedData, which has the encrypted size.
+// The native arguments are owned by args.
+func (t *Tanker) encrypt(wait awaitFunc, args *nativeArgs, encryptedData []byte, clearData []byte, options *EncryptionOptions) error {
+	coptions := convertEncryptionOptions(args, options)
+	args.pin(encryptedData, clearData)
+	_, err := wait(func() *C.tanker_future_t {
+		return C.tanker_encrypt(
 			t.instance,
-			(*C.uint8_t)(unsafe.Pointer(&encryptedData[0])),
-			(*C.uint8_t)(cClearData),
+			bufferPointer(encryptedData),
+			bufferPointer(clearData),
 			C.uint64_t(len(clearData)),
 			coptions,
-		),
-	)
+		)
+	})
+	return err
+}
+
+// Decrypt decrypts the pass encrypted resource and return the original clear data.
+func (t *Tanker) Decrypt(encryptedData []byte) ([]byte, error) {
+	return t.DecryptContext(context.Background(), encryptedData)
+}
+
+// DecryptContext is like Decrypt but stops waiting when ctx is done.
+func (t *Tanker) DecryptContext(ctx context.Context, encryptedData []byte) (clearData []byte, err error) {
+	observation := observe(t.observer, "Decrypt", 0, int64(len(encryptedData)))
+	defer func() { observation.end(int64(len(clearData)), err) }()
+	decryptedSize, err := decryptedSize("Decrypt", encryptedData)
 	if err != nil {
 		return nil, err
 	}
-	return encryptedData, nil
+	clearData = make([]byte, decryptedSize)
+	args := &nativeArgs{}
+	defer args.release()
+	args.pin(clearData, encryptedData)
+	n, err := t.decrypt(awaitContextWith(ctx, "Decrypt", args), clearData, encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	return clearData[:n], nil
 }
 
-// Decrypt decrypts the pass encrypted resource and return the original clear data.
-func (t *Tanker) Decrypt(encryptedData []byte) ([]byte, error) {
-	if len(encryptedData) == 0 {
-		return nil, newError(ErrorInvalidArgument, "encryptedData must not be nil")
+// DecryptTo is like Decrypt, but appends the clear data to dst and returns the
+// extended buffer, in the style of append(). dst is only reallocated if its capacity
+// is lower than len(dst)+DecryptedSize(). On error, dst is returned unchanged.
+//
+// To decrypt to a SecretBuffer, pass its empty slice: the clear data is then only
+// written to the SecretBuffer, provided it has at least DecryptedSize() bytes,
+// otherwise it is written to a new slice of the Go heap.
+//
+//	size, err := core.DecryptedSize(encryptedData)
+//	...
+//	secret := core.NewSecretBuffer(size)
+//	defer secret.Destroy()
+//	clearData, err := tanker.DecryptTo(secret.Bytes()[:0], encryptedData)
+//	...
+//	secret.Truncate(len(clearData))
+//
+// DecryptSecret() does the same in a single call.
+func (t *Tanker) DecryptTo(dst []byte, encryptedData []byte) (extended []byte, err error) {
+	observation := observe(t.observer, "DecryptTo", 0, int64(len(encryptedData)))
+	defer func() { observation.end(int64(len(extended)-len(dst)), err) }()
+	return t.decryptTo("DecryptTo", dst, encryptedData)
+}
+
+func (t *Tanker) decryptTo(operation string, dst []byte, encryptedData []byte) ([]byte, error) {
+	decryptedSize, err := decryptedSize(operation, encryptedData)
+	if err != nil {
+		return dst, err
 	}
-	cencrypted := (*C.uint8_t)(unsafe.Pointer(&encryptedData[0]))
-	cdecryptedSize, err := await(C.tanker_decrypted_size(cencrypted, C.uint64_t(len(encryptedData))))
+	extended, clearData := grow(dst, decryptedSize)
+	n, err := t.decrypt(awaitWith(operation), clearData, encryptedData)
 	if err != nil {
+		return dst, err
+	}
+	return extended[:len(dst)+n], nil
+}
+
+// DecryptSecret is like DecryptTo with a SecretBuffer, but allocates the
+// SecretBuffer of the size of the clear data, so that the clear data is never
+// copied to the Go heap and can be wiped from memory. The SecretBuffer must be
+// destroyed once the clear data is no longer needed.
+func (t *Tanker) DecryptSecret(encryptedData []byte) (secret *SecretBuffer, err error) {
+	observation := observe(t.observer, "DecryptSecret", 0, int64(len(encryptedData)))
+	defer func() {
+		var size int
+		if secret != nil {
+			size = secret.Len()
+		}
+		observation.end(int64(size), err)
+	}()
+	decryptedSize, err := decryptedSize("DecryptSecret", encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	secret = NewSecretBuffer(decryptedSize)
+	clearData, err := t.decryptTo("DecryptSecret", secret.Bytes()[:0], encryptedData)
+	if err != nil {
+		secret.Destroy()
 		return nil, err
 	}
-	decryptedSize := uint64((uintptr)(cdecryptedSize))
+	secret.Truncate(len(clearData))
+	return secret, nil
+}
 
-	clearData := make([]byte, decryptedSize)
-	_, err = await(
-		C.tanker_decrypt(
+// DecryptedSize returns the size of the buffer needed to decrypt encryptedData, only
+// the header and the size of encryptedData are read. For resources encrypted with
+// padding, it is an upper bound of the size of the clear data.
+func DecryptedSize(encryptedData []byte) (int, error) {
+	return decryptedSize("DecryptedSize", encryptedData)
+}
+
+func decryptedSize(operation string, encryptedData []byte) (int, error) {
+	if len(encryptedData) == 0 {
+		return 0, newError(ErrorInvalidArgument, operation, "encryptedData must not be nil")
+	}
+	result, err := getExpected(C.tanker_decrypted_size(bufferPointer(encryptedData), C.uint64_t(len(encryptedData))))
+	if err != nil {
+		return 0, withOperation(err, operation)
+	}
+	return int((uintptr)(result)), nil
+}
+
+// decrypt decrypts encryptedData to clearData, which has the decrypted size,
+// and returns the actual size of the clear data.
+func (t *Tanker) decrypt(wait awaitFunc, clearData []byte, encryptedData []byte) (int, error) {
+	result, err := wait(func() *C.tanker_future_t {
+		return C.tanker_decrypt(
 			t.instance,
-			(*C.uint8_t)(unsafe.Pointer(&clearData[0])),
-			(*C.uint8_t)(unsafe.Pointer(&encryptedData[0])),
+			bufferPointer(clearData),
+			bufferPointer(encryptedData),
 			C.uint64_t(len(encryptedData)),
-		),
-	)
+		)
+	})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	return clearData, nil
+	return int((uintptr)(result)), nil
 }
 
 // GetResourceId retrieves an encrypted resource's ID.
 // The resource ID can be pass to a call to Share().
 func (t *Tanker) GetResourceId(encryptedData []byte) (*string, error) {
 	if len(encryptedData) == 0 {
-		return nil, newError(ErrorInvalidArgument, "encryptedData must not be nil")
+		return nil, newError(ErrorInvalidArgument, "GetResourceId", "encryptedData must not be nil")
 	}
 	result, err := await(C.tanker_get_resource_id((*C.uchar)(unsafe.Pointer(&encryptedData[0])), C.uint64_t(len(encryptedData))))
 	if err != nil {
-		return nil, err
+		return nil, withOperation(err, "GetResourceId")
 	}
 	resourceID := unsafeANSIToString(result)
 	return &resourceID, nil
@@ -295,31 +473,67 @@ func (t *Tanker) GetResourceId(encryptedData []byte) (*string, error) {
 // Share shares a list of resource to a list of recipients and/or groups
 // This function either fully succeeds or fails. In case of failure,
 // nothing is share with any recipient or group.
+// It is retried according to TankerOptions.Retry.
 func (t *Tanker) Share(resourceIDs []string, sharingOptions SharingOptions) error {
+	return t.ShareContext(context.Background(), resourceIDs, sharingOptions)
+}
+
+// ShareContext is like Share but stops waiting when ctx is done.
+// The sharing may still be performed after ctx is done.
+func (t *Tanker) ShareContext(ctx context.Context, resourceIDs []string, sharingOptions SharingOptions) error {
+	return t.share(ctx, resourceIDs, sharingOptions, t.retry)
+}
+
+// shareOnce is like ShareContext but ignores TankerOptions.Retry, for the callers
+// which retry the sharing themselves.
+func (t *Tanker) shareOnce(ctx context.Context, resourceIDs []string, sharingOptions SharingOptions) error {
+	return t.share(ctx, resourceIDs, sharingOptions, nil)
+}
+
+func (t *Tanker) share(ctx context.Context, resourceIDs []string, sharingOptions SharingOptions, retry *RetryPolicy) (err error) {
+	observation := observe(t.observer, "Share", len(sharingOptions.ShareWithUsers)+len(sharingOptions.ShareWithGroups), 0)
+	defer func() { observation.end(0, err) }()
 	if len(resourceIDs) == 0 {
 		return fmt.Errorf("ResourceIDs must not be nil nor empty")
 	}
-	cresourceIds := toCArray(resourceIDs)
-	coptions := convertSharingOptions(sharingOptions)
-	defer freeCArray(coptions.share_with_users, len(sharingOptions.ShareWithUsers))
-	defer freeCArray(coptions.share_with_groups, len(sharingOptions.ShareWithGroups))
-	defer freeCArray(cresourceIds, len(resourceIDs))
-
-	_, err := await(
-		C.tanker_share(
-			t.instance,
-			cresourceIds,
-			C.uint64_t(len(resourceIDs)),
-			coptions,
-		),
-	)
-	return err
+	args := &nativeArgs{}
+	defer args.release()
+	cresourceIds := args.cArray(resourceIDs)
+	coptions := convertSharingOptions(args, sharingOptions)
+
+	return doWithRetry(ctx, retry, "Share", func() error {
+		_, err := awaitContext(ctx, "Share", args, func() *C.tanker_future_t {
+			return C.tanker_share(
+				t.instance,
+				cresourceIds,
+				C.uint64_t(len(resourceIDs)),
+				coptions,
+			)
+		}, nil)
+		return err
+	})
 }
 
 // GetDeviceList retrieves the user's device list.
 // The current Tanker status must be StatusReady.
+// It is retried according to TankerOptions.Retry.
 func (t *Tanker) GetDeviceList() (goDevices []DeviceDescription, err error) {
-	cresult, err := await(C.tanker_get_device_list(t.instance))
+	return t.GetDeviceListContext(context.Background())
+}
+
+// GetDeviceListContext is like GetDeviceList but stops waiting when ctx is done.
+func (t *Tanker) GetDeviceListContext(ctx context.Context) (goDevices []DeviceDescription, err error) {
+	observation := observe(t.observer, "GetDeviceList", 0, 0)
+	defer func() { observation.end(0, err) }()
+	var cresult unsafe.Pointer
+	err = doWithRetry(ctx, t.retry, "GetDeviceList", func() (err error) {
+		cresult, err = awaitContext(ctx, "GetDeviceList", nil, func() *C.tanker_future_t {
+			return C.tanker_get_device_list(t.instance)
+		}, func(result unsafe.Pointer) {
+			C.tanker_free_device_list((*C.tanker_device_list_t)(result))
+		})
+		return
+	})
 	if err != nil {
 		return
 	}
@@ -336,34 +550,51 @@ func (t *Tanker) GetDeviceList() (goDevices []DeviceDescription, err error) {
 
 // RevokeDevice revokes one of the user's devices.
 func (t *Tanker) RevokeDevice(deviceID string) (err error) {
-	cdeviceID := C.CString(deviceID)
-	defer C.free(unsafe.Pointer(cdeviceID))
-	_, err = await(C.tanker_revoke_device(t.instance, cdeviceID))
+	return t.RevokeDeviceContext(context.Background(), deviceID)
+}
+
+// RevokeDeviceContext is like RevokeDevice but stops waiting when ctx is done.
+// The device may still be revoked after ctx is done.
+func (t *Tanker) RevokeDeviceContext(ctx context.Context, deviceID string) (err error) {
+	observation := observe(t.observer, "RevokeDevice", 0, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	cdeviceID := args.cString(deviceID)
+	_, err = awaitContext(ctx, "RevokeDevice", args, func() *C.tanker_future_t {
+		return C.tanker_revoke_device(t.instance, cdeviceID)
+	}, nil)
 	return
 }
 
 // Create an encryption session that will allow doing multiple encryption operations with a reduced number of keys.
-func (t *Tanker) CreateEncryptionSession(encryptionOptions *EncryptionOptions) (*EncryptionSession, error) {
-	var coptions *C.tanker_encrypt_options_t = nil
-	if encryptionOptions != nil {
-		coptions = convertEncryptionOptions(*encryptionOptions)
-		defer freeCArray(coptions.share_with_users, len(encryptionOptions.ShareWithUsers))
-		defer freeCArray(coptions.share_with_groups, len(encryptionOptions.ShareWithGroups))
-	} else {
-		coptions = nil
-	}
+func (t *Tanker) CreateEncryptionSession(encryptionOptions *EncryptionOptions) (EncryptionSessionClient, error) {
+	return t.CreateEncryptionSessionContext(context.Background(), encryptionOptions)
+}
+
+// CreateEncryptionSessionContext is like CreateEncryptionSession but stops waiting when ctx is done.
+func (t *Tanker) CreateEncryptionSessionContext(ctx context.Context, encryptionOptions *EncryptionOptions) (_ EncryptionSessionClient, err error) {
+	observation := observe(t.observer, "CreateEncryptionSession", encryptionRecipients(encryptionOptions), 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	coptions := convertEncryptionOptions(args, encryptionOptions)
 
-	csession, err := await(
-		C.tanker_encryption_session_open(
+	csession, err := awaitContext(ctx, "CreateEncryptionSession", args, func() *C.tanker_future_t {
+		return C.tanker_encryption_session_open(
 			t.instance,
 			coptions,
-		),
-	)
+		)
+	}, func(result unsafe.Pointer) {
+		_, _ = await(C.tanker_encryption_session_close((*C.tanker_encryption_session_t)(result)))
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	return &EncryptionSession{
 		instance: (*C.tanker_encryption_session_t)(csession),
+		streams:  t.streams,
+		observer: t.observer,
 	}, nil
 }
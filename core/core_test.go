@@ -1,6 +1,14 @@
 package core_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"runtime"
+	"sync/atomic"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -63,8 +71,32 @@ var _ = Describe("functional", func() {
 			Expect(session.GetStatus()).To(Equal(core.StatusStopped))
 		})
 
+		It("Observes the operations", func() {
+			recorder := &observerRecorder{}
+			session, err := core.NewTanker(core.TankerOptions{AppID: aliceLaptop.AppID, WritablePath: aliceLaptop.Path, Url: &aliceLaptop.Url, Observer: recorder})
+			Expect(err).ToNot(HaveOccurred())
+			defer session.Destroy() // nolint: errcheck
+			_, err = helpers.StartTankerSession(session, aliceLaptop.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			encrypted, err := session.Encrypt(helpers.RandomBytes(1024), nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = session.Decrypt(encrypted[:len(encrypted)-1])
+			Expect(err).To(HaveOccurred())
+
+			events := recorder.Events()
+			Expect(len(events)).To(BeNumerically(">=", 6))
+			encrypt, decrypt := events[len(events)-3], events[len(events)-1]
+			Expect(encrypt.Operation).To(Equal("Encrypt"))
+			Expect(encrypt.InputBytes).To(Equal(int64(1024)))
+			Expect(encrypt.OutputBytes).To(Equal(int64(len(encrypted))))
+			Expect(encrypt.Err).ToNot(HaveOccurred())
+			Expect(decrypt.Operation).To(Equal("Decrypt"))
+			Expect(decrypt.Err).To(HaveOccurred())
+			Expect(decrypt.ErrorCode).ToNot(BeZero())
+		})
+
 		It("Creating a Tanker returns a proper error when it fails", func() {
-			_, err := core.NewTanker(core.TankerOptions{"invalid base 64", "/tmp", &TestApp.Config.URL})
+			_, err := core.NewTanker(core.TankerOptions{AppID: "invalid base 64", WritablePath: "/tmp", Url: &TestApp.Config.URL})
 			Expect(err).To(HaveOccurred())
 			terror, ok := (err).(core.Error)
 			Expect(ok).To(BeTrue())
@@ -187,12 +219,84 @@ var _ = Describe("functional", func() {
 			Expect(decrypted).To(Equal(clearData))
 		})
 
+		It("Encrypts and Decrypts with a context", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			clearData := helpers.RandomBytes(1024)
+			encrypted, err := aliceSession.EncryptContext(ctx, clearData, nil)
+			Expect(err).ToNot(HaveOccurred())
+			decrypted, err := aliceSession.DecryptContext(ctx, encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal(clearData))
+		})
+
+		It("Fails with ErrorOperationCanceled when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := aliceSession.EncryptContext(ctx, helpers.RandomBytes(12), nil)
+			Expect(err).To(HaveOccurred())
+			terror, ok := (err).(core.Error)
+			Expect(ok).To(BeTrue())
+			Expect(terror.Code()).To(Equal(core.ErrorOperationCanceled))
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+			Expect(errors.Is(err, core.ErrOperationCanceled)).To(BeTrue())
+			Expect(terror.Operation()).To(Equal("Encrypt"))
+		})
+
+		It("Keeps the arguments of an abandoned operation until it completes", func() {
+			encryptionOptions := core.NewEncryptionOptions()
+			encryptionOptions.ShareWithUsers = []string{bob.PublicIdentity}
+			clearData := helpers.RandomBytes(1024 * 1024)
+			for i := 0; i < 10; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(time.Duration(i)*time.Millisecond, cancel)
+				_, err := aliceSession.EncryptContext(ctx, clearData, &encryptionOptions)
+				if err != nil {
+					Expect(errors.Is(err, core.ErrOperationCanceled)).To(BeTrue())
+				}
+				cancel()
+				runtime.GC()
+			}
+			encrypted, err := aliceSession.Encrypt(clearData, &encryptionOptions)
+			Expect(err).ToNot(HaveOccurred())
+			decrypted, err := aliceSession.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal(clearData))
+		})
+
+		It("Stops waiting for an operation when the deadline is exceeded", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+			defer cancel()
+			_, err := aliceSession.GetDeviceListContext(ctx)
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		})
+
 		It("Encrypts an empty array", func() {
 			encrypted, err := aliceSession.Encrypt([]byte{}, nil)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(encrypted).ToNot(HaveLen(0))
 		})
 
+		It("Pads the clear data according to the padding step", func() {
+			options := core.NewEncryptionOptions()
+			encryptedSize := func(clearSize int) int {
+				encrypted, err := aliceSession.Encrypt(helpers.RandomBytes(clearSize), &options)
+				Expect(err).ToNot(HaveOccurred())
+				decrypted, err := aliceSession.Decrypt(encrypted)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(decrypted).To(HaveLen(clearSize))
+				return len(encrypted)
+			}
+			options.PaddingStep = core.PaddingOff
+			Expect(encryptedSize(11)).To(Equal(encryptedSize(10) + 1))
+			options.PaddingStep = 500
+			Expect(encryptedSize(10)).To(Equal(encryptedSize(499)))
+			Expect(encryptedSize(500)).To(BeNumerically(">", encryptedSize(499)))
+			options.PaddingStep = core.PaddingAuto
+			Expect(encryptedSize(20)).To(Equal(encryptedSize(21)))
+		})
+
 		It("Fails to encrypt nil", func() {
 			_, err := aliceSession.Encrypt(nil, nil)
 			Expect(err).To(HaveOccurred())
@@ -223,6 +327,86 @@ var _ = Describe("functional", func() {
 			Expect(decrypted).To(Equal(clearData))
 		})
 
+		It("Encrypts and decrypts into buffers", func() {
+			clearData := []byte("data")
+			size := core.EncryptedSize(len(clearData), nil)
+			buffer := make([]byte, 0, size+100)
+			encrypted, err := aliceSession.EncryptTo(buffer, clearData, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encrypted).To(HaveLen(size))
+			Expect(&encrypted[0]).To(BeIdenticalTo(&buffer[:1][0]))
+			Expect(core.DecryptedSize(encrypted)).To(BeNumerically(">=", len(clearData)))
+
+			decrypted, err := aliceSession.DecryptTo([]byte("clear:"), encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("clear:data")))
+			decrypted, err = aliceSession.DecryptTo(decrypted, encrypted[:3])
+			Expect(err).To(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("clear:data")))
+
+			empty, err := aliceSession.EncryptTo(nil, []byte{}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			decrypted, err = aliceSession.DecryptTo(nil, empty)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(BeEmpty())
+		})
+
+		It("Decrypts to secret buffers", func() {
+			encrypted, err := aliceSession.Encrypt([]byte("secret data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			secret, err := aliceSession.DecryptSecret(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			defer secret.Destroy()
+			Expect(secret.Bytes()).To(Equal([]byte("secret data")))
+
+			size, err := core.DecryptedSize(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			buffer := core.NewSecretBuffer(size)
+			defer buffer.Destroy()
+			clearData, err := aliceSession.DecryptTo(buffer.Bytes()[:0], encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			// The clear data is written in the memory of the SecretBuffer
+			Expect(&clearData[0]).To(BeIdenticalTo(&buffer.Bytes()[0]))
+			buffer.Truncate(len(clearData))
+			Expect(buffer.Bytes()).To(Equal([]byte("secret data")))
+		})
+
+		It("Gets the resource ID from a reader", func() {
+			small, err := aliceSession.Encrypt([]byte("data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			stream, err := aliceSession.StreamEncrypt(bytes.NewReader(helpers.RandomBytes(1024*1024*3)), nil)
+			Expect(err).ToNot(HaveOccurred())
+			large, err := ioutil.ReadAll(stream)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stream.Close()).To(Succeed())
+			session, err := aliceSession.CreateEncryptionSession(nil)
+			Expect(err).ToNot(HaveOccurred())
+			defer session.Destroy()
+			sessionEncrypted, err := session.Encrypt([]byte("data"))
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, encrypted := range [][]byte{small, large, sessionEncrypted} {
+				expected, err := aliceSession.GetResourceId(encrypted)
+				Expect(err).ToNot(HaveOccurred())
+				source := bytes.NewReader(encrypted)
+				resourceID, replay, err := aliceSession.GetResourceIDFromReader(source)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resourceID).To(Equal(expected))
+				Expect(ioutil.ReadAll(replay)).To(Equal(encrypted))
+			}
+			source := bytes.NewReader(large)
+			_, _, err = aliceSession.GetResourceIDFromReader(source)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(source.Len()).To(BeNumerically(">", len(large)-100))
+
+			_, _, err = aliceSession.GetResourceIDFromReader(bytes.NewReader(large[:10]))
+			Expect(errors.Is(err, core.ErrInvalidArgument)).To(BeTrue())
+			_, _, err = aliceSession.GetResourceIDFromReader(bytes.NewReader(small[:10]))
+			Expect(errors.Is(err, core.ErrInvalidArgument)).To(BeTrue())
+			_, _, err = aliceSession.GetResourceIDFromReader(bytes.NewReader(append([]byte{42}, small[1:]...)))
+			Expect(errors.Is(err, core.ErrInvalidArgument)).To(BeTrue())
+		})
+
 		It("Claims the same provisional Identity twice", func() {
 			bobEmail := "bob.test@tanker.io"
 			bobProvisional, err := identity.CreateProvisional(TestApp.IdConfig, bobEmail)
@@ -242,7 +426,7 @@ var _ = Describe("functional", func() {
 			Expect(attachResult.Status).To(Equal(core.StatusIdentityVerificationNeeded))
 			code, err := TestApp.GetVerificationCode(bobEmail)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(bobSession.VerifyProvisionalIdentity(core.EmailVerification{bobEmail, *code})).To(Succeed())
+			Expect(bobSession.VerifyProvisionalIdentity(core.EmailVerification{Email: bobEmail, VerificationCode: *code})).To(Succeed())
 
 			attachResult2, err := bobSession.AttachProvisionalIdentity(*bobProvisional)
 			Expect(err).ToNot(HaveOccurred())
@@ -268,9 +452,9 @@ var _ = Describe("functional", func() {
 			devices, err := bobSession.GetDeviceList()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(devices).To(ConsistOf(
-				core.DeviceDescription{*deviceID1, true},
-				core.DeviceDescription{*deviceID2, false},
-				core.DeviceDescription{*bobLaptopID, false},
+				core.DeviceDescription{DeviceID: *deviceID1, IsRevoked: true},
+				core.DeviceDescription{DeviceID: *deviceID2, IsRevoked: false},
+				core.DeviceDescription{DeviceID: *bobLaptopID, IsRevoked: false},
 			))
 		})
 
@@ -293,5 +477,45 @@ var _ = Describe("functional", func() {
 			Expect(ok).To(BeTrue())
 			Expect(terror.Code()).To(Equal(core.ErrorDeviceRevoked))
 		})
+
+		It("Calls every event handler when revoked", func() {
+			bobSession, _ := bobLaptop.Start()
+			defer bobSession.Stop() // nolint: errCheck
+
+			device1, _ := bob.CreateDevice()
+			session1, _ := device1.Start()
+			defer session1.Stop() // nolint: errCheck
+			events := session1.Events()
+			revoked := make(chan bool, 2)
+			_, err := session1.RegisterEventHandler(core.EventDeviceRevoked, func() { revoked <- true })
+			Expect(err).ToNot(HaveOccurred())
+			unregister, err := session1.RegisterEventHandler(core.EventDeviceRevoked, func() { revoked <- true })
+			Expect(err).ToNot(HaveOccurred())
+			var unregisteredCalls int32
+			unregistered, err := session1.RegisterEventHandler(core.EventDeviceRevoked, func() { atomic.AddInt32(&unregisteredCalls, 1) })
+			Expect(err).ToNot(HaveOccurred())
+			unregistered()
+			defer unregister()
+
+			deviceID1, _ := session1.GetDeviceID()
+			Expect(bobSession.RevokeDevice(*deviceID1)).To(Succeed())
+			encrypted, err := bobSession.Encrypt(helpers.RandomBytes(12), nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = session1.Decrypt(encrypted)
+			Expect(err).To(HaveOccurred())
+
+			Eventually(revoked).Should(Receive())
+			Eventually(revoked).Should(Receive())
+			Eventually(events).Should(Receive(Equal(core.EventDeviceRevoked)))
+			Consistently(func() int32 { return atomic.LoadInt32(&unregisteredCalls) }).Should(BeZero())
+		})
+
+		It("Refuses to register a handler for an unknown event", func() {
+			_, err := aliceSession.RegisterEventHandler(core.EventType(42), func() {})
+			Expect(err).To(HaveOccurred())
+			terror, ok := (err).(core.Error)
+			Expect(ok).To(BeTrue())
+			Expect(terror.Code()).To(Equal(core.ErrorInvalidArgument))
+		})
 	})
 })
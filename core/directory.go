@@ -0,0 +1,389 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ManifestFileName is the name of the manifest written at the root of an encrypted directory.
+const ManifestFileName = ".tanker-manifest"
+
+const manifestVersion = 1
+
+// DirectoryOptions configures EncryptDirectory() and DecryptDirectory().
+type DirectoryOptions struct {
+	// Include lists the path.Match() patterns of the files to process, all the
+	// files are processed if it is empty. Patterns are matched against the
+	// slash-separated path relative to the root, and against the file name.
+	Include []string
+	// Exclude lists the patterns of the files and directories to skip, it
+	// takes precedence over Include.
+	Exclude []string
+	// Workers is the number of files processed concurrently, zero means runtime.NumCPU().
+	Workers int
+	// Session, if set, encrypts all the files with the encryption session,
+	// EncryptionOptions is then ignored.
+	Session EncryptionSessionClient
+	// EncryptionOptions is used to encrypt each file with its own key.
+	EncryptionOptions *EncryptionOptions
+}
+
+// Manifest describes the files of an encrypted directory.
+type Manifest struct {
+	Version int             `json:"version"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes an encrypted file.
+type ManifestEntry struct {
+	// Path is the slash-separated path of the file, relative to the root.
+	Path       string `json:"path"`
+	ResourceID string `json:"resource_id"`
+	// Size and SHA256 are the size and hex-encoded checksum of the clear data.
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+func (o *DirectoryOptions) workers() int {
+	if o == nil || o.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return o.Workers
+}
+
+func (o *DirectoryOptions) validate(operation string) error {
+	if o == nil {
+		return nil
+	}
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return newError(ErrorInvalidArgument, operation, "invalid pattern: "+pattern)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, relPath); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(relPath)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether a file or directory is excluded.
+func (o *DirectoryOptions) excluded(relPath string) bool {
+	return o != nil && matchAny(o.Exclude, relPath)
+}
+
+// selected reports whether a file is to be processed.
+func (o *DirectoryOptions) selected(relPath string) bool {
+	if o == nil {
+		return true
+	}
+	return !o.excluded(relPath) && (len(o.Include) == 0 || matchAny(o.Include, relPath))
+}
+
+// digest computes the size and checksum of the data written to it.
+type digest struct {
+	hash hash.Hash
+	size int64
+}
+
+func newDigest() *digest {
+	return &digest{hash: sha256.New()}
+}
+
+func (d *digest) Write(data []byte) (int, error) {
+	d.size += int64(len(data))
+	return d.hash.Write(data)
+}
+
+func (d *digest) sum() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// EncryptDirectory encrypts the files of the directory tree at path src to a mirrored
+// tree at path dst. File and directory names are kept as is. Each file is encrypted with
+// its own key and shared according to options.EncryptionOptions, or with options.Session.
+//
+// The returned Manifest lists the resource IDs of the files, and the sizes and checksums
+// of their clear data. It is also written encrypted to dst/ManifestFileName, so that
+// DecryptDirectory() can check the decrypted files. Nil options encrypt every file with
+// default EncryptionOptions.
+func EncryptDirectory(t Client, src string, dst string, options *DirectoryOptions) (*Manifest, error) {
+	return EncryptDirectoryContext(context.Background(), t, src, dst, options)
+}
+
+// EncryptDirectoryContext is like EncryptDirectory but stops processing files when ctx is done.
+func EncryptDirectoryContext(ctx context.Context, t Client, src string, dst string, options *DirectoryOptions) (*Manifest, error) {
+	if err := options.validate("EncryptDirectory"); err != nil {
+		return nil, err
+	}
+	open := func(ctx context.Context) func(io.Reader) (Stream, error) {
+		return func(reader io.Reader) (Stream, error) {
+			if options != nil && options.Session != nil {
+				return options.Session.StreamEncryptContext(ctx, reader)
+			}
+			var encryptionOptions *EncryptionOptions
+			if options != nil {
+				encryptionOptions = options.EncryptionOptions
+			}
+			return t.StreamEncryptContext(ctx, reader, encryptionOptions)
+		}
+	}
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return nil, err
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Version: manifestVersion}
+	err = filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			if relPath == "." {
+				return os.Chmod(dst, info.Mode().Perm())
+			}
+			// Do not encrypt the output when it is inside src
+			if options.excluded(relPath) || os.SameFile(info, dstInfo) {
+				return filepath.SkipDir
+			}
+			return mkdir(filepath.Join(dst, filepath.FromSlash(relPath)), info.Mode().Perm())
+		}
+		if info.Mode().IsRegular() && relPath != ManifestFileName && options.selected(relPath) {
+			manifest.Files = append(manifest.Files, ManifestEntry{Path: relPath})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = runFiles(ctx, "EncryptDirectory", options.workers(), manifest.Files, func(ctx context.Context, entry *ManifestEntry) error {
+		source, err := os.Open(filepath.Join(src, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return err
+		}
+		defer source.Close() // nolint: errcheck
+		info, err := source.Stat()
+		if err != nil {
+			return err
+		}
+		checksum := newDigest()
+		resourceID, err := writeStream(filepath.Join(dst, filepath.FromSlash(entry.Path)), info.Mode().Perm(), io.TeeReader(source, checksum), open(ctx), nil, nil)
+		if err != nil {
+			return err
+		}
+		entry.ResourceID, entry.Size, entry.SHA256 = *resourceID, checksum.size, checksum.sum()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writeStream(filepath.Join(dst, ManifestFileName), 0600, bytes.NewReader(data), open(ctx), nil, nil); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// DecryptDirectory decrypts the files listed in the manifest of the encrypted directory
+// tree at path src to a mirrored tree at path dst. The size and checksum of each file are
+// checked against the manifest, a mismatch fails with ErrorDecryptionFailed. The returned
+// Manifest lists the decrypted files. Only options.Include, options.Exclude and
+// options.Workers are used.
+func DecryptDirectory(t Client, src string, dst string, options *DirectoryOptions) (*Manifest, error) {
+	return DecryptDirectoryContext(context.Background(), t, src, dst, options)
+}
+
+// DecryptDirectoryContext is like DecryptDirectory but stops processing files when ctx is done.
+func DecryptDirectoryContext(ctx context.Context, t Client, src string, dst string, options *DirectoryOptions) (*Manifest, error) {
+	if err := options.validate("DecryptDirectory"); err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(ctx, t, filepath.Join(src, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	selected := &Manifest{Version: manifest.Version}
+	for _, entry := range manifest.Files {
+		if !isLocalPath(entry.Path) {
+			return nil, newError(ErrorDecryptionFailed, "DecryptDirectory", "invalid path in manifest: "+entry.Path)
+		}
+		if options.selected(entry.Path) {
+			selected.Files = append(selected.Files, entry)
+		}
+	}
+
+	if err = os.MkdirAll(dst, 0700); err != nil {
+		return nil, err
+	}
+	err = filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." {
+			return os.Chmod(dst, info.Mode().Perm())
+		}
+		if options.excluded(relPath) {
+			return filepath.SkipDir
+		}
+		return mkdir(filepath.Join(dst, filepath.FromSlash(relPath)), info.Mode().Perm())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = runFiles(ctx, "DecryptDirectory", options.workers(), selected.Files, func(ctx context.Context, entry *ManifestEntry) error {
+		source, err := os.Open(filepath.Join(src, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return err
+		}
+		defer source.Close() // nolint: errcheck
+		info, err := source.Stat()
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, filepath.FromSlash(entry.Path))
+		if err = os.MkdirAll(filepath.Dir(dstPath), 0700); err != nil {
+			return err
+		}
+		checksum := newDigest()
+		_, err = writeStream(dstPath, info.Mode().Perm(), source, func(reader io.Reader) (Stream, error) {
+			return t.StreamDecryptContext(ctx, reader)
+		}, checksum, func() error {
+			if checksum.size != entry.Size || checksum.sum() != entry.SHA256 {
+				return newError(ErrorDecryptionFailed, "DecryptDirectory", "checksum mismatch for "+entry.Path)
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return selected, nil
+}
+
+func readManifest(ctx context.Context, t Client, file string) (*Manifest, error) {
+	source, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close() // nolint: errcheck
+	stream, err := t.StreamDecryptContext(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close() // nolint: errcheck
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, newError(ErrorDecryptionFailed, "DecryptDirectory", "invalid manifest: "+err.Error())
+	}
+	if manifest.Version != manifestVersion {
+		return nil, newError(ErrorInvalidArgument, "DecryptDirectory", "unsupported manifest version")
+	}
+	return manifest, nil
+}
+
+// isLocalPath reports whether relPath is a clean relative path which stays below the root.
+func isLocalPath(relPath string) bool {
+	if relPath == "" || path.Clean(relPath) != relPath || path.IsAbs(relPath) {
+		return false
+	}
+	if relPath == "." || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return false
+	}
+	return filepath.VolumeName(filepath.FromSlash(relPath)) == ""
+}
+
+// mkdir creates the directory dir if needed, and gives it the permissions perm.
+func mkdir(dir string, perm os.FileMode) error {
+	if err := os.Mkdir(dir, perm); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.Chmod(dir, perm)
+}
+
+// runFiles runs task on each entry with the given number of workers. It stops at
+// the first error, or when ctx is done, and returns the error.
+func runFiles(ctx context.Context, operation string, workers int, entries []ManifestEntry, task func(context.Context, *ManifestEntry) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if err := task(ctx, &entries[index]); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for index := range entries {
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return newCanceledError(operation, err)
+	}
+	return nil
+}
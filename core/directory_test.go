@@ -0,0 +1,155 @@
+package core_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+	"github.com/TankerHQ/sdk-go/v2/helpers"
+)
+
+var _ = Describe("Directories", func() {
+
+	var (
+		alice        helpers.User
+		aliceLaptop  *helpers.Device
+		aliceSession *core.Tanker
+		dir          string
+		src          string
+		encrypted    string
+		decrypted    string
+	)
+
+	BeforeEach(func() {
+		alice = TestApp.CreateUser()
+		aliceLaptop, _ = alice.CreateDevice()
+		aliceSession, _ = aliceLaptop.Start()
+		var err error
+		dir, err = ioutil.TempDir("", "core-directories-")
+		Expect(err).ToNot(HaveOccurred())
+		src, encrypted, decrypted = filepath.Join(dir, "src"), filepath.Join(dir, "encrypted"), filepath.Join(dir, "decrypted")
+		files := map[string]string{"a.txt": "a", "docs/b.txt": "bb", "docs/c.log": "ccc", "tmp/d.txt": "dddd"}
+		for name, content := range files {
+			Expect(os.MkdirAll(filepath.Join(src, filepath.Dir(name)), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(src, name), []byte(content), 0600)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		Expect(aliceSession.Stop()).To(Succeed())
+		os.RemoveAll(dir) // nolint: errcheck
+	})
+
+	It("Encrypts and decrypts directories", func() {
+		manifest, err := core.EncryptDirectory(aliceSession, src, encrypted, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest.Files).To(HaveLen(4))
+		for _, entry := range manifest.Files {
+			data, err := ioutil.ReadFile(filepath.Join(encrypted, filepath.FromSlash(entry.Path)))
+			Expect(err).ToNot(HaveOccurred())
+			resourceID, err := aliceSession.GetResourceId(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*resourceID).To(Equal(entry.ResourceID))
+		}
+		Expect(filepath.Join(encrypted, core.ManifestFileName)).To(BeAnExistingFile())
+
+		restored, err := core.DecryptDirectory(aliceSession, encrypted, decrypted, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored.Files).To(Equal(manifest.Files))
+		Expect(ioutil.ReadFile(filepath.Join(decrypted, "docs", "c.log"))).To(Equal([]byte("ccc")))
+		Expect(ioutil.ReadFile(filepath.Join(decrypted, "tmp", "d.txt"))).To(Equal([]byte("dddd")))
+		Expect(filepath.Join(decrypted, core.ManifestFileName)).ToNot(BeAnExistingFile())
+	})
+
+	It("Filters the files and encrypts them with an encryption session", func() {
+		session, err := aliceSession.CreateEncryptionSession(nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer session.Destroy()
+
+		manifest, err := core.EncryptDirectory(aliceSession, src, encrypted, &core.DirectoryOptions{
+			Exclude: []string{"tmp", "*.log"},
+			Workers: 2,
+			Session: session,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest.Files).To(HaveLen(2))
+		Expect(manifest.Files[0].Path).To(Equal("a.txt"))
+		Expect(manifest.Files[1].Path).To(Equal("docs/b.txt"))
+		Expect(manifest.Files[1].Size).To(Equal(int64(2)))
+		Expect(manifest.Files[1].ResourceID).To(Equal(session.GetResourceId()))
+		Expect(filepath.Join(encrypted, "tmp")).ToNot(BeADirectory())
+
+		restored, err := core.DecryptDirectory(aliceSession, encrypted, decrypted, &core.DirectoryOptions{Include: []string{"docs/*"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored.Files).To(Equal(manifest.Files[1:]))
+		Expect(ioutil.ReadFile(filepath.Join(decrypted, "docs", "b.txt"))).To(Equal([]byte("bb")))
+		Expect(filepath.Join(decrypted, "a.txt")).ToNot(BeAnExistingFile())
+	})
+
+	It("Keeps the permissions of the files and directories", func() {
+		Expect(os.Chmod(filepath.Join(src, "docs", "b.txt"), 0640)).To(Succeed())
+		Expect(os.Chmod(filepath.Join(src, "docs"), 0750)).To(Succeed())
+
+		_, err := core.EncryptDirectory(aliceSession, src, encrypted, nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = core.DecryptDirectory(aliceSession, encrypted, decrypted, nil)
+		Expect(err).ToNot(HaveOccurred())
+		for _, root := range []string{encrypted, decrypted} {
+			info, err := os.Stat(filepath.Join(root, "docs", "b.txt"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)))
+			info, err = os.Stat(filepath.Join(root, "docs"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0750)))
+		}
+	})
+
+	It("Does not encrypt the destination when it is inside the source", func() {
+		inside := filepath.Join(src, "encrypted")
+		manifest, err := core.EncryptDirectory(aliceSession, src, inside, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest.Files).To(HaveLen(4))
+		Expect(filepath.Join(inside, "encrypted")).ToNot(BeADirectory())
+	})
+
+	It("Fails with a checksum mismatch and leaves the decrypted file untouched", func() {
+		_, err := core.EncryptDirectory(aliceSession, src, encrypted, nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = core.DecryptDirectory(aliceSession, encrypted, decrypted, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		// A file swapped with another one fails the checksum
+		swapped, err := ioutil.ReadFile(filepath.Join(encrypted, "a.txt"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(encrypted, "docs", "b.txt"), swapped, 0600)).To(Succeed())
+		_, err = core.DecryptDirectory(aliceSession, encrypted, decrypted, nil)
+		expectErrorCode(err, core.ErrorDecryptionFailed)
+		Expect(ioutil.ReadFile(filepath.Join(decrypted, "docs", "b.txt"))).To(Equal([]byte("bb")))
+		expectFiles(filepath.Join(decrypted, "docs"), "b.txt", "c.log")
+	})
+
+	It("Rejects the manifests with paths outside of the root", func() {
+		manifest, err := json.Marshal(core.Manifest{Version: 1, Files: []core.ManifestEntry{{Path: "../escaped.txt"}}})
+		Expect(err).ToNot(HaveOccurred())
+		encryptedManifest, err := aliceSession.Encrypt(manifest, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.MkdirAll(encrypted, 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(encrypted, core.ManifestFileName), encryptedManifest, 0600)).To(Succeed())
+
+		_, err = core.DecryptDirectory(aliceSession, encrypted, decrypted, nil)
+		expectErrorCode(err, core.ErrorDecryptionFailed)
+		Expect(filepath.Join(dir, "escaped.txt")).ToNot(BeAnExistingFile())
+	})
+
+	It("Rejects invalid patterns", func() {
+		_, err := core.EncryptDirectory(aliceSession, src, encrypted, &core.DirectoryOptions{Include: []string{"["}})
+		expectErrorCode(err, core.ErrorInvalidArgument)
+		_, err = core.DecryptDirectory(aliceSession, encrypted, decrypted, &core.DirectoryOptions{Exclude: []string{"["}})
+		expectErrorCode(err, core.ErrorInvalidArgument)
+	})
+})
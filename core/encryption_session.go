@@ -5,13 +5,13 @@ package core
 #include <ctanker.h>
 */
 import "C"
-import (
-	"unsafe"
-)
+import "context"
 
 // Represents an EncryptionSession instance.
 type EncryptionSession struct {
 	instance *C.tanker_encryption_session_t
+	streams  StreamOptions
+	observer Observer
 }
 
 // Destroy destroys the session, internal resource cleanup is performed
@@ -29,27 +29,31 @@ func (s *EncryptionSession) GetResourceId() string {
 
 // Encrypts the passed []byte with the session and returns the result.
 func (s *EncryptionSession) Encrypt(clearData []byte) ([]byte, error) {
+	return s.EncryptContext(context.Background(), clearData)
+}
+
+// EncryptContext is like Encrypt but stops waiting when ctx is done.
+func (s *EncryptionSession) EncryptContext(ctx context.Context, clearData []byte) (encryptedData []byte, err error) {
+	observation := observe(s.observer, "EncryptionSession.Encrypt", 0, int64(len(clearData)))
+	defer func() { observation.end(int64(len(encryptedData)), err) }()
 	if clearData == nil {
-		return nil, newError(ErrorInvalidArgument, "clearData must not be nil")
-	}
-	var cClearData unsafe.Pointer
-	if len(clearData) == 0 {
-		cClearData = C.CBytes(clearData)
-	} else {
-		cClearData = unsafe.Pointer(&clearData[0])
+		return nil, newError(ErrorInvalidArgument, "Encrypt", "clearData must not be nil")
 	}
-	encryptedSize := C.tanker_encryption_session_encrypted_size(C.uint64_t(len(clearData)))
+	encryptedSize := C.tanker_encryption_session_encrypted_size(s.instance, C.uint64_t(len(clearData)))
 
-	encryptedData := make([]byte, encryptedSize)
+	encryptedData = make([]byte, encryptedSize)
 
-	_, err := await(
-		C.tanker_encryption_session_encrypt(
+	args := &nativeArgs{}
+	defer args.release()
+	args.pin(encryptedData, clearData)
+	_, err = awaitContext(ctx, "Encrypt", args, func() *C.tanker_future_t {
+		return C.tanker_encryption_session_encrypt(
 			s.instance,
-			(*C.uint8_t)(unsafe.Pointer(&encryptedData[0])),
-			(*C.uint8_t)(cClearData),
+			bufferPointer(encryptedData),
+			bufferPointer(clearData),
 			C.uint64_t(len(clearData)),
-		),
-	)
+		)
+	}, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +42,21 @@ var _ = Describe("Encryption sessions", func() {
 		Expect(sessId).To(Equal(*cipherId))
 	})
 
+	It("Pads the data encrypted with an encryption session", func() {
+		aliceSession, _ := aliceLaptop.Start()
+		defer aliceSession.Stop() // nolint: errcheck
+		options := core.NewEncryptionOptions()
+		options.PaddingStep = 100
+		encSess, err := aliceSession.CreateEncryptionSession(&options)
+		Expect(err).ToNot(HaveOccurred())
+		short, err := encSess.Encrypt([]byte("a"))
+		Expect(err).ToNot(HaveOccurred())
+		long, err := encSess.Encrypt(helpers.RandomBytes(99))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(short).To(HaveLen(len(long)))
+		Expect(aliceSession.Decrypt(short)).To(Equal([]byte("a")))
+	})
+
 	It("Can share with a user using an encryption session", func() {
 		msg := []byte("New Carollton Amtrak Station")
 		aliceSession, _ := aliceLaptop.Start()
@@ -1,49 +1,102 @@
 package core
 
+import "github.com/TankerHQ/sdk-go/v2/coretypes"
+
 // ErrorCode represents a Tanker error code.
-type ErrorCode uint32
+//
+// ErrorCode implements error so that each code can be compared against with
+// errors.Is(), see the Err* sentinel errors.
+type ErrorCode = coretypes.ErrorCode
 
 const (
-	ErrorInvalidArgument ErrorCode = iota + 1
-	ErrorInternalError
-	ErrorNetworkError
-	ErrorPreconditionFailed
-	ErrorOperationCanceled
+	ErrorInvalidArgument     = coretypes.ErrorInvalidArgument
+	ErrorInternalError       = coretypes.ErrorInternalError
+	ErrorNetworkError        = coretypes.ErrorNetworkError
+	ErrorPreconditionFailed  = coretypes.ErrorPreconditionFailed
+	ErrorOperationCanceled   = coretypes.ErrorOperationCanceled
+	ErrorDecryptionFailed    = coretypes.ErrorDecryptionFailed
+	ErrorGroupTooBig         = coretypes.ErrorGroupTooBig
+	ErrorInvalidVerification = coretypes.ErrorInvalidVerification
+	ErrorTooManyAttempts     = coretypes.ErrorTooManyAttempts
+	ErrorExpiredVerification = coretypes.ErrorExpiredVerification
+	ErrorIoError             = coretypes.ErrorIoError
+	ErrorDeviceRevoked       = coretypes.ErrorDeviceRevoked
+	ErrorConflict            = coretypes.ErrorConflict
+	ErrorUpgradeRequired     = coretypes.ErrorUpgradeRequired
+)
 
-	ErrorDecryptionFailed
+// Sentinel errors, one per ErrorCode. Every error returned by Tanker
+// functions matches the sentinel of its code:
+//
+//	if errors.Is(err, core.ErrDecryptionFailed) {
+//		...
+//	}
+var (
+	ErrInvalidArgument     = coretypes.ErrInvalidArgument
+	ErrInternalError       = coretypes.ErrInternalError
+	ErrNetworkError        = coretypes.ErrNetworkError
+	ErrPreconditionFailed  = coretypes.ErrPreconditionFailed
+	ErrOperationCanceled   = coretypes.ErrOperationCanceled
+	ErrDecryptionFailed    = coretypes.ErrDecryptionFailed
+	ErrGroupTooBig         = coretypes.ErrGroupTooBig
+	ErrInvalidVerification = coretypes.ErrInvalidVerification
+	ErrTooManyAttempts     = coretypes.ErrTooManyAttempts
+	ErrExpiredVerification = coretypes.ErrExpiredVerification
+	ErrIoError             = coretypes.ErrIoError
+	ErrDeviceRevoked       = coretypes.ErrDeviceRevoked
+	ErrConflict            = coretypes.ErrConflict
+	ErrUpgradeRequired     = coretypes.ErrUpgradeRequired
+)
 
-	ErrorGroupTooBig
+// Error is the Tanker error interface. Cast the error returned by
+// Tanker functions to this interface to get more informations, or use
+// errors.As() when the error may have been wrapped.
+type Error = coretypes.Error
 
-	ErrorInvalidVerification
-	ErrorTooManyAttempts
-	ErrorExpiredVerification
-	ErrorIoError
-	ErrorDeviceRevoked
+// IsRetryable reports whether err, or one of the errors it wraps, is a
+// Tanker error with a retryable code. See ErrorCode.Retryable().
+func IsRetryable(err error) bool {
+	return coretypes.IsRetryable(err)
+}
 
-	ErrorConflict
-	ErrorUpgradeRequired
-)
+// NewError creates a Tanker error. Tanker functions create their own errors,
+// this is meant for fakes, such as the ones of the coremock package, which
+// need to return realistic errors.
+func NewError(code ErrorCode, operation string, message string) Error {
+	return coretypes.NewError(code, operation, message)
+}
 
-// Error is the Tanker error interface. Cast the error returned by
-// Tanker functions to this interface to get more informations.
-type Error interface {
-	error
-	Code() ErrorCode
+// GroupTooBigError is the Error returned with ErrorGroupTooBig by the group
+// functions, it carries the size which exceeded the limit:
+//
+//	var tooBig *core.GroupTooBigError
+//	if errors.As(err, &tooBig) {
+//		log.Printf("%d members are too many for a single request", tooBig.Size)
+//	}
+type GroupTooBigError = coretypes.GroupTooBigError
+
+// NewGroupTooBigError creates an ErrorGroupTooBig error. Like NewError, it is
+// meant for fakes.
+func NewGroupTooBigError(operation string, message string, size int, maxSize int) *GroupTooBigError {
+	return coretypes.NewGroupTooBigError(operation, message, size, maxSize)
+}
+
+func newError(code ErrorCode, operation string, message string) error {
+	return coretypes.NewError(code, operation, message)
 }
 
-type tankerError struct {
-	code    ErrorCode
-	message string
+func newCanceledError(operation string, cause error) error {
+	return coretypes.NewCanceledError(operation, cause)
 }
 
-func newError(code ErrorCode, message string) error {
-	return &tankerError{code, message}
+func withOperation(err error, operation string) error {
+	return coretypes.WithOperation(err, operation)
 }
 
-func (e tankerError) Error() string {
-	return e.message
+func withAttempts(err error, attempts int) error {
+	return coretypes.WithAttempts(err, attempts)
 }
 
-func (e tankerError) Code() ErrorCode {
-	return e.code
+func withGroupSize(err error, size int) error {
+	return coretypes.WithGroupSize(err, size)
 }
@@ -0,0 +1,59 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+)
+
+// expectErrorCode checks that err is a core.Error of the given code.
+func expectErrorCode(err error, code core.ErrorCode) {
+	ExpectWithOffset(1, err).To(HaveOccurred())
+	terror, ok := err.(core.Error)
+	ExpectWithOffset(1, ok).To(BeTrue())
+	ExpectWithOffset(1, terror.Code()).To(Equal(code))
+}
+
+var _ = Describe("Errors", func() {
+	It("Names the error codes", func() {
+		Expect(core.ErrorDecryptionFailed.String()).To(Equal("DecryptionFailed"))
+		Expect(core.ErrorCode(42).String()).To(Equal("ErrorCode(42)"))
+		Expect(fmt.Sprint(core.ErrorNetworkError)).To(Equal("tanker: NetworkError"))
+	})
+
+	It("Matches the sentinel of its code, even when wrapped", func() {
+		err := fmt.Errorf("decrypting invoice: %w", core.NewError(core.ErrorDecryptionFailed, "Decrypt", "corrupted data"))
+		Expect(errors.Is(err, core.ErrDecryptionFailed)).To(BeTrue())
+		Expect(errors.Is(err, core.ErrInvalidArgument)).To(BeFalse())
+
+		var terror core.Error
+		Expect(errors.As(err, &terror)).To(BeTrue())
+		Expect(terror.Code()).To(Equal(core.ErrorDecryptionFailed))
+		Expect(terror.Operation()).To(Equal("Decrypt"))
+		Expect(terror.Message()).To(Equal("corrupted data"))
+		Expect(terror.Error()).To(Equal("Decrypt: corrupted data"))
+	})
+
+	It("Tells retryable errors from permanent ones", func() {
+		Expect(core.IsRetryable(core.NewError(core.ErrorNetworkError, "Share", "timeout"))).To(BeTrue())
+		Expect(core.IsRetryable(core.NewError(core.ErrorConflict, "CreateGroup", "conflict"))).To(BeTrue())
+		Expect(core.IsRetryable(core.NewError(core.ErrorInvalidArgument, "Share", "invalid"))).To(BeFalse())
+		Expect(core.IsRetryable(errors.New("not a tanker error"))).To(BeFalse())
+		Expect(core.IsRetryable(nil)).To(BeFalse())
+	})
+
+	It("Reports the size of the groups too big but not the limit of the server", func() {
+		err := core.WithGroupSize(core.NewError(core.ErrorGroupTooBig, "CreateGroup", "cannot create a group with 1200 members, the maximum is 1000"), 1200)
+		var tooBig *core.GroupTooBigError
+		Expect(errors.As(err, &tooBig)).To(BeTrue())
+		Expect(tooBig.Size).To(Equal(1200))
+		Expect(tooBig.MaxSize).To(BeZero())
+
+		err = core.WithGroupSize(core.NewError(core.ErrorInvalidArgument, "CreateGroup", "invalid identity"), 1200)
+		Expect(errors.As(err, &tooBig)).To(BeFalse())
+	})
+})
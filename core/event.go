@@ -0,0 +1,233 @@
+package core
+
+/*
+#include <ctanker.h>
+
+void gotanker_event_proxy(void *arg, void *data);
+
+static tanker_expected_t *gotanker_event_connect(tanker_t *ctanker, enum tanker_event event, void *data) {
+	return tanker_event_connect(ctanker, event, gotanker_event_proxy, data);
+}
+*/
+import "C"
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	gopointer "github.com/mattn/go-pointer"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// EventHandler defines the function object type used by RegisterEventHandler().
+type EventHandler = coretypes.EventHandler
+
+// EventType represents the type of event one can register and be notified of.
+type EventType = coretypes.EventType
+
+const (
+	EventSessionClosed = coretypes.EventSessionClosed
+	EventDeviceRevoked = coretypes.EventDeviceRevoked
+)
+
+// The events must match the ones of the native library
+var (
+	_ = [1]struct{}{}[EventSessionClosed-C.TANKER_EVENT_SESSION_CLOSED]
+	_ = [1]struct{}{}[EventDeviceRevoked-C.TANKER_EVENT_DEVICE_REVOKED]
+)
+
+// eventSubscriberBufferSize is the capacity of the channels returned by Events().
+const eventSubscriberBufferSize = 8
+
+// eventQueueSize is the capacity of the queue of the native events waiting to
+// be dispatched, the events emitted while it is full are dropped.
+const eventQueueSize = 16
+
+// eventDispatcher fans out the native events of a Tanker instance
+// to every registered handler and subscriber channel. The events are
+// queued by the native thread and dispatched in order by a single goroutine.
+type eventDispatcher struct {
+	// dropped is accessed atomically, it comes first to be 64-bit aligned
+	dropped uint64
+	queue   chan EventType
+
+	mutex       sync.Mutex
+	nextID      uint64
+	handlers    map[EventType]map[uint64]EventHandler
+	subscribers []chan EventType
+	closed      bool
+	// native callback user data, one per connected event
+	connections []unsafe.Pointer
+}
+
+// eventConnection is the user data passed to the native event callback.
+type eventConnection struct {
+	dispatcher *eventDispatcher
+	event      EventType
+}
+
+func newEventDispatcher() *eventDispatcher {
+	d := &eventDispatcher{
+		queue:    make(chan EventType, eventQueueSize),
+		handlers: make(map[EventType]map[uint64]EventHandler),
+	}
+	go d.run()
+	return d
+}
+
+//export gotanker_event_proxy
+func gotanker_event_proxy(arg unsafe.Pointer, data unsafe.Pointer) {
+	connection := gopointer.Restore(data).(*eventConnection)
+	connection.dispatcher.enqueue(connection.event)
+}
+
+// enqueue queues an event for run(). Do not run user code on the native thread,
+// nor block it: the event is dropped if the handlers are lagging behind.
+func (d *eventDispatcher) enqueue(event EventType) {
+	select {
+	case d.queue <- event:
+	default:
+		atomic.AddUint64(&d.dropped, 1)
+	}
+}
+
+// run dispatches the queued events in the order they were emitted, until the
+// queue is closed.
+func (d *eventDispatcher) run() {
+	for event := range d.queue {
+		d.dispatch(event)
+	}
+}
+
+// connect plugs the dispatcher to the native events of the given instance.
+func (d *eventDispatcher) connect(instance *C.tanker_t) error {
+	for _, event := range []EventType{EventSessionClosed, EventDeviceRevoked} {
+		data := gopointer.Save(&eventConnection{dispatcher: d, event: event})
+		d.connections = append(d.connections, data)
+		if _, err := await(C.gotanker_event_connect(instance, C.enum_tanker_event(event), data)); err != nil {
+			return withOperation(err, "NewTanker")
+		}
+	}
+	return nil
+}
+
+func (d *eventDispatcher) dispatch(event EventType) {
+	d.mutex.Lock()
+	handlers := make([]EventHandler, 0, len(d.handlers[event]))
+	for _, handler := range d.handlers[event] {
+		handlers = append(handlers, handler)
+	}
+	for _, subscriber := range d.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			// the subscriber is lagging behind, drop the event rather than blocking
+		}
+	}
+	d.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+func (d *eventDispatcher) register(event EventType, handler EventHandler) func() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	id := d.nextID
+	d.nextID++
+	if d.handlers[event] == nil {
+		d.handlers[event] = make(map[uint64]EventHandler)
+	}
+	d.handlers[event][id] = handler
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mutex.Lock()
+			defer d.mutex.Unlock()
+			delete(d.handlers[event], id)
+		})
+	}
+}
+
+func (d *eventDispatcher) subscribe() <-chan EventType {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	subscriber := make(chan EventType, eventSubscriberBufferSize)
+	if d.closed {
+		close(subscriber)
+		return subscriber
+	}
+	d.subscribers = append(d.subscribers, subscriber)
+	return subscriber
+}
+
+// close releases the native callback data and closes every subscriber channel,
+// the events still queued are dropped.
+// Must only be called once the native instance has been destroyed.
+func (d *eventDispatcher) close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	close(d.queue)
+	for _, subscriber := range d.subscribers {
+		close(subscriber)
+	}
+	d.subscribers = nil
+	d.handlers = make(map[EventType]map[uint64]EventHandler)
+	for _, data := range d.connections {
+		gopointer.Unref(data)
+	}
+	d.connections = nil
+}
+
+// RegisterEventHandler registers an event handler for the given EventType.
+// Several handlers can be registered for the same event. The handlers of all
+// the events of an instance are called one after the other on a single goroutine,
+// in the order the events were emitted, never on the native thread which emitted
+// them: a handler must not block, or it delays the next events. The events
+// emitted while 16 events are waiting for the handlers are dropped, and counted
+// by DroppedEvents().
+// The returned function unregisters the handler, it is safe to call it more than once.
+//
+//  unregister, err := tanker.RegisterEventHandler(core.EventDeviceRevoked, func() {
+//  	// wipe local data
+//  })
+//  defer unregister()
+func (t *Tanker) RegisterEventHandler(event EventType, handler EventHandler) (func(), error) {
+	if event != EventSessionClosed && event != EventDeviceRevoked {
+		return nil, newError(ErrorInvalidArgument, "RegisterEventHandler", "unknown event type")
+	}
+	if handler == nil {
+		return nil, newError(ErrorInvalidArgument, "RegisterEventHandler", "handler must not be nil")
+	}
+	return t.events.register(event, handler), nil
+}
+
+// Events returns a channel on which every event of this Tanker instance is sent.
+// Events are dropped if the channel is not drained fast enough. The channel
+// is closed when the Tanker instance is destroyed.
+//
+//  events := tanker.Events()
+//  for {
+//  	select {
+//  	case event := <-events:
+//  		...
+//  	case <-done:
+//  		return
+//  	}
+//  }
+func (t *Tanker) Events() <-chan EventType {
+	return t.events.subscribe()
+}
+
+// DroppedEvents returns the number of events of this Tanker instance which were
+// dropped because the event handlers were lagging behind. The events dropped for
+// a subscriber of Events() only are not counted.
+func (t *Tanker) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&t.events.dropped)
+}
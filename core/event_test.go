@@ -0,0 +1,40 @@
+package core_test
+
+import (
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+)
+
+var _ = Describe("Event dispatcher", func() {
+	It("Drops and counts the events while the handlers are lagging behind", func() {
+		dispatcher := core.NewEventDispatcher()
+		defer core.CloseEventDispatcher(dispatcher)
+		var handled int32
+		started := make(chan struct{}, 1)
+		unblock := make(chan struct{})
+		core.RegisterDispatcherHandler(dispatcher, core.EventDeviceRevoked, func() {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-unblock
+			atomic.AddInt32(&handled, 1)
+		})
+
+		core.EmitEvent(dispatcher, core.EventDeviceRevoked)
+		Eventually(started).Should(Receive())
+		// The queue holds 16 events, the next ones do not block the emitter
+		for i := 0; i < 18; i++ {
+			core.EmitEvent(dispatcher, core.EventDeviceRevoked)
+		}
+		Expect(core.DroppedDispatcherEvents(dispatcher)).To(Equal(uint64(2)))
+
+		close(unblock)
+		Eventually(func() int32 { return atomic.LoadInt32(&handled) }).Should(Equal(int32(17)))
+		Expect(core.DroppedDispatcherEvents(dispatcher)).To(Equal(uint64(2)))
+	})
+})
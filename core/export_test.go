@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// DoWithRetry exposes the retry loop to the tests.
+func DoWithRetry(policy *RetryPolicy, operation string, attempt func() error) error {
+	return doWithRetry(context.Background(), policy, operation, attempt)
+}
+
+// StreamClearSize exposes the chunk layout computation to the tests.
+func StreamClearSize(header []byte, encryptedSize int64) (int64, error) {
+	layout, err := parseChunkLayout(header, encryptedSize)
+	return layout.clearSize, err
+}
+
+// SessionPoolSlots exposes the number of sets of recipients a pool holds
+// sessions for to the tests.
+func SessionPoolSlots(p *SessionPool) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.slots)
+}
+
+// SetPerReadGoroutines makes the streams created afterwards serve the native
+// reads as before the read pump, for the baseline of the benchmarks.
+func SetPerReadGoroutines(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&perReadGoroutines, value)
+}
+
+// WithGroupSize exposes the conversion of the errors of groups too big to the tests.
+func WithGroupSize(err error, size int) error {
+	return withGroupSize(err, size)
+}
+
+// CountLogHandlerProxyInstalls counts the installations of the native log
+// handler proxy until the returned function is called, which returns the count.
+func CountLogHandlerProxyInstalls() func() int {
+	install := installLogHandlerProxy
+	count := 0
+	installLogHandlerProxy = func() {
+		count++
+		install()
+	}
+	return func() int {
+		installLogHandlerProxy = install
+		return count
+	}
+}
+
+// EventDispatcher exposes the dispatcher of the native events to the tests.
+type EventDispatcher = eventDispatcher
+
+// NewEventDispatcher creates a dispatcher which is not connected to any instance.
+func NewEventDispatcher() *EventDispatcher {
+	return newEventDispatcher()
+}
+
+// EmitEvent queues an event as the native callback does.
+func EmitEvent(d *EventDispatcher, event EventType) {
+	d.enqueue(event)
+}
+
+// RegisterDispatcherHandler registers a handler to the dispatcher.
+func RegisterDispatcherHandler(d *EventDispatcher, event EventType, handler EventHandler) func() {
+	return d.register(event, handler)
+}
+
+// DroppedDispatcherEvents returns the number of events the dispatcher dropped.
+func DroppedDispatcherEvents(d *EventDispatcher) uint64 {
+	return atomic.LoadUint64(&d.dropped)
+}
+
+// CloseEventDispatcher closes the dispatcher.
+func CloseEventDispatcher(d *EventDispatcher) {
+	d.close()
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"expvar"
+	"sync"
+)
+
+// ExpvarObserver is an Observer publishing the metrics of the operations with
+// the expvar package. The map it is created with holds a map per operation:
+//
+//	{"Encrypt": {"calls": 12, "in_flight": 1, "errors": 2, "error_codes": {"NetworkError": 2},
+//	  "duration_seconds": 0.84, "input_bytes": 40960, "output_bytes": 41372, "recipients": 24}}
+//
+// It is safe for concurrent use.
+type ExpvarObserver struct {
+	root *expvar.Map
+
+	mutex      sync.Mutex
+	operations map[string]*expvar.Map
+}
+
+// NewExpvarObserver creates an ExpvarObserver publishing its metrics in root,
+// usually created with expvar.NewMap():
+//
+//	options.Observer = core.NewExpvarObserver(expvar.NewMap("tanker"))
+func NewExpvarObserver(root *expvar.Map) *ExpvarObserver {
+	return &ExpvarObserver{root: root, operations: map[string]*expvar.Map{}}
+}
+
+// operation returns the map of an operation, creating it on first use.
+func (o *ExpvarObserver) operation(name string) *expvar.Map {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	m := o.operations[name]
+	if m == nil {
+		m = new(expvar.Map).Init()
+		m.Set("error_codes", new(expvar.Map).Init())
+		o.root.Set(name, m)
+		o.operations[name] = m
+	}
+	return m
+}
+
+// OperationStarted implements Observer.
+func (o *ExpvarObserver) OperationStarted(event OperationEvent) {
+	o.operation(event.Operation).Add("in_flight", 1)
+}
+
+// OperationEnded implements Observer.
+func (o *ExpvarObserver) OperationEnded(event OperationEvent) {
+	m := o.operation(event.Operation)
+	m.Add("in_flight", -1)
+	m.Add("calls", 1)
+	m.AddFloat("duration_seconds", event.Duration.Seconds())
+	m.Add("input_bytes", event.InputBytes)
+	m.Add("output_bytes", event.OutputBytes)
+	m.Add("recipients", int64(event.Recipients))
+	if event.Err != nil {
+		m.Add("errors", 1)
+		m.Get("error_codes").(*expvar.Map).Add(errorCodeLabel(event.ErrorCode), 1)
+	}
+}
+
+// errorCodeLabel names the error code of an event, "Unknown" for the errors
+// which are not Tanker errors.
+func errorCodeLabel(code ErrorCode) string {
+	if code == 0 {
+		return "Unknown"
+	}
+	return code.String()
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// EncryptFile encrypts the file at path src to the file at path dst, which keeps
+// the permissions of src. The data will be shared according to the EncryptionOptions
+// passed, the resource ID is returned so that it can be shared later.
+//
+// The encrypted data is written to a temporary file next to dst, which is renamed to
+// dst once synced: dst is either left untouched or fully written. The temporary file
+// is removed on error.
+//
+//	resourceID, err := core.EncryptFile(tanker, "report.pdf", "report.pdf.encrypted", nil)
+func EncryptFile(t Client, src string, dst string, options *EncryptionOptions) (*string, error) {
+	return EncryptFileContext(context.Background(), t, src, dst, options)
+}
+
+// EncryptFileContext is like EncryptFile but stops waiting for the stream creation when ctx is done.
+func EncryptFileContext(ctx context.Context, t Client, src string, dst string, options *EncryptionOptions) (*string, error) {
+	return transformFile(src, dst, func(reader io.Reader) (Stream, error) {
+		return t.StreamEncryptContext(ctx, reader, options)
+	})
+}
+
+// DecryptFile decrypts the file at path src to the file at path dst, in the same
+// way as EncryptFile. The resource ID of the encrypted file is returned.
+func DecryptFile(t Client, src string, dst string) (*string, error) {
+	return DecryptFileContext(context.Background(), t, src, dst)
+}
+
+// DecryptFileContext is like DecryptFile but stops waiting for the stream creation when ctx is done.
+func DecryptFileContext(ctx context.Context, t Client, src string, dst string) (*string, error) {
+	return transformFile(src, dst, func(reader io.Reader) (Stream, error) {
+		return t.StreamDecryptContext(ctx, reader)
+	})
+}
+
+// transformFile writes the output of the stream created by open over the
+// content of src to dst, through a temporary file.
+func transformFile(src string, dst string, open func(io.Reader) (Stream, error)) (*string, error) {
+	source, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close() // nolint: errcheck
+	info, err := source.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return writeStream(dst, info.Mode().Perm(), source, open, nil, nil)
+}
+
+// writeStream writes the output of the stream created by open over source to
+// dst, through a temporary file which gets the permissions perm. If tap is
+// set, it receives a copy of the output. If verify is set, dst is only
+// replaced if it returns no error once the whole output has been written.
+func writeStream(dst string, perm os.FileMode, source io.Reader, open func(io.Reader) (Stream, error), tap io.Writer, verify func() error) (*string, error) {
+	stream, err := open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close() // nolint: errcheck
+	resourceID, err := stream.GetResourceID()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, name := filepath.Split(dst)
+	if dir == "" {
+		dir = "."
+	}
+	// The temporary file is in the directory of dst, so that it can be renamed
+	temp, err := ioutil.TempFile(dir, "."+name+".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	var output io.Writer = temp
+	if tap != nil {
+		output = io.MultiWriter(temp, tap)
+	}
+	err = writeFile(temp, output, stream, perm)
+	if err == nil && verify != nil {
+		err = verify()
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(temp.Name()) // nolint: errcheck
+		return nil, err
+	}
+	syncDir(dir)
+	return resourceID, nil
+}
+
+// writeFile copies the stream to output, which writes to file, and closes
+// both. The file is synced and has the given permissions.
+func writeFile(file *os.File, output io.Writer, stream Stream, perm os.FileMode) error {
+	_, err := io.Copy(output, stream)
+	if err == nil {
+		err = stream.Close()
+	}
+	if err == nil {
+		err = file.Chmod(perm)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir makes a rename in dir durable. It is best-effort, directories
+// cannot be synced on every platform.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()  // nolint: errcheck
+		d.Close() // nolint: errcheck
+	}
+}
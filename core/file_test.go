@@ -0,0 +1,167 @@
+package core_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+	"github.com/TankerHQ/sdk-go/v2/helpers"
+)
+
+// expectFiles checks the names of the files of dir, so that no temporary file is left.
+func expectFiles(dir string, names ...string) {
+	entries, err := ioutil.ReadDir(dir)
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	var found []string
+	for _, entry := range entries {
+		found = append(found, entry.Name())
+	}
+	ExpectWithOffset(1, found).To(ConsistOf(names))
+}
+
+var _ = Describe("Files", func() {
+
+	var (
+		alice        helpers.User
+		aliceLaptop  *helpers.Device
+		aliceSession *core.Tanker
+		dir          string
+	)
+
+	BeforeEach(func() {
+		alice = TestApp.CreateUser()
+		aliceLaptop, _ = alice.CreateDevice()
+		aliceSession, _ = aliceLaptop.Start()
+		var err error
+		dir, err = ioutil.TempDir("", "core-files-")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(aliceSession.Stop()).To(Succeed())
+		os.RemoveAll(dir) // nolint: errcheck
+	})
+
+	It("Encrypts and decrypts files shared with bob", func() {
+		clearData := helpers.RandomBytes(1024 * 1024 * 3)
+		clearPath := filepath.Join(dir, "report.pdf")
+		encryptedPath := filepath.Join(dir, "report.pdf.encrypted")
+		decryptedPath := filepath.Join(dir, "decrypted.pdf")
+		Expect(ioutil.WriteFile(clearPath, clearData, 0640)).To(Succeed())
+		Expect(os.Chmod(clearPath, 0640)).To(Succeed())
+
+		bob := TestApp.CreateUser()
+		bobLaptop, _ := bob.CreateDevice()
+		bobSession, _ := bobLaptop.Start()
+		defer bobSession.Stop() // nolint: errcheck
+		options := core.NewEncryptionOptions()
+		options.ShareWithUsers = []string{bob.PublicIdentity}
+		resourceID, err := core.EncryptFile(aliceSession, clearPath, encryptedPath, &options)
+		Expect(err).ToNot(HaveOccurred())
+		encrypted, err := ioutil.ReadFile(encryptedPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(aliceSession.GetResourceId(encrypted)).To(Equal(resourceID))
+
+		decryptedID, err := core.DecryptFile(bobSession, encryptedPath, decryptedPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*decryptedID).To(Equal(*resourceID))
+		Expect(ioutil.ReadFile(decryptedPath)).To(Equal(clearData))
+		expectFiles(dir, "report.pdf", "report.pdf.encrypted", "decrypted.pdf")
+	})
+
+	It("Gives the permissions of the source to the destination", func() {
+		clearPath := filepath.Join(dir, "clear.txt")
+		encryptedPath := filepath.Join(dir, "clear.txt.encrypted")
+		decryptedPath := filepath.Join(dir, "decrypted.txt")
+		Expect(ioutil.WriteFile(clearPath, []byte("clear"), 0600)).To(Succeed())
+		Expect(os.Chmod(clearPath, 0604)).To(Succeed())
+
+		_, err := core.EncryptFile(aliceSession, clearPath, encryptedPath, nil)
+		Expect(err).ToNot(HaveOccurred())
+		info, err := os.Stat(encryptedPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0604)))
+
+		Expect(os.Chmod(encryptedPath, 0640)).To(Succeed())
+		_, err = core.DecryptFile(aliceSession, encryptedPath, decryptedPath)
+		Expect(err).ToNot(HaveOccurred())
+		info, err = os.Stat(decryptedPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)))
+	})
+
+	It("Replaces an existing destination", func() {
+		clearPath := filepath.Join(dir, "clear.txt")
+		encryptedPath := filepath.Join(dir, "clear.txt.encrypted")
+		Expect(ioutil.WriteFile(clearPath, []byte("clear"), 0600)).To(Succeed())
+		Expect(ioutil.WriteFile(encryptedPath, []byte("previous"), 0600)).To(Succeed())
+
+		_, err := core.EncryptFile(aliceSession, clearPath, encryptedPath, nil)
+		Expect(err).ToNot(HaveOccurred())
+		encrypted, err := ioutil.ReadFile(encryptedPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(aliceSession.Decrypt(encrypted)).To(Equal([]byte("clear")))
+		expectFiles(dir, "clear.txt", "clear.txt.encrypted")
+	})
+
+	It("Writes to the current directory when the destination has no directory", func() {
+		wd, err := os.Getwd()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.Chdir(dir)).To(Succeed())
+		defer os.Chdir(wd) // nolint: errcheck
+		Expect(ioutil.WriteFile("clear.txt", []byte("clear"), 0600)).To(Succeed())
+
+		_, err = core.EncryptFile(aliceSession, "clear.txt", "clear.txt.encrypted", nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = core.DecryptFile(aliceSession, "clear.txt.encrypted", "decrypted.txt")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ioutil.ReadFile(filepath.Join(dir, "decrypted.txt"))).To(Equal([]byte("clear")))
+		expectFiles(dir, "clear.txt", "clear.txt.encrypted", "decrypted.txt")
+	})
+
+	It("Leaves the destination untouched and removes the temporary file on error", func() {
+		clearPath := filepath.Join(dir, "clear.txt")
+		encryptedPath := filepath.Join(dir, "clear.txt.encrypted")
+		decryptedPath := filepath.Join(dir, "decrypted.txt")
+		Expect(ioutil.WriteFile(clearPath, helpers.RandomBytes(1024*1024), 0600)).To(Succeed())
+		_, err := core.EncryptFile(aliceSession, clearPath, encryptedPath, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ioutil.WriteFile(decryptedPath, []byte("previous"), 0600)).To(Succeed())
+
+		// The header of a truncated file is valid, the decryption fails while copying
+		encrypted, err := ioutil.ReadFile(encryptedPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ioutil.WriteFile(encryptedPath, encrypted[:len(encrypted)/2], 0600)).To(Succeed())
+		_, err = core.DecryptFile(aliceSession, encryptedPath, decryptedPath)
+		Expect(err).To(HaveOccurred())
+		Expect(ioutil.ReadFile(decryptedPath)).To(Equal([]byte("previous")))
+		expectFiles(dir, "clear.txt", "clear.txt.encrypted", "decrypted.txt")
+
+		_, err = core.DecryptFile(aliceSession, clearPath, decryptedPath)
+		Expect(err).To(HaveOccurred())
+		Expect(ioutil.ReadFile(decryptedPath)).To(Equal([]byte("previous")))
+		expectFiles(dir, "clear.txt", "clear.txt.encrypted", "decrypted.txt")
+
+		_, err = core.EncryptFile(aliceSession, filepath.Join(dir, "missing.txt"), decryptedPath, nil)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = core.EncryptFile(aliceSession, clearPath, filepath.Join(dir, "missing", "clear.txt.encrypted"), nil)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		expectFiles(dir, "clear.txt", "clear.txt.encrypted", "decrypted.txt")
+	})
+
+	It("Stops waiting for the stream creation when the context is done", func() {
+		clearPath := filepath.Join(dir, "clear.txt")
+		Expect(ioutil.WriteFile(clearPath, []byte("clear"), 0600)).To(Succeed())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := core.EncryptFileContext(ctx, aliceSession, clearPath, filepath.Join(dir, "clear.txt.encrypted"), nil)
+		expectErrorCode(err, core.ErrorOperationCanceled)
+		expectFiles(dir, "clear.txt")
+	})
+})
@@ -5,31 +5,90 @@ package core
 #include <stdlib.h>
 */
 import "C"
-import "unsafe"
+import (
+	"context"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
 
 // CreateGroup creates a Tanker group. The group will be created with the user's PublicIdentities provided.
 // This function succeeds or fails completely, e.g. if a PublicIdentity is invalid, no group is created.
 // On success, the created group ID is returned.
+// The native library does not list the groups of a user nor the members of a group,
+// applications keep track of the group IDs and of their members themselves.
 func (t *Tanker) CreateGroup(publicIdentities []string) (*string, error) {
+	return t.CreateGroupContext(context.Background(), publicIdentities)
+}
+
+// CreateGroupContext is like CreateGroup but stops waiting when ctx is done.
+// The group may still be created after ctx is done.
+func (t *Tanker) CreateGroupContext(ctx context.Context, publicIdentities []string) (_ *string, err error) {
 	nbIDs := len(publicIdentities)
-	ids := toCArray(publicIdentities)
-	defer freeCArray(ids, nbIDs)
-	result, err := await(C.tanker_create_group(t.instance, ids, C.uint64_t(nbIDs)))
+	observation := observe(t.observer, "CreateGroup", nbIDs, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	ids := args.cArray(publicIdentities)
+	result, err := awaitContext(ctx, "CreateGroup", args, func() *C.tanker_future_t {
+		return C.tanker_create_group(t.instance, ids, C.uint64_t(nbIDs))
+	}, releaseBuffer)
 	if err != nil {
-		return nil, err
+		return nil, withGroupSize(err, nbIDs)
 	}
 	groupID := unsafeANSIToString(result)
 	return &groupID, nil
 }
 
+// GroupMembersUpdate lists the members to add to and to remove from a group.
+type GroupMembersUpdate = coretypes.GroupMembersUpdate
+
 // UpdateGroupMembers updates the members of a group. The new group members will automatically
 // get access to all resources previously shared with the group.
+// It is retried according to TankerOptions.Retry.
 func (t *Tanker) UpdateGroupMembers(groupID string, publicIdentitiesToAdd []string) error {
-	nbIDs := len(publicIdentitiesToAdd)
-	cgroupID := C.CString(groupID)
-	ids := toCArray(publicIdentitiesToAdd)
-	defer freeCArray(ids, nbIDs)
-	defer C.free(unsafe.Pointer(cgroupID))
-	_, err := await(C.tanker_update_group_members(t.instance, cgroupID, ids, C.uint64_t(nbIDs)))
-	return err
+	return t.UpdateGroupMembersContext(context.Background(), groupID, publicIdentitiesToAdd)
+}
+
+// UpdateGroupMembersContext is like UpdateGroupMembers but stops waiting when ctx is done.
+// The group may still be updated after ctx is done.
+func (t *Tanker) UpdateGroupMembersContext(ctx context.Context, groupID string, publicIdentitiesToAdd []string) error {
+	return t.updateGroupMembers(ctx, "UpdateGroupMembers", groupID, GroupMembersUpdate{UsersToAdd: publicIdentitiesToAdd})
+}
+
+// EditGroupMembers adds and removes members of a group in a single update, which
+// succeeds or fails completely. The removed members lose access to the resources
+// shared with the group, including the ones they could already decrypt.
+// It is retried according to TankerOptions.Retry.
+func (t *Tanker) EditGroupMembers(groupID string, update GroupMembersUpdate) error {
+	return t.EditGroupMembersContext(context.Background(), groupID, update)
+}
+
+// EditGroupMembersContext is like EditGroupMembers but stops waiting when ctx is done.
+// The group may still be updated after ctx is done.
+func (t *Tanker) EditGroupMembersContext(ctx context.Context, groupID string, update GroupMembersUpdate) error {
+	return t.updateGroupMembers(ctx, "EditGroupMembers", groupID, update)
+}
+
+func (t *Tanker) updateGroupMembers(ctx context.Context, operation string, groupID string, update GroupMembersUpdate) (err error) {
+	nbToAdd := len(update.UsersToAdd)
+	nbToRemove := len(update.UsersToRemove)
+	observation := observe(t.observer, operation, nbToAdd+nbToRemove, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	cgroupID := args.cString(groupID)
+	toAdd := args.cArray(update.UsersToAdd)
+	toRemove := args.cArray(update.UsersToRemove)
+	err = doWithRetry(ctx, t.retry, operation, func() error {
+		_, err := awaitContext(ctx, operation, args, func() *C.tanker_future_t {
+			return C.tanker_update_group_members(t.instance, cgroupID, toAdd, C.uint64_t(nbToAdd), toRemove, C.uint64_t(nbToRemove))
+		}, nil)
+		return err
+	})
+	// The native library checks the members to add and to remove separately
+	size := nbToAdd
+	if nbToRemove > nbToAdd {
+		size = nbToRemove
+	}
+	return withGroupSize(err, size)
 }
@@ -1,6 +1,8 @@
 package core_test
 
 import (
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -70,4 +72,56 @@ var _ = Describe("functional", func() {
 		Expect(aliceSession.Destroy()).To(Succeed())
 	})
 
+	It("Adds and removes group members at once", func() {
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		martine := TestApp.CreateUser()
+		martineLaptop, _ := martine.CreateDevice()
+		martineSession, _ := martineLaptop.Start()
+		bob := TestApp.CreateUser()
+		bobLaptop, _ := bob.CreateDevice()
+		bobSession, _ := bobLaptop.Start()
+		groupID, err := aliceSession.CreateGroup([]string{alice.PublicIdentity, martine.PublicIdentity})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(aliceSession.EditGroupMembers(*groupID, core.GroupMembersUpdate{
+			UsersToAdd:    []string{bob.PublicIdentity},
+			UsersToRemove: []string{martine.PublicIdentity},
+		})).To(Succeed())
+
+		encryptionOptions := core.NewEncryptionOptions()
+		encryptionOptions.ShareWithGroups = []string{*groupID}
+		encrypted, err := aliceSession.Encrypt([]byte("data"), &encryptionOptions)
+		Expect(err).ToNot(HaveOccurred())
+		decrypted, err := bobSession.Decrypt(encrypted)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decrypted).To(Equal([]byte("data")))
+		_, err = martineSession.Decrypt(encrypted)
+		Expect(err).To(HaveOccurred())
+
+		Expect(bobSession.Destroy()).To(Succeed())
+		Expect(martineSession.Destroy()).To(Succeed())
+		Expect(aliceSession.Destroy()).To(Succeed())
+	})
+
+	It("Reports the size of a group too big", func() {
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		// More members than the Tanker server accepts
+		identities := make([]string, 1001)
+		for i := range identities {
+			identities[i] = alice.PublicIdentity
+		}
+		_, err := aliceSession.CreateGroup(identities)
+		var tooBig *core.GroupTooBigError
+		Expect(errors.As(err, &tooBig)).To(BeTrue())
+		Expect(errors.Is(err, core.ErrGroupTooBig)).To(BeTrue())
+		Expect(tooBig.Size).To(Equal(len(identities)))
+		Expect(tooBig.MaxSize).To(BeZero())
+
+		Expect(aliceSession.Destroy()).To(Succeed())
+	})
+
 })
@@ -19,6 +19,37 @@ func toCArray(goStrings []string) **C.char {
 	return res
 }
 
+// releaseBuffer frees a buffer allocated by the native library,
+// it is meant to be used as the release function of awaitContext().
+func releaseBuffer(buffer unsafe.Pointer) {
+	C.tanker_free_buffer(buffer)
+}
+
+// emptyBuffer is passed to the native library in place of empty slices,
+// which have no address. Nothing is read from or written to it.
+var emptyBuffer [1]byte
+
+// bufferPointer returns the address of buffer, to pass it to the native library.
+func bufferPointer(buffer []byte) *C.uint8_t {
+	if len(buffer) == 0 {
+		return (*C.uint8_t)(unsafe.Pointer(&emptyBuffer[0]))
+	}
+	return (*C.uint8_t)(unsafe.Pointer(&buffer[0]))
+}
+
+// grow extends buffer by size bytes, reusing its capacity if possible. It
+// returns the extended buffer and its new part.
+func grow(buffer []byte, size int) ([]byte, []byte) {
+	length := len(buffer)
+	if cap(buffer)-length < size {
+		grown := make([]byte, length, length+size)
+		copy(grown, buffer)
+		buffer = grown
+	}
+	buffer = buffer[:length+size]
+	return buffer, buffer[length:]
+}
+
 func freeCArray(array **C.char, size int) {
 	for i := 0; i < size; i++ {
 		C.free(unsafe.Pointer(*(**C.char)(unsafe.Pointer(uintptr(unsafe.Pointer(array)) + unsafe.Sizeof(uintptr(0))*uintptr(i)))))
@@ -26,23 +57,33 @@ func freeCArray(array **C.char, size int) {
 	C.free(unsafe.Pointer(array))
 }
 
-func convertEncryptionOptions(options EncryptionOptions) *C.tanker_encrypt_options_t {
-	return &C.tanker_encrypt_options_t{
-		version:           3,
-		share_with_users:  toCArray(options.ShareWithUsers),
+// convertEncryptionOptions returns a C copy of options owned by args, nil if options is nil.
+func convertEncryptionOptions(args *nativeArgs, options *EncryptionOptions) *C.tanker_encrypt_options_t {
+	if options == nil {
+		return nil
+	}
+	coptions := (*C.tanker_encrypt_options_t)(args.alloc(C.sizeof_tanker_encrypt_options_t))
+	*coptions = C.tanker_encrypt_options_t{
+		version:           4,
+		share_with_users:  args.cArray(options.ShareWithUsers),
 		nb_users:          C.uint32_t(len(options.ShareWithUsers)),
-		share_with_groups: toCArray(options.ShareWithGroups),
+		share_with_groups: args.cArray(options.ShareWithGroups),
 		nb_groups:         C.uint32_t(len(options.ShareWithGroups)),
 		share_with_self:   C.bool(options.ShareWithSelf),
+		padding_step:      C.uint32_t(options.PaddingStep),
 	}
+	return coptions
 }
 
-func convertSharingOptions(options SharingOptions) *C.tanker_sharing_options_t {
-	return &C.tanker_sharing_options_t{
+// convertSharingOptions returns a C copy of options owned by args.
+func convertSharingOptions(args *nativeArgs, options SharingOptions) *C.tanker_sharing_options_t {
+	coptions := (*C.tanker_sharing_options_t)(args.alloc(C.sizeof_tanker_sharing_options_t))
+	*coptions = C.tanker_sharing_options_t{
 		version:           1,
-		share_with_users:  toCArray(options.ShareWithUsers),
+		share_with_users:  args.cArray(options.ShareWithUsers),
 		nb_users:          C.uint32_t(len(options.ShareWithUsers)),
-		share_with_groups: toCArray(options.ShareWithGroups),
+		share_with_groups: args.cArray(options.ShareWithGroups),
 		nb_groups:         C.uint32_t(len(options.ShareWithGroups)),
 	}
+	return coptions
 }
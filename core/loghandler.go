@@ -12,6 +12,12 @@ static void _tanker_set_log_handler()
 
 */
 import "C"
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
 
 // LogLevel represents a Tanker log level
 // available values are:
@@ -21,6 +27,44 @@ import "C"
 // * E, for Error
 type LogLevel rune
 
+const (
+	LogLevelDebug   LogLevel = 'D'
+	LogLevelInfo    LogLevel = 'I'
+	LogLevelWarning LogLevel = 'W'
+	LogLevelError   LogLevel = 'E'
+)
+
+// severity orders the levels, unknown levels are the most severe so that
+// they are not filtered out.
+func (l LogLevel) severity() int {
+	switch l {
+	case LogLevelDebug:
+		return 0
+	case LogLevelInfo:
+		return 1
+	case LogLevelWarning:
+		return 2
+	case LogLevelError:
+		return 3
+	}
+	return 4
+}
+
+// String returns the name of the level, e.g. "warning".
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarning:
+		return "warning"
+	case LogLevelError:
+		return "error"
+	}
+	return fmt.Sprintf("LogLevel(%q)", rune(l))
+}
+
 // LogRecord represents a Tanker log message.
 // Authors are free to handle each log passed to their registered LogHandler
 // as they see fit.
@@ -32,22 +76,44 @@ type LogRecord struct {
 	Message  string
 }
 
+// String formats the record as the default handler does.
+func (r LogRecord) String() string {
+	return fmt.Sprintf("[%c]{%s}'%s+%d': %s", r.Level, r.Category, r.File, r.Line, r.Message)
+}
+
 // LogHandler defines the Tanker log handler callback.
+//
+// The native library logs from its own threads: the handler is called
+// synchronously from them, and possibly concurrently. Handlers which may
+// block should be wrapped in an AsyncLogHandler.
 type LogHandler func(LogRecord)
 
-var currentLogHandler LogHandler = nil
+// logHandlerBox lets an atomic.Value hold a LogHandler.
+type logHandlerBox struct {
+	handler LogHandler
+}
+
+var currentLogHandler atomic.Value
+
+// DefaultLogHandler is the handler used when none is set: it prints the
+// warnings and errors with the standard logger, and discards the rest.
+func DefaultLogHandler(record LogRecord) {
+	if record.Level.severity() >= LogLevelWarning.severity() {
+		log.Print("tanker: ", record)
+	}
+}
 
 func convertLogLevel(level C.uint) LogLevel {
 	var logLevel LogLevel = 0
 	switch level {
 	case C.TANKER_LOG_DEBUG:
-		logLevel = 'D'
+		logLevel = LogLevelDebug
 	case C.TANKER_LOG_INFO:
-		logLevel = 'I'
+		logLevel = LogLevelInfo
 	case C.TANKER_LOG_WARNING:
-		logLevel = 'W'
+		logLevel = LogLevelWarning
 	case C.TANKER_LOG_ERROR:
-		logLevel = 'E'
+		logLevel = LogLevelError
 	}
 	return logLevel
 }
@@ -61,12 +127,152 @@ func tanker_log_handler_proxy(crecord *C.tanker_log_record_t) {
 		Line:     uint(crecord.line),
 		Message:  C.GoString(crecord.message),
 	}
-	currentLogHandler(record)
+	if redactor := getLogRedactor(); redactor != nil {
+		record.Message = redactor.Redact(record.Message)
+	}
+	getLogHandler()(record)
+}
+
+// installLogHandlerProxy makes the native library log to the proxy instead of
+// its own output. It is a variable so that the tests can count the calls.
+var installLogHandlerProxy = func() {
+	C._tanker_set_log_handler()
+}
+
+func getLogHandler() LogHandler {
+	if box, ok := currentLogHandler.Load().(logHandlerBox); ok {
+		return box.handler
+	}
+	return DefaultLogHandler
 }
 
-// SetLogHandler sets a logHandler for all Tanker instances.
+// SetLogHandler sets a logHandler for all Tanker instances, the native library
+// has a single log output. A nil handler restores DefaultLogHandler. It is safe
+// to call it while Tanker instances are logging.
+//
+// The records cannot be routed to a handler per instance: the native records do
+// not tell which instance logged them, and some are logged outside of any
+// instance.
+//
+//	core.SetLogHandler(core.FilterLogs(core.StdLogHandler(logger), core.LogLevelInfo))
 func SetLogHandler(handler LogHandler) {
-	currentLogHandler = handler
+	if handler == nil {
+		handler = DefaultLogHandler
+	}
+	currentLogHandler.Store(logHandlerBox{handler})
 
-	C._tanker_set_log_handler()
+	installLogHandlerProxy()
+}
+
+// FilterLogs returns a handler passing the records of at least minLevel to
+// handler. If categories are given, only the records of these categories are
+// passed.
+func FilterLogs(handler LogHandler, minLevel LogLevel, categories ...string) LogHandler {
+	allowed := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		allowed[category] = true
+	}
+	return func(record LogRecord) {
+		if record.Level.severity() < minLevel.severity() {
+			return
+		}
+		if len(allowed) > 0 && !allowed[record.Category] {
+			return
+		}
+		handler(record)
+	}
+}
+
+// StdLogHandler returns a handler printing the records with logger.
+func StdLogHandler(logger *log.Logger) LogHandler {
+	return func(record LogRecord) {
+		logger.Print(record)
+	}
+}
+
+// StructuredLogger is the interface of the structured loggers which
+// StructuredLogHandler can log to. keyvals alternates keys and values.
+type StructuredLogger interface {
+	Log(level LogLevel, message string, keyvals ...interface{})
+}
+
+// StructuredLogHandler returns a handler logging the records with logger, the
+// category, file and line are passed as the "category", "file" and "line" fields.
+func StructuredLogHandler(logger StructuredLogger) LogHandler {
+	return func(record LogRecord) {
+		logger.Log(record.Level, record.Message, "category", record.Category, "file", record.File, "line", record.Line)
+	}
+}
+
+// AsyncLogHandler passes the records to a handler from its own goroutine, so
+// that slow handlers do not block the native library. The records are
+// buffered, and dropped when the buffer is full.
+//
+//	logs := core.NewAsyncLogHandler(core.StdLogHandler(logger), 1024)
+//	defer logs.Close()
+//	core.SetLogHandler(logs.Handle)
+type AsyncLogHandler struct {
+	// dropped is accessed atomically, it comes first to be 64-bit aligned
+	dropped uint64
+	handler LogHandler
+	records chan LogRecord
+	done    chan struct{}
+
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// NewAsyncLogHandler creates an AsyncLogHandler buffering up to bufferSize
+// records, at least 1.
+func NewAsyncLogHandler(handler LogHandler, bufferSize int) *AsyncLogHandler {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	h := &AsyncLogHandler{
+		handler: handler,
+		records: make(chan LogRecord, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+func (h *AsyncLogHandler) run() {
+	defer close(h.done)
+	for record := range h.records {
+		h.handler(record)
+	}
+}
+
+// Handle queues the record, it never blocks. It is the LogHandler to pass to
+// SetLogHandler(). The records handled after Close() are dropped.
+func (h *AsyncLogHandler) Handle(record LogRecord) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.closed {
+		atomic.AddUint64(&h.dropped, 1)
+		return
+	}
+	select {
+	case h.records <- record:
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+	}
+}
+
+// Dropped returns the number of records dropped so far.
+func (h *AsyncLogHandler) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// Close passes the buffered records to the handler, and stops the goroutine.
+// Closing an AsyncLogHandler more than once has no effect.
+func (h *AsyncLogHandler) Close() {
+	h.mutex.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.records)
+	}
+	h.mutex.Unlock()
+	<-h.done
 }
@@ -0,0 +1,101 @@
+package core_test
+
+import (
+	"bytes"
+	"log"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+)
+
+type logEntry struct {
+	level   core.LogLevel
+	message string
+	keyvals []interface{}
+}
+
+type fakeStructuredLogger struct {
+	entries []logEntry
+}
+
+func (l *fakeStructuredLogger) Log(level core.LogLevel, message string, keyvals ...interface{}) {
+	l.entries = append(l.entries, logEntry{level, message, keyvals})
+}
+
+var _ = Describe("Log handlers", func() {
+	record := core.LogRecord{Category: "Network", Level: core.LogLevelInfo, File: "client.cpp", Line: 42, Message: "connected"}
+
+	It("Filters by level and category", func() {
+		var handled []core.LogRecord
+		collect := func(record core.LogRecord) { handled = append(handled, record) }
+
+		handler := core.FilterLogs(collect, core.LogLevelWarning)
+		handler(record)
+		Expect(handled).To(BeEmpty())
+		warning := record
+		warning.Level = core.LogLevelWarning
+		handler(warning)
+		Expect(handled).To(Equal([]core.LogRecord{warning}))
+
+		handled = nil
+		handler = core.FilterLogs(collect, core.LogLevelDebug, "Crypto")
+		handler(record)
+		Expect(handled).To(BeEmpty())
+		crypto := record
+		crypto.Category = "Crypto"
+		handler(crypto)
+		Expect(handled).To(Equal([]core.LogRecord{crypto}))
+	})
+
+	It("Adapts standard and structured loggers", func() {
+		var output bytes.Buffer
+		core.StdLogHandler(log.New(&output, "", 0))(record)
+		Expect(output.String()).To(Equal("[I]{Network}'client.cpp+42': connected\n"))
+
+		logger := &fakeStructuredLogger{}
+		core.StructuredLogHandler(logger)(record)
+		Expect(logger.entries).To(Equal([]logEntry{{
+			level:   core.LogLevelInfo,
+			message: "connected",
+			keyvals: []interface{}{"category", "Network", "file", "client.cpp", "line", uint(42)},
+		}}))
+		Expect(core.LogLevelWarning.String()).To(Equal("warning"))
+	})
+
+	It("Dispatches asynchronously and counts the dropped records", func() {
+		var mutex sync.Mutex
+		var handled []core.LogRecord
+		started := make(chan struct{}, 1)
+		unblock := make(chan struct{})
+		async := core.NewAsyncLogHandler(func(record core.LogRecord) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-unblock
+			mutex.Lock()
+			defer mutex.Unlock()
+			handled = append(handled, record)
+		}, 2)
+		// The first record is being handled, the next two are buffered
+		async.Handle(record)
+		Eventually(started).Should(Receive())
+		async.Handle(record)
+		async.Handle(record)
+		async.Handle(record)
+		Expect(async.Dropped()).To(Equal(uint64(1)))
+		close(unblock)
+		async.Close()
+		async.Close()
+		mutex.Lock()
+		defer mutex.Unlock()
+		Expect(handled).To(HaveLen(3))
+
+		async.Handle(record)
+		Expect(handled).To(HaveLen(3))
+		Expect(async.Dropped()).To(Equal(uint64(2)))
+	})
+})
@@ -0,0 +1,115 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+	"github.com/TankerHQ/sdk-go/v2/coremock"
+)
+
+var _ = Describe("Helpers on the mock", func() {
+	var tanker *coremock.Tanker
+
+	BeforeEach(func() {
+		tanker = &coremock.Tanker{}
+	})
+
+	It("Removes the partial output of a failed file encryption", func() {
+		dir, err := ioutil.TempDir("", "coremock-files-")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir) // nolint: errcheck
+		clearPath := filepath.Join(dir, "clear.txt")
+		Expect(ioutil.WriteFile(clearPath, []byte("clear"), 0600)).To(Succeed())
+		copyErr := errors.New("copy failed")
+		tanker.StreamEncryptFunc = func(ctx context.Context, reader io.Reader, options *core.EncryptionOptions) (core.Stream, error) {
+			return coremock.NewStream(io.MultiReader(bytes.NewReader([]byte("partial")), failingReader{copyErr}), "resource"), nil
+		}
+
+		_, err = core.EncryptFile(tanker, clearPath, filepath.Join(dir, "clear.txt.encrypted"), nil)
+		Expect(err).To(MatchError(copyErr))
+		entries, err := ioutil.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(tanker.CallsTo("StreamEncryptContext")).To(HaveLen(1))
+	})
+
+	It("Closes the sessions of a session pool once idle", func() {
+		var sessions []*coremock.EncryptionSession
+		tanker.CreateEncryptionSessionFunc = func(ctx context.Context, encryptionOptions *core.EncryptionOptions) (core.EncryptionSessionClient, error) {
+			session := &coremock.EncryptionSession{
+				ResourceID: "session",
+				EncryptFunc: func(ctx context.Context, clearData []byte) ([]byte, error) {
+					return clearData, nil
+				},
+			}
+			sessions = append(sessions, session)
+			return session, nil
+		}
+		pool := core.NewSessionPool(tanker, core.SessionPoolOptions{IdleTimeout: 20 * time.Millisecond})
+		defer pool.Close()
+		_, err := pool.Encrypt([]byte("data"), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sessions).To(HaveLen(1))
+		Eventually(func() []coremock.Call { return sessions[0].CallsTo("Destroy") }).Should(HaveLen(1))
+
+		_, err = pool.Encrypt([]byte("data"), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sessions).To(HaveLen(2))
+		pool.Close()
+		Expect(sessions[1].CallsTo("Destroy")).To(HaveLen(1))
+		Expect(sessions[0].CallsTo("Destroy")).To(HaveLen(1))
+	})
+
+	It("Retries and resumes bulk shares", func() {
+		failures := map[string]int{"r1": 1, "r3": 5}
+		tanker.ShareFunc = func(ctx context.Context, resourceIDs []string, sharingOptions core.SharingOptions) error {
+			for _, resourceID := range resourceIDs {
+				if failures[resourceID] > 0 {
+					failures[resourceID]--
+					return core.NewError(core.ErrorNetworkError, "Share", "connection reset")
+				}
+			}
+			return nil
+		}
+		options := core.BulkShareOptions{
+			MaxResources: 2,
+			Workers:      1,
+			Retry:        &core.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		}
+		var checkpoints []core.ShareCheckpoint
+		options.OnBatch = func(batch core.ShareBatch, checkpoint core.ShareCheckpoint) {
+			checkpoints = append(checkpoints, checkpoint)
+		}
+		resourceIDs := []string{"r1", "r2", "r3", "r4", "r5"}
+		sharingOptions := core.SharingOptions{ShareWithGroups: []string{"group"}}
+		report, err := core.BulkShare(tanker, resourceIDs, sharingOptions, &options)
+		Expect(errors.Is(err, core.ErrNetworkError)).To(BeTrue())
+		Expect(err.(core.Error).Attempts()).To(Equal(2))
+		Expect(report.Failed()).To(Equal([]string{"r3", "r4"}))
+		Expect(report.Succeeded()).To(Equal([]string{"r1", "r2", "r5"}))
+		Expect(checkpoints).To(HaveLen(3))
+		Expect(checkpoints[2]).To(Equal(report.Checkpoint()))
+		Expect(tanker.CallsTo("ShareContext")).To(HaveLen(5))
+
+		checkpoint := report.Checkpoint()
+		options.Checkpoint = &checkpoint
+		failures["r3"] = 0
+		report, err = core.BulkShare(tanker, resourceIDs, sharingOptions, &options)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Failed()).To(BeEmpty())
+		Expect(report.Batches[0].Skipped).To(BeTrue())
+		Expect(report.Batches[1].Skipped).To(BeFalse())
+		Expect(report.Checkpoint().Done).To(Equal([]int{0, 1, 2}))
+		Expect(tanker.CallsTo("ShareContext")).To(HaveLen(6))
+	})
+})
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// OperationEvent describes a Tanker operation to an Observer.
+type OperationEvent = coretypes.OperationEvent
+
+// Observer receives the start and end events of the operations of a Tanker
+// instance, to collect metrics or traces. See TankerOptions.Observer.
+//
+// The observed operations are Start, Stop, Encrypt, EncryptTo, Decrypt, DecryptTo,
+// DecryptSecret, Share, CreateGroup, UpdateGroupMembers, EditGroupMembers,
+// GetDeviceList, RevokeDevice, CreateEncryptionSession, StreamEncrypt, StreamDecrypt
+// and the verification operations. The encryptions of the encryption sessions are
+// named "EncryptionSession.Encrypt" and "EncryptionSession.StreamEncrypt". Streams
+// end when they are closed, with the sizes of the data read and produced.
+//
+// The methods are called synchronously by the operations, possibly concurrently,
+// and must not block.
+type Observer = coretypes.Observer
+
+type multiObserver []Observer
+
+func (m multiObserver) OperationStarted(event OperationEvent) {
+	for _, observer := range m {
+		observer.OperationStarted(event)
+	}
+}
+
+func (m multiObserver) OperationEnded(event OperationEvent) {
+	for _, observer := range m {
+		observer.OperationEnded(event)
+	}
+}
+
+// MultiObserver returns an Observer passing the events to each of observers,
+// in order.
+//
+//	metrics := core.NewPrometheusObserver(nil)
+//	http.Handle("/metrics", metrics)
+//	options.Observer = core.MultiObserver(metrics, core.NewExpvarObserver(expvar.NewMap("tanker")))
+func MultiObserver(observers ...Observer) Observer {
+	return multiObserver(append([]Observer{}, observers...))
+}
+
+// observation is an operation being observed, a nil observation ignores the
+// calls to end() so that the operations need not check for an observer.
+type observation struct {
+	observer Observer
+	event    OperationEvent
+}
+
+// observe notifies observer of the start of an operation, it returns nil if
+// observer is nil.
+func observe(observer Observer, operation string, recipients int, inputBytes int64) *observation {
+	if observer == nil {
+		return nil
+	}
+	o := &observation{
+		observer: observer,
+		event: OperationEvent{
+			Operation:  operation,
+			Started:    time.Now(),
+			Recipients: recipients,
+			InputBytes: inputBytes,
+		},
+	}
+	observer.OperationStarted(o.event)
+	return o
+}
+
+// end notifies the observer of the end of the operation.
+func (o *observation) end(outputBytes int64, err error) {
+	if o == nil {
+		return
+	}
+	event := o.event
+	event.Duration = time.Since(event.Started)
+	event.OutputBytes = outputBytes
+	event.Err = err
+	var terror Error
+	if errors.As(err, &terror) {
+		event.ErrorCode = terror.Code()
+	}
+	o.observer.OperationEnded(event)
+}
+
+// setInput updates the input size of an operation which is only known at its
+// end, such as the data read by a stream.
+func (o *observation) setInput(inputBytes int64) {
+	if o != nil {
+		o.event.InputBytes = inputBytes
+	}
+}
+
+// encryptionRecipients returns the number of recipients of options, which may
+// be nil.
+func encryptionRecipients(options *EncryptionOptions) int {
+	if options == nil {
+		return 0
+	}
+	return len(options.ShareWithUsers) + len(options.ShareWithGroups)
+}
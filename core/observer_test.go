@@ -0,0 +1,170 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+)
+
+// observerRecorder records the events it observes.
+type observerRecorder struct {
+	mutex  sync.Mutex
+	events []core.OperationEvent
+}
+
+func (r *observerRecorder) OperationStarted(event core.OperationEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *observerRecorder) OperationEnded(event core.OperationEvent) {
+	r.OperationStarted(event)
+}
+
+func (r *observerRecorder) Events() []core.OperationEvent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]core.OperationEvent{}, r.events...)
+}
+
+// blockedWriter blocks the writes until it is released.
+type blockedWriter struct {
+	writing chan struct{}
+	release chan struct{}
+	output  bytes.Buffer
+}
+
+func (w *blockedWriter) Write(data []byte) (int, error) {
+	select {
+	case w.writing <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return w.output.Write(data)
+}
+
+var _ = Describe("Observers", func() {
+	started := core.OperationEvent{Operation: "Encrypt", Started: time.Now(), Recipients: 2, InputBytes: 100}
+	succeeded := started
+	succeeded.Duration = 20 * time.Millisecond
+	succeeded.OutputBytes = 150
+	failed := started
+	failed.Duration = 2 * time.Second
+	failed.Err = core.NewError(core.ErrorNetworkError, "Encrypt", "unreachable")
+	failed.ErrorCode = core.ErrorNetworkError
+	share := core.OperationEvent{Operation: "Share", Started: time.Now(), Recipients: 3, Duration: time.Millisecond, Err: errors.New("invalid")}
+
+	observe := func(observer core.Observer) {
+		observer.OperationStarted(started)
+		observer.OperationEnded(succeeded)
+		observer.OperationStarted(started)
+		observer.OperationEnded(failed)
+		observer.OperationStarted(started)
+		observer.OperationStarted(share)
+		observer.OperationEnded(share)
+	}
+
+	It("Passes the events to every observer", func() {
+		first, second := &observerRecorder{}, &observerRecorder{}
+		core.MultiObserver(first, second).OperationEnded(succeeded)
+		Expect(first.Events()).To(Equal([]core.OperationEvent{succeeded}))
+		Expect(second.Events()).To(Equal([]core.OperationEvent{succeeded}))
+	})
+
+	It("Publishes the metrics with expvar", func() {
+		root := new(expvar.Map).Init()
+		observe(core.NewExpvarObserver(root))
+		var metrics map[string]map[string]interface{}
+		Expect(json.Unmarshal([]byte(root.String()), &metrics)).To(Succeed())
+		Expect(metrics["Encrypt"]).To(Equal(map[string]interface{}{
+			"calls":            2.0,
+			"in_flight":        1.0,
+			"errors":           1.0,
+			"error_codes":      map[string]interface{}{"NetworkError": 1.0},
+			"duration_seconds": 2.02,
+			"input_bytes":      200.0,
+			"output_bytes":     150.0,
+			"recipients":       4.0,
+		}))
+		Expect(metrics["Share"]["error_codes"]).To(Equal(map[string]interface{}{"Unknown": 1.0}))
+	})
+
+	It("Serves the metrics in the Prometheus text format", func() {
+		observer := core.NewPrometheusObserver([]float64{1, 0.1})
+		observe(observer)
+		recorder := httptest.NewRecorder()
+		observer.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+		Expect(recorder.Body.String()).To(Equal(`# HELP tanker_operations_in_flight Number of Tanker operations in progress.
+# TYPE tanker_operations_in_flight gauge
+tanker_operations_in_flight{operation="Encrypt"} 1
+tanker_operations_in_flight{operation="Share"} 0
+# HELP tanker_operations_total Number of Tanker operations completed.
+# TYPE tanker_operations_total counter
+tanker_operations_total{operation="Encrypt"} 2
+tanker_operations_total{operation="Share"} 1
+# HELP tanker_operation_errors_total Number of Tanker operations which failed, by error code.
+# TYPE tanker_operation_errors_total counter
+tanker_operation_errors_total{operation="Encrypt",code="NetworkError"} 1
+tanker_operation_errors_total{operation="Share",code="Unknown"} 1
+# HELP tanker_operation_duration_seconds Duration of the Tanker operations.
+# TYPE tanker_operation_duration_seconds histogram
+tanker_operation_duration_seconds_bucket{operation="Encrypt",le="0.1"} 1
+tanker_operation_duration_seconds_bucket{operation="Encrypt",le="1"} 1
+tanker_operation_duration_seconds_bucket{operation="Encrypt",le="+Inf"} 2
+tanker_operation_duration_seconds_sum{operation="Encrypt"} 2.02
+tanker_operation_duration_seconds_count{operation="Encrypt"} 2
+tanker_operation_duration_seconds_bucket{operation="Share",le="0.1"} 1
+tanker_operation_duration_seconds_bucket{operation="Share",le="1"} 1
+tanker_operation_duration_seconds_bucket{operation="Share",le="+Inf"} 1
+tanker_operation_duration_seconds_sum{operation="Share"} 0.001
+tanker_operation_duration_seconds_count{operation="Share"} 1
+# HELP tanker_operation_input_bytes_total Size of the data encrypted or decrypted by the Tanker operations.
+# TYPE tanker_operation_input_bytes_total counter
+tanker_operation_input_bytes_total{operation="Encrypt"} 200
+tanker_operation_input_bytes_total{operation="Share"} 0
+# HELP tanker_operation_output_bytes_total Size of the data produced by the Tanker operations.
+# TYPE tanker_operation_output_bytes_total counter
+tanker_operation_output_bytes_total{operation="Encrypt"} 150
+tanker_operation_output_bytes_total{operation="Share"} 0
+# HELP tanker_operation_recipients_total Number of users and groups the Tanker operations shared with.
+# TYPE tanker_operation_recipients_total counter
+tanker_operation_recipients_total{operation="Encrypt"} 4
+tanker_operation_recipients_total{operation="Share"} 3
+`))
+
+		var written bytes.Buffer
+		Expect(observer.WriteMetrics(&written)).To(Succeed())
+		Expect(written.String()).To(Equal(recorder.Body.String()))
+	})
+
+	It("Does not block the operations while writing the Prometheus metrics", func() {
+		observer := core.NewPrometheusObserver(nil)
+		observe(observer)
+		writer := &blockedWriter{writing: make(chan struct{}, 1), release: make(chan struct{})}
+		written := make(chan error, 1)
+		go func() { written <- observer.WriteMetrics(writer) }()
+		Eventually(writer.writing).Should(Receive())
+
+		ended := make(chan struct{})
+		go func() {
+			observer.OperationEnded(succeeded)
+			close(ended)
+		}()
+		Eventually(ended).Should(BeClosed())
+
+		close(writer.release)
+		Eventually(written).Should(Receive(BeNil()))
+		Expect(writer.output.String()).To(ContainSubstring(`tanker_operations_total{operation="Encrypt"} 2`))
+	})
+})
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the buckets of the
+// duration histogram of a PrometheusObserver created without buckets.
+var DefaultLatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusObserver is an Observer collecting the metrics of the operations,
+// which it serves in the Prometheus text exposition format as an http.Handler:
+//
+//	metrics := core.NewPrometheusObserver(nil)
+//	http.Handle("/metrics", metrics)
+//	tanker, err := core.NewTanker(core.TankerOptions{AppID: appID, WritablePath: path, Observer: metrics})
+//
+// The metrics are labelled with the operation:
+//  tanker_operations_in_flight (gauge)
+//  tanker_operations_total (counter)
+//  tanker_operation_errors_total (counter), also labelled with the error code
+//  tanker_operation_duration_seconds (histogram)
+//  tanker_operation_input_bytes_total, tanker_operation_output_bytes_total (counters)
+//  tanker_operation_recipients_total (counter)
+// It is safe for concurrent use.
+type PrometheusObserver struct {
+	buckets []float64
+
+	mutex      sync.Mutex
+	operations map[string]*operationMetrics
+}
+
+type operationMetrics struct {
+	inFlight    int64
+	calls       uint64
+	errors      map[string]uint64
+	buckets     []uint64
+	duration    float64
+	inputBytes  int64
+	outputBytes int64
+	recipients  int64
+}
+
+// NewPrometheusObserver creates a PrometheusObserver whose duration histogram
+// has the given bucket upper bounds, in seconds. nil means DefaultLatencyBuckets.
+func NewPrometheusObserver(buckets []float64) *PrometheusObserver {
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	return &PrometheusObserver{buckets: sorted, operations: map[string]*operationMetrics{}}
+}
+
+// operation returns the metrics of an operation, creating them on first use.
+// Must be called with the mutex held.
+func (o *PrometheusObserver) operation(name string) *operationMetrics {
+	m := o.operations[name]
+	if m == nil {
+		m = &operationMetrics{errors: map[string]uint64{}, buckets: make([]uint64, len(o.buckets))}
+		o.operations[name] = m
+	}
+	return m
+}
+
+// OperationStarted implements Observer.
+func (o *PrometheusObserver) OperationStarted(event OperationEvent) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.operation(event.Operation).inFlight++
+}
+
+// OperationEnded implements Observer.
+func (o *PrometheusObserver) OperationEnded(event OperationEvent) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	m := o.operation(event.Operation)
+	m.inFlight--
+	m.calls++
+	seconds := event.Duration.Seconds()
+	m.duration += seconds
+	// The buckets are stored non-cumulatively, and summed when written
+	if i := sort.SearchFloat64s(o.buckets, seconds); i < len(o.buckets) {
+		m.buckets[i]++
+	}
+	m.inputBytes += event.InputBytes
+	m.outputBytes += event.OutputBytes
+	m.recipients += int64(event.Recipients)
+	if event.Err != nil {
+		m.errors[errorCodeLabel(event.ErrorCode)]++
+	}
+}
+
+// snapshot returns a copy of the metrics of the operations.
+func (o *PrometheusObserver) snapshot() map[string]*operationMetrics {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	operations := make(map[string]*operationMetrics, len(o.operations))
+	for name, m := range o.operations {
+		copied := *m
+		copied.errors = make(map[string]uint64, len(m.errors))
+		for code, count := range m.errors {
+			copied.errors[code] = count
+		}
+		copied.buckets = append([]uint64{}, m.buckets...)
+		operations[name] = &copied
+	}
+	return operations
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (o *PrometheusObserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	o.WriteMetrics(w) // nolint: errcheck
+}
+
+// WriteMetrics writes the metrics in the Prometheus text exposition format to
+// output, the operations are sorted by name.
+func (o *PrometheusObserver) WriteMetrics(output io.Writer) error {
+	// The metrics are copied so that writing to a slow client does not block
+	// the operations
+	operations := o.snapshot()
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := bufio.NewWriter(output)
+	writeFamily := func(name string, kind string, help string, write func(operation string, m *operationMetrics)) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, operation := range names {
+			write(operation, operations[operation])
+		}
+	}
+	writeCounter := func(name string, help string, value func(m *operationMetrics) float64) {
+		writeFamily(name, "counter", help, func(operation string, m *operationMetrics) {
+			writeSample(w, name, value(m), "operation", operation)
+		})
+	}
+
+	writeFamily("tanker_operations_in_flight", "gauge", "Number of Tanker operations in progress.", func(operation string, m *operationMetrics) {
+		writeSample(w, "tanker_operations_in_flight", float64(m.inFlight), "operation", operation)
+	})
+	writeCounter("tanker_operations_total", "Number of Tanker operations completed.", func(m *operationMetrics) float64 {
+		return float64(m.calls)
+	})
+	writeFamily("tanker_operation_errors_total", "counter", "Number of Tanker operations which failed, by error code.", func(operation string, m *operationMetrics) {
+		codes := make([]string, 0, len(m.errors))
+		for code := range m.errors {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			writeSample(w, "tanker_operation_errors_total", float64(m.errors[code]), "operation", operation, "code", code)
+		}
+	})
+	writeFamily("tanker_operation_duration_seconds", "histogram", "Duration of the Tanker operations.", func(operation string, m *operationMetrics) {
+		var cumulative uint64
+		for i, bound := range o.buckets {
+			cumulative += m.buckets[i]
+			writeSample(w, "tanker_operation_duration_seconds_bucket", float64(cumulative), "operation", operation, "le", formatFloat(bound))
+		}
+		writeSample(w, "tanker_operation_duration_seconds_bucket", float64(m.calls), "operation", operation, "le", "+Inf")
+		writeSample(w, "tanker_operation_duration_seconds_sum", m.duration, "operation", operation)
+		writeSample(w, "tanker_operation_duration_seconds_count", float64(m.calls), "operation", operation)
+	})
+	writeCounter("tanker_operation_input_bytes_total", "Size of the data encrypted or decrypted by the Tanker operations.", func(m *operationMetrics) float64 {
+		return float64(m.inputBytes)
+	})
+	writeCounter("tanker_operation_output_bytes_total", "Size of the data produced by the Tanker operations.", func(m *operationMetrics) float64 {
+		return float64(m.outputBytes)
+	})
+	writeCounter("tanker_operation_recipients_total", "Number of users and groups the Tanker operations shared with.", func(m *operationMetrics) float64 {
+		return float64(m.recipients)
+	})
+	return w.Flush()
+}
+
+// writeSample writes a sample, labels alternates label names and values.
+func writeSample(w *bufio.Writer, name string, value float64, labels ...string) {
+	w.WriteString(name) // nolint: errcheck
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			w.WriteByte('{') // nolint: errcheck
+		} else {
+			w.WriteByte(',') // nolint: errcheck
+		}
+		fmt.Fprintf(w, "%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1]))
+	}
+	if len(labels) > 1 {
+		w.WriteByte('}') // nolint: errcheck
+	}
+	w.WriteByte(' ')                  // nolint: errcheck
+	w.WriteString(formatFloat(value)) // nolint: errcheck
+	w.WriteByte('\n')                 // nolint: errcheck
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
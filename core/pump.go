@@ -0,0 +1,248 @@
+package core
+
+/*
+#include <ctanker.h>
+*/
+import "C"
+
+import (
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	gopointer "github.com/mattn/go-pointer"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+const (
+	// DefaultStreamChunkSize is the default StreamOptions.ChunkSize, the size of
+	// the clear chunks of the encryption format.
+	DefaultStreamChunkSize = coretypes.DefaultStreamChunkSize
+	// DefaultStreamReadAhead is the default StreamOptions.ReadAhead.
+	DefaultStreamReadAhead = coretypes.DefaultStreamReadAhead
+)
+
+// StreamOptions configures the buffers of the encryption and decryption streams.
+// The zero value uses the defaults.
+type StreamOptions = coretypes.StreamOptions
+
+// streamChunkSize returns the chunk size of the streams created with options.
+func streamChunkSize(o StreamOptions) int {
+	if o.ChunkSize <= 0 {
+		return DefaultStreamChunkSize
+	}
+	return o.ChunkSize
+}
+
+// streamReadAhead returns the read-ahead of the streams created with options.
+func streamReadAhead(o StreamOptions) int {
+	switch {
+	case o.ReadAhead < 0:
+		return 0
+	case o.ReadAhead == 0:
+		return DefaultStreamReadAhead
+	}
+	return o.ReadAhead
+}
+
+// maxEmptyReads is the number of consecutive reads of the source returning no
+// data and no error after which the stream fails with io.ErrNoProgress.
+const maxEmptyReads = 100
+
+// perReadGoroutines, if not zero, makes the pumps serve each native read on a
+// new goroutine, reading straight into the native buffer, as the streams did
+// before the pump. It is only set by the benchmarks, as their baseline, and
+// accessed atomically.
+var perReadGoroutines int32
+
+// readRequest is a read of the native library from the source of a stream.
+type readRequest struct {
+	buffer    []byte
+	operation *C.tanker_stream_read_operation_t
+}
+
+// streamPump feeds the native library with the data of the source of a stream.
+// The native read callbacks are queued to a single goroutine, which lives as
+// long as the stream, and answers them from a read-ahead buffer. The buffer is
+// taken from the pool of the stream buffers, and returned once the goroutine
+// exits.
+type streamPump struct {
+	// bytesRead is accessed atomically, it comes first to be 64-bit aligned
+	bytesRead int64
+
+	reader   io.Reader
+	requests chan readRequest
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// handle is the gopointer handle passed to the native library
+	handle unsafe.Pointer
+
+	// The read-ahead buffer holds the data in [start, end), it is only
+	// accessed by the pump goroutine
+	pooled     *[]byte
+	buffer     []byte
+	start, end int
+	eof        bool
+	// emptyReads is the number of consecutive reads of the source which
+	// returned nothing
+	emptyReads int
+
+	mutex sync.Mutex
+	err   error
+}
+
+func newStreamPump(reader io.Reader, options StreamOptions) *streamPump {
+	p := &streamPump{
+		reader: reader,
+		// The native library waits for a read to finish before starting another
+		requests: make(chan readRequest, 1),
+		stopped:  make(chan struct{}),
+	}
+	if size := streamReadAhead(options); size > 0 {
+		p.pooled = getStreamBuffer(size)
+		p.buffer = *p.pooled
+	}
+	p.handle = gopointer.Save(p)
+	go p.run()
+	return p
+}
+
+//export gotanker_proxy_input_source_read
+func gotanker_proxy_input_source_read(
+	buffer *C.uint8_t,
+	buffer_size C.int64_t,
+	operation *C.tanker_stream_read_operation_t,
+	additional_data unsafe.Pointer,
+) {
+	p, ok := gopointer.Restore(additional_data).(*streamPump)
+	if !ok {
+		// The stream has been released
+		C.tanker_stream_read_operation_finish(operation, -1)
+		return
+	}
+	slice := &reflect.SliceHeader{Data: uintptr(unsafe.Pointer(buffer)), Len: int(buffer_size), Cap: int(buffer_size)}
+	request := readRequest{buffer: *(*[]byte)(unsafe.Pointer(slice)), operation: operation}
+	if atomic.LoadInt32(&perReadGoroutines) != 0 {
+		go p.serveDirect(request)
+		return
+	}
+	p.requests <- request
+}
+
+func (p *streamPump) run() {
+	defer func() {
+		if p.pooled != nil {
+			putStreamBuffer(p.pooled)
+		}
+	}()
+	for {
+		// Read ahead while the native library does not need data
+		for p.end-p.start < len(p.buffer) && !p.eof && p.getErr() == nil && len(p.requests) == 0 {
+			p.fill()
+		}
+		select {
+		case request := <-p.requests:
+			p.serve(request)
+		case <-p.stopped:
+			return
+		}
+	}
+}
+
+// fill reads from the source into the free space of the read-ahead buffer.
+func (p *streamPump) fill() {
+	switch {
+	case p.start == p.end:
+		p.start, p.end = 0, 0
+	case p.end == len(p.buffer):
+		p.end = copy(p.buffer, p.buffer[p.start:p.end])
+		p.start = 0
+	}
+	p.end += p.read(p.buffer[p.end:])
+}
+
+// read reads from the source into buffer, it fails with io.ErrNoProgress
+// after maxEmptyReads consecutive reads returning no data and no error.
+func (p *streamPump) read(buffer []byte) int {
+	n, err := p.reader.Read(buffer)
+	atomic.AddInt64(&p.bytesRead, int64(n))
+	if n == 0 && err == nil {
+		p.emptyReads++
+		if p.emptyReads >= maxEmptyReads {
+			err = io.ErrNoProgress
+		}
+	} else {
+		p.emptyReads = 0
+	}
+	p.setErr(err)
+	return n
+}
+
+// serveDirect answers a read of the native library by reading straight into
+// its buffer.
+func (p *streamPump) serveDirect(request readRequest) {
+	n := 0
+	for n == 0 && !p.eof && p.getErr() == nil {
+		n = p.read(request.buffer)
+	}
+	p.finish(request, n)
+}
+
+func (p *streamPump) serve(request readRequest) {
+	// Finishing a read with no data means the end of the source
+	if len(p.buffer) == 0 {
+		// No read-ahead, read straight into the native buffer
+		p.serveDirect(request)
+		return
+	}
+	for p.start == p.end && !p.eof && p.getErr() == nil {
+		p.fill()
+	}
+	n := copy(request.buffer, p.buffer[p.start:p.end])
+	p.start += n
+	p.finish(request, n)
+}
+
+func (p *streamPump) finish(request readRequest, n int) {
+	if n == 0 && p.getErr() != nil {
+		C.tanker_stream_read_operation_finish(request.operation, -1)
+		return
+	}
+	C.tanker_stream_read_operation_finish(request.operation, C.int64_t(n))
+}
+
+func (p *streamPump) setErr(err error) {
+	if err == io.EOF {
+		p.eof = true
+		return
+	}
+	if err != nil {
+		p.mutex.Lock()
+		p.err = err
+		p.mutex.Unlock()
+	}
+}
+
+// getErr returns the error of the source, if any.
+func (p *streamPump) getErr() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
+
+// stop stops the pump goroutine, once the pending read of the source, if any,
+// returns. The native library must no longer use the pump.
+//
+// A read of the source cannot be interrupted: if the source blocks forever,
+// e.g. a network connection which is never closed, the goroutine and its
+// buffer leak until the read returns. Closing the source, or using a source
+// with deadlines, unblocks it.
+func (p *streamPump) stop() {
+	p.stopOnce.Do(func() {
+		gopointer.Unref(p.handle)
+		close(p.stopped)
+	})
+}
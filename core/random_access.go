@@ -0,0 +1,328 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Layout of the chunks of the encryption formats v4 and v8, used by encryption
+// streams, v8 being the padded one:
+//  version (1 byte) | encrypted chunk size (4 bytes, little endian) | resource ID (16 bytes) |
+//  IV seed (24 bytes) | encrypted data | MAC (16 bytes)
+// Every chunk has the encrypted chunk size but the last one, which may be empty.
+// The encrypted data of a v8 chunk ends with at least one byte of padding, the
+// padding of the resource may span the last chunks.
+const (
+	streamFormatVersion       = 4
+	paddedStreamFormatVersion = 8
+	streamChunkOverhead       = 1 + 4 + 16 + 24 + 16
+	streamHeaderSize          = 1 + 4 + 16 + 24
+	paddedStreamChunkOverhead = streamChunkOverhead + 1
+)
+
+// chunkLayout maps the clear offsets of an encrypted stream to its chunks.
+type chunkLayout struct {
+	encryptedChunkSize int64
+	clearChunkSize     int64
+	nbChunks           int64
+	// clearSize is unknown (-1) for padded streams until their last chunks are
+	// decrypted
+	clearSize int64
+	padded    bool
+}
+
+// isEncryptedStream reports whether header is the one of a v4 or v8 encrypted stream.
+func isEncryptedStream(header []byte) bool {
+	return len(header) >= streamHeaderSize &&
+		(header[0] == streamFormatVersion || header[0] == paddedStreamFormatVersion)
+}
+
+// parseChunkLayout computes the layout of a v4 or v8 encrypted resource of the
+// given size from its first header.
+func parseChunkLayout(header []byte, encryptedSize int64) (chunkLayout, error) {
+	padded := header[0] == paddedStreamFormatVersion
+	overhead := int64(streamChunkOverhead)
+	if padded {
+		overhead = paddedStreamChunkOverhead
+	}
+	encryptedChunkSize := int64(binary.LittleEndian.Uint32(header[1:5]))
+	if encryptedChunkSize <= overhead {
+		return chunkLayout{}, newError(ErrorDecryptionFailed, "NewDecryptReaderAt", "invalid encrypted chunk size")
+	}
+	nbChunks := (encryptedSize + encryptedChunkSize - 1) / encryptedChunkSize
+	lastChunkSize := encryptedSize - (nbChunks-1)*encryptedChunkSize
+	if lastChunkSize < overhead {
+		return chunkLayout{}, newError(ErrorDecryptionFailed, "NewDecryptReaderAt", "truncated encrypted stream")
+	}
+	layout := chunkLayout{
+		encryptedChunkSize: encryptedChunkSize,
+		clearChunkSize:     encryptedChunkSize - overhead,
+		nbChunks:           nbChunks,
+		clearSize:          encryptedSize - nbChunks*overhead,
+		padded:             padded,
+	}
+	if padded {
+		layout.clearSize = -1
+	}
+	return layout, nil
+}
+
+// chunkIndex returns the index of the chunk holding the clear offset.
+func (l chunkLayout) chunkIndex(clearOffset int64) int64 {
+	return clearOffset / l.clearChunkSize
+}
+
+// RandomAccessStream is returned by NewDecryptReaderAt(). It satisfies io.ReadSeeker
+// and io.ReaderAt over the clear data of an encrypted resource, so that it can
+// be served with http.ServeContent().
+//
+// Clear offsets are mapped to the chunks of the encrypted stream, and only the
+// last decrypted chunk is kept. The native library decrypts a stream from its
+// first chunk only, as the IV of each chunk depends on its index, so reading at
+// an offset decrypts every chunk before it: the cost of a read is proportional
+// to its offset, e.g. when serving a Range of a large video. Reads following
+// the last one continue the decryption, reading backward restarts it from the
+// first chunk.
+//
+// The size of the clear data is known without decrypting anything, but for
+// padded streams, which are decrypted once entirely when the RandomAccessStream
+// is created. Resources which are not encrypted streams are decrypted at once
+// when the RandomAccessStream is created.
+type RandomAccessStream struct {
+	tanker *Tanker
+	src    io.ReaderAt
+	size   int64
+	layout chunkLayout
+
+	mutex      sync.Mutex
+	position   int64
+	resourceID string
+	// cursor decrypts the chunks sequentially from the first one, next is the
+	// index of its next chunk
+	cursor Stream
+	next   int64
+	// chunk is the clear data of the chunk of index current
+	chunk     []byte
+	current   int64
+	destroyed bool
+}
+
+// NewDecryptReaderAt creates a RandomAccessStream decrypting the size bytes of encrypted
+// data read from src.
+//
+//	file, _ := os.Open("video.mp4.encrypted")
+//	info, _ := file.Stat()
+//	video, err := tanker.NewDecryptReaderAt(file, info.Size())
+//	if err != nil {
+//		return err
+//	}
+//	defer video.Destroy()
+//	http.ServeContent(w, r, "video.mp4", info.ModTime(), video)
+func (t *Tanker) NewDecryptReaderAt(src io.ReaderAt, size int64) (SeekableStream, error) {
+	return t.NewDecryptReaderAtContext(context.Background(), src, size)
+}
+
+// NewDecryptReaderAtContext is like NewDecryptReaderAt but stops waiting for the stream creation when ctx is done.
+// Reading from the returned RandomAccessStream is not affected by ctx.
+func (t *Tanker) NewDecryptReaderAtContext(ctx context.Context, src io.ReaderAt, size int64) (SeekableStream, error) {
+	if size <= 0 {
+		return nil, newError(ErrorInvalidArgument, "NewDecryptReaderAt", "size must be positive")
+	}
+	s := &RandomAccessStream{tanker: t, src: src, size: size, current: -1}
+	header := make([]byte, streamHeaderSize)
+	n, err := src.ReadAt(header, 0)
+	if n < len(header) && err != io.EOF {
+		return nil, err
+	}
+	header = header[:n]
+
+	if !isEncryptedStream(header) {
+		// Not an encrypted stream, decrypt it at once as a single chunk. Some
+		// of these formats end with the resource ID, which is computed from
+		// the whole encrypted data.
+		encryptedData, err := ioutil.ReadAll(io.NewSectionReader(src, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		resourceID, err := t.GetResourceId(encryptedData)
+		if err != nil {
+			return nil, err
+		}
+		s.resourceID = *resourceID
+		if s.chunk, err = t.DecryptContext(ctx, encryptedData); err != nil {
+			return nil, err
+		}
+		s.current = 0
+		// A chunk size greater than the clear size maps every offset to the chunk 0
+		s.layout = chunkLayout{clearChunkSize: int64(len(s.chunk)) + 1, clearSize: int64(len(s.chunk))}
+		return s, nil
+	}
+	resourceID, err := t.GetResourceId(header)
+	if err != nil {
+		return nil, err
+	}
+	s.resourceID = *resourceID
+	if s.layout, err = parseChunkLayout(header, size); err != nil {
+		return nil, err
+	}
+	// Create the cursor right away, so that a missing key is reported here
+	if err = s.rewind(ctx); err != nil {
+		return nil, err
+	}
+	if s.layout.padded {
+		if s.layout.clearSize, err = s.paddedClearSize(); err != nil {
+			s.Destroy()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// rewind creates a cursor decrypting from the first chunk. Must be called with
+// the mutex held, or before the stream is returned.
+func (s *RandomAccessStream) rewind(ctx context.Context) error {
+	if s.cursor != nil {
+		s.cursor.Destroy()
+		s.cursor = nil
+	}
+	cursor, err := s.tanker.StreamDecryptContext(ctx, io.NewSectionReader(s.src, 0, s.size))
+	if err != nil {
+		return err
+	}
+	s.cursor = cursor
+	s.next = 0
+	return nil
+}
+
+// paddedClearSize decrypts a padded stream entirely to compute its clear size:
+// the padding may span the last chunks.
+func (s *RandomAccessStream) paddedClearSize() (int64, error) {
+	n, err := io.Copy(ioutil.Discard, s.cursor)
+	if err != nil {
+		return 0, err
+	}
+	s.next = s.layout.nbChunks
+	return n, nil
+}
+
+// loadChunk makes the chunk of the given index the current one, decrypting
+// the chunks before it which follow the last one decrypted, or all of them.
+// Must be called with the mutex held.
+func (s *RandomAccessStream) loadChunk(index int64) error {
+	if index == s.current {
+		return nil
+	}
+	if s.cursor == nil || index < s.next {
+		if err := s.rewind(context.Background()); err != nil {
+			return err
+		}
+	}
+	if s.chunk == nil {
+		s.chunk = make([]byte, s.layout.clearChunkSize)
+	}
+	for s.next <= index {
+		n, err := io.ReadFull(s.cursor, s.chunk[:cap(s.chunk)])
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			s.current = -1
+			return err
+		}
+		s.chunk = s.chunk[:n]
+		s.current = s.next
+		s.next++
+	}
+	return nil
+}
+
+// ReadAt reads len(buffer) bytes of clear data starting at the clear offset off.
+// It is safe to call ReadAt concurrently, calls are serialized.
+func (s *RandomAccessStream) ReadAt(buffer []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, newError(ErrorInvalidArgument, "ReadAt", "negative offset")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.readAt(buffer, off)
+}
+
+// readAt must be called with the mutex held.
+func (s *RandomAccessStream) readAt(buffer []byte, off int64) (int, error) {
+	if s.destroyed {
+		return 0, newError(ErrorPreconditionFailed, "Read", "the stream has been destroyed")
+	}
+	read := 0
+	for read < len(buffer) {
+		if off >= s.layout.clearSize {
+			return read, io.EOF
+		}
+		index := s.layout.chunkIndex(off)
+		if err := s.loadChunk(index); err != nil {
+			return read, err
+		}
+		n := copy(buffer[read:], s.chunk[off-index*s.layout.clearChunkSize:])
+		if n == 0 {
+			return read, newError(ErrorDecryptionFailed, "Read", "the encrypted stream is shorter than expected")
+		}
+		read += n
+		off += int64(n)
+	}
+	return read, nil
+}
+
+// Read reads the clear data from the current position.
+func (s *RandomAccessStream) Read(buffer []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, err := s.readAt(buffer, s.position)
+	s.position += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the position of the next Read, relative to the clear data.
+func (s *RandomAccessStream) Seek(offset int64, whence int) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.position
+	case io.SeekEnd:
+		offset += s.layout.clearSize
+	default:
+		return 0, newError(ErrorInvalidArgument, "Seek", "invalid whence")
+	}
+	if offset < 0 {
+		return 0, newError(ErrorInvalidArgument, "Seek", "negative position")
+	}
+	s.position = offset
+	return offset, nil
+}
+
+// Size returns the size of the clear data.
+func (s *RandomAccessStream) Size() int64 {
+	return s.layout.clearSize
+}
+
+// GetResourceID returns the resource ID of the stream.
+func (s *RandomAccessStream) GetResourceID() (*string, error) {
+	resourceID := s.resourceID
+	return &resourceID, nil
+}
+
+// Destroy releases the decryption stream, the RandomAccessStream is no longer usable.
+func (s *RandomAccessStream) Destroy() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cursor != nil {
+		s.cursor.Destroy()
+		s.cursor = nil
+	}
+	s.chunk = nil
+	s.current = -1
+	s.destroyed = true
+}
@@ -0,0 +1,259 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+	"github.com/TankerHQ/sdk-go/v2/helpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// countingReaderAt records the ranges of the encrypted data read by a
+// RandomAccessStream, to check which chunks it decrypted.
+type countingReaderAt struct {
+	reader *bytes.Reader
+
+	mutex sync.Mutex
+	reads int
+	// first and end bound the data read since the last reset
+	first, end int64
+}
+
+func newCountingReaderAt(data []byte) *countingReaderAt {
+	r := &countingReaderAt{reader: bytes.NewReader(data)}
+	r.reset()
+	return r
+}
+
+func (r *countingReaderAt) ReadAt(buffer []byte, off int64) (int, error) {
+	n, err := r.reader.ReadAt(buffer, off)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reads++
+	if off < r.first {
+		r.first = off
+	}
+	if off+int64(n) > r.end {
+		r.end = off + int64(n)
+	}
+	return n, err
+}
+
+func (r *countingReaderAt) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reads, r.first, r.end = 0, r.reader.Size(), 0
+}
+
+// readRange returns the number of reads and the range of the data read since the last reset.
+func (r *countingReaderAt) readRange() (int, int64, int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.reads, r.first, r.end
+}
+
+var _ = Describe("Random access streams", func() {
+	It("Computes the clear size from the chunk layout", func() {
+		header := make([]byte, 45)
+		header[0] = 4
+		binary.LittleEndian.PutUint32(header[1:5], 1024)
+		clearChunkSize := int64(1024 - 61)
+		Expect(core.StreamClearSize(header, 61)).To(Equal(int64(0)))
+		Expect(core.StreamClearSize(header, 61+10)).To(Equal(int64(10)))
+		// A full last chunk is followed by an empty one
+		Expect(core.StreamClearSize(header, 2*1024+61)).To(Equal(2 * clearChunkSize))
+		_, err := core.StreamClearSize(header, 1024+10)
+		Expect(err).To(HaveOccurred())
+
+		// The clear size of padded streams is only known once decrypted
+		header[0] = 8
+		Expect(core.StreamClearSize(header, 2*1024+62)).To(Equal(int64(-1)))
+		_, err = core.StreamClearSize(header, 1024+61)
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("Decrypts at random offsets and serves ranges", func(padding core.Padding) {
+		clearData := helpers.RandomBytes(1024 * 1024 * 3)
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		defer aliceSession.Stop() // nolint: errCheck
+
+		options := core.NewEncryptionOptions()
+		options.PaddingStep = padding
+		encryptedStream, err := aliceSession.StreamEncrypt(bytes.NewReader(clearData), &options)
+		Expect(err).ToNot(HaveOccurred())
+		encrypted, err := ioutil.ReadAll(encryptedStream)
+		Expect(err).ToNot(HaveOccurred())
+		encryptedStream.Destroy()
+
+		stream, err := aliceSession.NewDecryptReaderAt(bytes.NewReader(encrypted), int64(len(encrypted)))
+		Expect(err).ToNot(HaveOccurred())
+		defer stream.Destroy()
+		Expect(stream.Size()).To(Equal(int64(len(clearData))))
+
+		buffer := make([]byte, 100)
+		for _, offset := range []int64{2*1024*1024 + 5, 10, 1024*1024 - 50, 3*1024*1024 - 100, 1024 * 1024} {
+			_, err = stream.ReadAt(buffer, offset)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(buffer).To(Equal(clearData[offset : offset+100]))
+		}
+
+		request := httptest.NewRequest("GET", "/video", nil)
+		request.Header.Set("Range", "bytes=2000000-2000099")
+		recorder := httptest.NewRecorder()
+		http.ServeContent(recorder, request, "video", time.Now(), stream)
+		Expect(recorder.Code).To(Equal(http.StatusPartialContent))
+		Expect(recorder.Body.Bytes()).To(Equal(clearData[2000000:2000100]))
+
+		Expect(stream.Seek(-10, io.SeekEnd)).To(Equal(int64(len(clearData) - 10)))
+		tail, err := ioutil.ReadAll(stream)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tail).To(Equal(clearData[len(clearData)-10:]))
+	},
+		Entry("without padding", core.PaddingOff),
+		Entry("with the automatic padding", core.PaddingAuto),
+		Entry("with padding spanning several chunks", core.Padding(4*1024*1024)),
+	)
+
+	Context("With a session", func() {
+		var (
+			aliceSession *core.Tanker
+			clearData    []byte
+		)
+
+		BeforeEach(func() {
+			alice := TestApp.CreateUser()
+			aliceLaptop, _ := alice.CreateDevice()
+			aliceSession, _ = aliceLaptop.Start()
+			clearData = helpers.RandomBytes(1024 * 1024 * 3)
+		})
+
+		AfterEach(func() {
+			Expect(aliceSession.Stop()).To(Succeed())
+		})
+
+		streamEncrypt := func(padding core.Padding) []byte {
+			options := core.NewEncryptionOptions()
+			options.PaddingStep = padding
+			encryptedStream, err := aliceSession.StreamEncrypt(bytes.NewReader(clearData), &options)
+			Expect(err).ToNot(HaveOccurred())
+			defer encryptedStream.Destroy()
+			encrypted, err := ioutil.ReadAll(encryptedStream)
+			Expect(err).ToNot(HaveOccurred())
+			return encrypted
+		}
+
+		It("Decrypts from the first chunk, forward reads continue the decryption", func() {
+			encrypted := streamEncrypt(core.PaddingOff)
+			src := newCountingReaderAt(encrypted)
+			stream, err := aliceSession.NewDecryptReaderAt(src, int64(len(encrypted)))
+			Expect(err).ToNot(HaveOccurred())
+			defer stream.Destroy()
+
+			buffer := make([]byte, 100)
+			src.reset()
+			_, err = stream.ReadAt(buffer, 2*1024*1024)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(buffer).To(Equal(clearData[2*1024*1024 : 2*1024*1024+100]))
+			_, first, end := src.readRange()
+			Expect(first).To(BeZero())
+			Expect(end).To(BeNumerically(">=", 2*1024*1024))
+
+			// A read further on continues from the last chunk decrypted
+			src.reset()
+			_, err = stream.ReadAt(buffer, 3*1024*1024-100)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(buffer).To(Equal(clearData[3*1024*1024-100:]))
+			_, first, _ = src.readRange()
+			Expect(first).To(BeNumerically(">", 2*1024*1024))
+
+			// A read in the current chunk does not restart the decryption
+			src.reset()
+			_, err = stream.ReadAt(buffer[:10], 3*1024*1024-50)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(buffer[:10]).To(Equal(clearData[3*1024*1024-50 : 3*1024*1024-40]))
+			_, first, _ = src.readRange()
+			Expect(first).ToNot(BeZero())
+
+			// A read backward restarts from the first chunk
+			src.reset()
+			_, err = stream.ReadAt(buffer, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(buffer).To(Equal(clearData[10:110]))
+			_, first, _ = src.readRange()
+			Expect(first).To(BeZero())
+		})
+
+		It("Decrypts padded streams entirely to compute their size", func() {
+			encrypted := streamEncrypt(core.PaddingAuto)
+			src := newCountingReaderAt(encrypted)
+			stream, err := aliceSession.NewDecryptReaderAt(src, int64(len(encrypted)))
+			Expect(err).ToNot(HaveOccurred())
+			defer stream.Destroy()
+			Expect(stream.Size()).To(Equal(int64(len(clearData))))
+			_, first, end := src.readRange()
+			Expect(first).To(BeZero())
+			Expect(end).To(Equal(int64(len(encrypted))))
+		})
+
+		DescribeTable("Decrypts the other formats at once and reads their resource ID", func(encrypt func() []byte) {
+			encrypted := encrypt()
+			expectedID, err := aliceSession.GetResourceId(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			src := newCountingReaderAt(encrypted)
+			stream, err := aliceSession.NewDecryptReaderAt(src, int64(len(encrypted)))
+			Expect(err).ToNot(HaveOccurred())
+			defer stream.Destroy()
+			Expect(stream.GetResourceID()).To(Equal(expectedID))
+
+			src.reset()
+			Expect(ioutil.ReadAll(stream)).To(Equal(clearData[:1000]))
+			reads, _, _ := src.readRange()
+			Expect(reads).To(BeZero())
+		},
+			// v3 ends with the resource ID
+			Entry("encrypted without padding", func() []byte {
+				options := core.NewEncryptionOptions()
+				options.PaddingStep = core.PaddingOff
+				encrypted, err := aliceSession.Encrypt(clearData[:1000], &options)
+				Expect(err).ToNot(HaveOccurred())
+				return encrypted
+			}),
+			// v6 ends with the resource ID
+			Entry("encrypted with padding", func() []byte {
+				encrypted, err := aliceSession.Encrypt(clearData[:1000], nil)
+				Expect(err).ToNot(HaveOccurred())
+				return encrypted
+			}),
+			// v5 and v7 start with the resource ID
+			Entry("encrypted with an encryption session", func() []byte {
+				session, err := aliceSession.CreateEncryptionSession(nil)
+				Expect(err).ToNot(HaveOccurred())
+				defer session.Destroy()
+				encrypted, err := session.Encrypt(clearData[:1000])
+				Expect(err).ToNot(HaveOccurred())
+				return encrypted
+			}),
+		)
+
+		It("Reads the resource ID of streams from their header", func() {
+			encrypted := streamEncrypt(core.PaddingOff)
+			expectedID, err := aliceSession.GetResourceId(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			stream, err := aliceSession.NewDecryptReaderAt(bytes.NewReader(encrypted), int64(len(encrypted)))
+			Expect(err).ToNot(HaveOccurred())
+			defer stream.Destroy()
+			Expect(stream.GetResourceID()).To(Equal(expectedID))
+		})
+	})
+})
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"sync/atomic"
+)
+
+// LogRedactionMode is the way a LogRedactor replaces the personal data it finds.
+type LogRedactionMode int
+
+const (
+	// RedactionMask replaces the values with their kind, e.g. "[email]"
+	RedactionMask LogRedactionMode = iota
+	// RedactionHash replaces the values with their kind and a keyed hash, e.g.
+	// "[email:9f86d081884c7d65]", so that the records about the same value can
+	// still be correlated
+	RedactionHash
+)
+
+// The names of the built-in detectors, used in the replacements.
+const (
+	RedactedEmail      = "email"
+	RedactedIdentity   = "identity"
+	RedactedResourceID = "resource_id"
+)
+
+// LogRedactionPattern is a custom detector of a LogRedactor: the matches of
+// Regexp are replaced according to the LogRedactionMode, labelled with Name.
+type LogRedactionPattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// LogRedactionOptions configures a LogRedactor.
+type LogRedactionOptions struct {
+	Mode LogRedactionMode
+	// HashKey keys the hashes of RedactionHash, so that they cannot be reversed
+	// by hashing guessed values. If empty, a random key is generated: the hashes
+	// are then only stable within the process
+	HashKey []byte
+	// RedactEmails redacts the email addresses
+	RedactEmails bool
+	// RedactIdentities redacts the long base64 strings: the identities, the user
+	// IDs, the group IDs and the tokens
+	RedactIdentities bool
+	// RedactResourceIDs redacts the resource IDs
+	RedactResourceIDs bool
+	// Patterns are applied after the built-in detectors, in order
+	Patterns []LogRedactionPattern
+}
+
+// NewLogRedactionOptions creates LogRedactionOptions with default values: every
+// built-in detector is enabled, and the values are masked.
+func NewLogRedactionOptions() LogRedactionOptions {
+	return LogRedactionOptions{
+		Mode:              RedactionMask,
+		RedactEmails:      true,
+		RedactIdentities:  true,
+		RedactResourceIDs: true,
+	}
+}
+
+var (
+	emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	// base64Regexp matches the candidates of the identity and resource ID
+	// detectors, which are told apart by their length
+	base64Regexp = regexp.MustCompile(`[A-Za-z0-9+/]{22,}={0,2}`)
+)
+
+// minIdentityLength is the length of the shortest value redacted as an identity,
+// the base64 of a 32 bytes user ID is 44 characters long.
+const minIdentityLength = 40
+
+// LogRedactor removes the personal data from the log messages. It is safe for
+// concurrent use.
+type LogRedactor struct {
+	mode              LogRedactionMode
+	hashKey           []byte
+	redactEmails      bool
+	redactIdentities  bool
+	redactResourceIDs bool
+	patterns          []LogRedactionPattern
+}
+
+// NewLogRedactor creates a LogRedactor, it fails with ErrorInvalidArgument if the
+// mode is unknown or if a pattern has no name or no Regexp.
+func NewLogRedactor(options LogRedactionOptions) (*LogRedactor, error) {
+	if options.Mode != RedactionMask && options.Mode != RedactionHash {
+		return nil, newError(ErrorInvalidArgument, "NewLogRedactor", "unknown redaction mode")
+	}
+	for _, pattern := range options.Patterns {
+		if pattern.Name == "" || pattern.Regexp == nil {
+			return nil, newError(ErrorInvalidArgument, "NewLogRedactor", "redaction patterns must have a name and a regexp")
+		}
+	}
+	hashKey := append([]byte{}, options.HashKey...)
+	if options.Mode == RedactionHash && len(hashKey) == 0 {
+		hashKey = make([]byte, 32)
+		if _, err := rand.Read(hashKey); err != nil {
+			return nil, newError(ErrorInternalError, "NewLogRedactor", "cannot generate the hash key: "+err.Error())
+		}
+	}
+	return &LogRedactor{
+		mode:              options.Mode,
+		hashKey:           hashKey,
+		redactEmails:      options.RedactEmails,
+		redactIdentities:  options.RedactIdentities,
+		redactResourceIDs: options.RedactResourceIDs,
+		patterns:          append([]LogRedactionPattern{}, options.Patterns...),
+	}, nil
+}
+
+// replacement returns what replaces a value of the given kind.
+func (r *LogRedactor) replacement(kind string, value string) string {
+	if r.mode == RedactionMask {
+		return "[" + kind + "]"
+	}
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(value)) // nolint: errcheck
+	return "[" + kind + ":" + hex.EncodeToString(mac.Sum(nil)[:8]) + "]"
+}
+
+// isResourceID reports whether value is the base64 of a resource ID.
+func isResourceID(value string) bool {
+	return len(value) == base64.StdEncoding.EncodedLen(resourceIDSize) && value[len(value)-2:] == "=="
+}
+
+// Redact returns message with the personal data replaced.
+func (r *LogRedactor) Redact(message string) string {
+	if r.redactEmails {
+		message = emailRegexp.ReplaceAllStringFunc(message, func(value string) string {
+			return r.replacement(RedactedEmail, value)
+		})
+	}
+	if r.redactIdentities || r.redactResourceIDs {
+		message = base64Regexp.ReplaceAllStringFunc(message, func(value string) string {
+			switch {
+			case r.redactResourceIDs && isResourceID(value):
+				return r.replacement(RedactedResourceID, value)
+			case r.redactIdentities && len(value) >= minIdentityLength:
+				return r.replacement(RedactedIdentity, value)
+			}
+			return value
+		})
+	}
+	for _, pattern := range r.patterns {
+		message = pattern.Regexp.ReplaceAllStringFunc(message, func(value string) string {
+			return r.replacement(pattern.Name, value)
+		})
+	}
+	return message
+}
+
+// Handler returns a handler passing the records to handler with their message
+// redacted, for the handlers which are not set with SetLogHandler().
+func (r *LogRedactor) Handler(handler LogHandler) LogHandler {
+	return func(record LogRecord) {
+		record.Message = r.Redact(record.Message)
+		handler(record)
+	}
+}
+
+// logRedactorBox lets an atomic.Value hold a nil *LogRedactor.
+type logRedactorBox struct {
+	redactor *LogRedactor
+}
+
+var currentLogRedactor atomic.Value
+
+func getLogRedactor() *LogRedactor {
+	box, _ := currentLogRedactor.Load().(logRedactorBox)
+	return box.redactor
+}
+
+// SetLogRedaction redacts the messages of the records of the native library
+// before they are passed to the LogHandler, nil options disable the redaction,
+// which is disabled by default. It fails with ErrorInvalidArgument if the
+// options are invalid, the redaction is then left unchanged.
+//
+// Enabling the redaction routes the records of the native library to the
+// LogHandler, DefaultLogHandler if SetLogHandler() has not been called, as the
+// native library would otherwise print them unredacted.
+//
+//	options := core.NewLogRedactionOptions()
+//	options.Mode = core.RedactionHash
+//	options.HashKey = hashKey
+//	options.Patterns = []core.LogRedactionPattern{{Name: "phone", Regexp: phoneRegexp}}
+//	err := core.SetLogRedaction(&options)
+func SetLogRedaction(options *LogRedactionOptions) error {
+	var redactor *LogRedactor
+	if options != nil {
+		var err error
+		redactor, err = NewLogRedactor(*options)
+		if err != nil {
+			return err
+		}
+	}
+	currentLogRedactor.Store(logRedactorBox{redactor})
+	if redactor != nil {
+		installLogHandlerProxy()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package core_test
+
+import (
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+)
+
+var _ = Describe("Log redaction", func() {
+	const (
+		email      = "alice.smith+tanker@example.co.uk"
+		userID     = "RDa0code5ZIbuDO5kEk4bTAIBC9ltQyS0xzZ0U3GsWc="
+		resourceID = "m3RDlF5KNVQjjtyEGK8HmA=="
+	)
+	message := "sharing " + resourceID + " with " + userID + " (" + email + ")"
+
+	It("Masks the built-in detectors", func() {
+		redactor, err := core.NewLogRedactor(core.NewLogRedactionOptions())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(redactor.Redact(message)).To(Equal("sharing [resource_id] with [identity] ([email])"))
+		Expect(redactor.Redact("encryption session created")).To(Equal("encryption session created"))
+	})
+
+	It("Applies only the enabled detectors and the custom patterns", func() {
+		options := core.LogRedactionOptions{
+			RedactEmails: true,
+			Patterns:     []core.LogRedactionPattern{{Name: "phone", Regexp: regexp.MustCompile(`\+33[0-9]{9}`)}},
+		}
+		redactor, err := core.NewLogRedactor(options)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(redactor.Redact(message + " +33612345678")).To(Equal("sharing " + resourceID + " with " + userID + " ([email]) [phone]"))
+	})
+
+	It("Hashes the values with the hash key", func() {
+		options := core.NewLogRedactionOptions()
+		options.Mode = core.RedactionHash
+		options.HashKey = []byte("key")
+		redactor, err := core.NewLogRedactor(options)
+		Expect(err).ToNot(HaveOccurred())
+		redacted := redactor.Redact(email + " " + email)
+		Expect(redacted).To(MatchRegexp(`^\[email:[0-9a-f]{16}\] \[email:[0-9a-f]{16}\]$`))
+		hashes := strings.Split(redacted, " ")
+		Expect(hashes[0]).To(Equal(hashes[1]))
+
+		options.HashKey = []byte("other key")
+		other, err := core.NewLogRedactor(options)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.Redact(email)).ToNot(Equal(hashes[0]))
+	})
+
+	It("Redacts the records passed to a handler", func() {
+		redactor, err := core.NewLogRedactor(core.NewLogRedactionOptions())
+		Expect(err).ToNot(HaveOccurred())
+		var handled core.LogRecord
+		redactor.Handler(func(record core.LogRecord) { handled = record })(core.LogRecord{Category: "Share", Message: message})
+		Expect(handled).To(Equal(core.LogRecord{Category: "Share", Message: "sharing [resource_id] with [identity] ([email])"}))
+	})
+
+	It("Routes the native records to the redaction without a log handler set", func() {
+		installs := core.CountLogHandlerProxyInstalls()
+		Expect(core.SetLogRedaction(&core.LogRedactionOptions{RedactEmails: true})).To(Succeed())
+		Expect(installs()).To(Equal(1))
+
+		// Disabling the redaction keeps the records routed to the handler
+		installs = core.CountLogHandlerProxyInstalls()
+		Expect(core.SetLogRedaction(nil)).To(Succeed())
+		Expect(installs()).To(BeZero())
+	})
+
+	It("Rejects invalid options", func() {
+		options := core.NewLogRedactionOptions()
+		options.Patterns = []core.LogRedactionPattern{{Name: "phone"}}
+		_, err := core.NewLogRedactor(options)
+		Expect(err).To(HaveOccurred())
+		terror, ok := err.(core.Error)
+		Expect(ok).To(BeTrue())
+		Expect(terror.Code()).To(Equal(core.ErrorInvalidArgument))
+		Expect(core.SetLogRedaction(&options)).ToNot(Succeed())
+		Expect(core.SetLogRedaction(nil)).To(Succeed())
+	})
+})
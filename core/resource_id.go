@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// resourceIDSize is the size of a raw resource ID.
+const resourceIDSize = 16
+
+// resourceIDOffsets maps the versions of the encryption formats which start
+// with the resource ID to its offset:
+//  v4, v8 (streams): version | encrypted chunk size (4 bytes) | resource ID | ...
+//  v5, v7 (encryption sessions): version | resource ID | ...
+var resourceIDOffsets = map[byte]int{
+	4: 1 + 4,
+	5: 1,
+	7: 1,
+	8: 1 + 4,
+}
+
+// trailingIDOverheads maps the versions of the other encryption formats, which
+// end with the resource ID, to the size of their header and resource ID:
+//  v2: version | IV (24 bytes) | encrypted data | resource ID
+//  v3, v6 (padded): version | encrypted data | resource ID
+var trailingIDOverheads = map[byte]int{
+	2: 1 + 24 + resourceIDSize,
+	3: 1 + resourceIDSize,
+	6: 1 + resourceIDSize,
+}
+
+// maxTrailingIDSize bounds the size of the resources which end with their ID:
+// Encrypt() only uses these formats for the data smaller than a stream chunk,
+// padding included.
+const maxTrailingIDSize = 2 * DefaultStreamChunkSize
+
+// GetResourceIDFromReader retrieves the ID of the encrypted resource read from r,
+// reading only its header when the encryption format allows it. It returns a reader
+// replaying the data consumed followed by the rest of r, so that the encrypted data
+// can still be stored or decrypted:
+//
+//	resourceID, encrypted, err := tanker.GetResourceIDFromReader(upload)
+//	...
+//	_, err = io.Copy(storage, encrypted)
+//
+// Resources encrypted with Encrypt() without an encryption session and smaller than
+// a stream chunk end with their resource ID, they are read until the end.
+func (t *Tanker) GetResourceIDFromReader(r io.Reader) (*string, io.Reader, error) {
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(r, version); err != nil {
+		if err == io.EOF {
+			return nil, nil, newError(ErrorInvalidArgument, "GetResourceIDFromReader", "encrypted data must not be empty")
+		}
+		return nil, nil, err
+	}
+	if overhead, ok := trailingIDOverheads[version[0]]; ok {
+		return t.getTrailingResourceID(version[0], overhead, r)
+	}
+	offset, ok := resourceIDOffsets[version[0]]
+	if !ok {
+		return nil, nil, newError(ErrorInvalidArgument, "GetResourceIDFromReader", fmt.Sprintf("unknown encryption format version %d", version[0]))
+	}
+	header := make([]byte, offset+resourceIDSize)
+	header[0] = version[0]
+	if err := readFullHeader(r, header[1:]); err != nil {
+		return nil, nil, err
+	}
+	resourceID := base64.StdEncoding.EncodeToString(header[offset:])
+	return &resourceID, io.MultiReader(bytes.NewReader(header), r), nil
+}
+
+// getTrailingResourceID reads the rest of a resource which ends with its ID,
+// at least its overhead and at most maxTrailingIDSize.
+func (t *Tanker) getTrailingResourceID(version byte, overhead int, r io.Reader) (*string, io.Reader, error) {
+	encrypted := bytes.NewBuffer(make([]byte, overhead, 4096))
+	encrypted.Bytes()[0] = version
+	if err := readFullHeader(r, encrypted.Bytes()[1:]); err != nil {
+		return nil, nil, err
+	}
+	if _, err := encrypted.ReadFrom(io.LimitReader(r, maxTrailingIDSize-int64(overhead)+1)); err != nil {
+		return nil, nil, err
+	}
+	if encrypted.Len() > maxTrailingIDSize {
+		return nil, nil, newError(ErrorInvalidArgument, "GetResourceIDFromReader", fmt.Sprintf("encrypted data too large for the encryption format version %d", version))
+	}
+	resourceID, err := t.GetResourceId(encrypted.Bytes())
+	if err != nil {
+		return nil, nil, withOperation(err, "GetResourceIDFromReader")
+	}
+	if raw, err := base64.StdEncoding.DecodeString(*resourceID); err != nil || len(raw) != resourceIDSize {
+		return nil, nil, newError(ErrorInternalError, "GetResourceIDFromReader", "invalid resource ID")
+	}
+	return resourceID, bytes.NewReader(encrypted.Bytes()), nil
+}
+
+// readFullHeader reads the header of a resource, failing with
+// ErrorInvalidArgument if the resource is shorter.
+func readFullHeader(r io.Reader, header []byte) error {
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return newError(ErrorInvalidArgument, "GetResourceIDFromReader", "truncated encrypted data")
+		}
+		return err
+	}
+	return nil
+}
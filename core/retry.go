@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// RetryPolicy configures the automatic retry of the idempotent operations:
+// Share, GetDeviceList, UpdateGroupMembers and GetVerificationMethods.
+// When one of them fails with a retryable error, it is attempted again
+// after an exponential backoff with jitter.
+type RetryPolicy = coretypes.RetryPolicy
+
+// DefaultRetryPolicy returns a policy making up to 4 attempts, starting with
+// a 200ms backoff capped at 5s, and retrying network errors and conflicts.
+func DefaultRetryPolicy() RetryPolicy {
+	return coretypes.DefaultRetryPolicy()
+}
+
+// isRetryable reports whether p retries err.
+func isRetryable(p *RetryPolicy, err error) bool {
+	var terror Error
+	if !errors.As(err, &terror) || terror.Code() == ErrorOperationCanceled {
+		return false
+	}
+	if p.RetryableCodes == nil {
+		return terror.Code().Retryable()
+	}
+	for _, code := range p.RetryableCodes {
+		if code == terror.Code() {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRetryPolicy checks p, NewTanker() refuses invalid policies.
+func validateRetryPolicy(p *RetryPolicy) error {
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return newError(ErrorInvalidArgument, "NewTanker", "retry backoffs must not be negative")
+	}
+	return nil
+}
+
+// jitter returns a random delay between half and the whole of backoff, a
+// negative backoff is treated as zero.
+func jitter(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	half := int64(backoff / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// doWithRetry calls attempt until it succeeds, fails with an error which is not
+// retryable by p, or the maximum number of attempts is reached. The returned error
+// reports the number of attempts made. A nil policy makes a single attempt.
+func doWithRetry(ctx context.Context, p *RetryPolicy, operation string, attempt func() error) error {
+	err := attempt()
+	if p == nil {
+		return err
+	}
+	attempts := 1
+	backoff := p.InitialBackoff
+	for ; err != nil && attempts < p.MaxAttempts && isRetryable(p, err); attempts++ {
+		timer := time.NewTimer(jitter(backoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return withAttempts(newCanceledError(operation, ctx.Err()), attempts)
+		case <-timer.C:
+		}
+		if backoff < math.MaxInt64/2 {
+			backoff *= 2
+		}
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+		err = attempt()
+	}
+	return withAttempts(err, attempts)
+}
@@ -0,0 +1,81 @@
+package core_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	policy := core.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	failing := func(code core.ErrorCode, failures int, attempts *int) func() error {
+		return func() error {
+			*attempts++
+			if *attempts <= failures {
+				return core.NewError(code, "Share", "failure")
+			}
+			return nil
+		}
+	}
+
+	It("Retries retryable errors until the operation succeeds", func() {
+		attempts := 0
+		Expect(core.DoWithRetry(&policy, "Share", failing(core.ErrorNetworkError, 2, &attempts))).To(Succeed())
+		Expect(attempts).To(Equal(3))
+	})
+
+	It("Reports the number of attempts in the final error", func() {
+		attempts := 0
+		err := core.DoWithRetry(&policy, "Share", failing(core.ErrorConflict, 5, &attempts))
+		Expect(attempts).To(Equal(3))
+		var terror core.Error
+		Expect(errors.As(err, &terror)).To(BeTrue())
+		Expect(terror.Code()).To(Equal(core.ErrorConflict))
+		Expect(terror.Attempts()).To(Equal(3))
+		Expect(err.Error()).To(Equal("Share: failure (after 3 attempts)"))
+	})
+
+	It("Does not retry permanent errors", func() {
+		attempts := 0
+		err := core.DoWithRetry(&policy, "Share", failing(core.ErrorInvalidArgument, 5, &attempts))
+		Expect(attempts).To(Equal(1))
+		Expect(err.(core.Error).Attempts()).To(Equal(1))
+	})
+
+	It("Retries the configured codes only", func() {
+		custom := policy
+		custom.RetryableCodes = []core.ErrorCode{core.ErrorInternalError}
+		attempts := 0
+		Expect(core.DoWithRetry(&custom, "Share", failing(core.ErrorInternalError, 1, &attempts))).To(Succeed())
+		attempts = 0
+		Expect(core.DoWithRetry(&custom, "Share", failing(core.ErrorNetworkError, 1, &attempts))).ToNot(Succeed())
+		Expect(attempts).To(Equal(1))
+	})
+
+	It("Makes a single attempt without a policy", func() {
+		attempts := 0
+		Expect(core.DoWithRetry(nil, "Share", failing(core.ErrorNetworkError, 1, &attempts))).ToNot(Succeed())
+		Expect(attempts).To(Equal(1))
+	})
+
+	It("Treats a negative backoff as zero", func() {
+		negative := policy
+		negative.InitialBackoff = -time.Second
+		attempts := 0
+		Expect(core.DoWithRetry(&negative, "Share", failing(core.ErrorNetworkError, 2, &attempts))).To(Succeed())
+		Expect(attempts).To(Equal(3))
+	})
+
+	It("Refuses a policy with a negative backoff", func() {
+		negative := policy
+		negative.MaxBackoff = -time.Second
+		_, err := core.NewTanker(core.TankerOptions{AppID: "app", WritablePath: "/tmp", Retry: &negative})
+		Expect(err).To(HaveOccurred())
+		Expect(err.(core.Error).Code()).To(Equal(core.ErrorInvalidArgument))
+	})
+})
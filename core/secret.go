@@ -0,0 +1,83 @@
+package core
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <ctanker.h>
+
+// The writes through a volatile pointer cannot be optimized away, unlike a
+// memset() followed by a free()
+static void gotanker_zero(void *data, size_t size) {
+	volatile unsigned char *bytes = data;
+	while (size--)
+		*bytes++ = 0;
+}
+*/
+import "C"
+import (
+	"unsafe"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// SecretBuffer holds a secret, such as a passphrase, a verification key or decrypted
+// data, in memory allocated outside of the Go heap where the platform allows it: it
+// is never copied by the garbage collector, and it is zeroed by Destroy(). Lock() also
+// prevents it from being swapped to disk. Unlike a string, a SecretBuffer can be wiped
+// once it is no longer needed:
+//
+//	passphrase := core.NewSecretBufferFrom(input)
+//	defer passphrase.Destroy()
+//	err := tanker.VerifyIdentity(core.PassphraseVerification{SecretPassphrase: passphrase})
+//
+// The Tanker functions zero the C copies of the secrets they pass to the native library.
+type SecretBuffer = coretypes.SecretBuffer
+
+// NewSecretBuffer creates a zero-filled SecretBuffer of the given size.
+func NewSecretBuffer(size int) *SecretBuffer {
+	return coretypes.NewSecretBuffer(size)
+}
+
+// NewSecretBufferFrom creates a SecretBuffer holding a copy of secret, which is
+// then zeroed.
+func NewSecretBufferFrom(secret []byte) *SecretBuffer {
+	return coretypes.NewSecretBufferFrom(secret)
+}
+
+// ZeroBytes overwrites data with zeros.
+func ZeroBytes(data []byte) {
+	coretypes.ZeroBytes(data)
+}
+
+// secretCopy returns a C string copy of secret, to be freed with freeSecret().
+func secretCopy(secret []byte) *C.char {
+	copied := (*C.char)(C.malloc(C.size_t(len(secret) + 1)))
+	if len(secret) > 0 {
+		C.memcpy(unsafe.Pointer(copied), unsafe.Pointer(&secret[0]), C.size_t(len(secret)))
+	}
+	*(*C.char)(unsafe.Pointer(uintptr(unsafe.Pointer(copied)) + uintptr(len(secret)))) = 0
+	return copied
+}
+
+// freeSecret zeroes and frees a C string holding a secret.
+func freeSecret(secret *C.char) {
+	if secret == nil {
+		return
+	}
+	C.gotanker_zero(unsafe.Pointer(secret), C.strlen(secret))
+	C.free(unsafe.Pointer(secret))
+}
+
+// releaseSecret zeroes and frees a C string holding a secret allocated by the
+// native library, it is meant to be used as the release function of awaitContext().
+func releaseSecret(secret unsafe.Pointer) {
+	C.gotanker_zero(secret, C.strlen((*C.char)(secret)))
+	C.tanker_free_buffer(secret)
+}
+
+// unsafeSecretToString is like unsafeANSIToString, but zeroes the C string.
+func unsafeSecretToString(pointer unsafe.Pointer) string {
+	str := C.GoString((*C.char)(pointer))
+	releaseSecret(pointer)
+	return str
+}
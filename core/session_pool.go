@@ -0,0 +1,329 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionPoolOptions configures the rotation of the sessions of a SessionPool.
+// Zero values mean no limit.
+type SessionPoolOptions struct {
+	// MaxUses is the number of encryptions after which a session is rotated.
+	MaxUses int
+	// MaxBytes is the amount of clear data after which a session is rotated.
+	MaxBytes int64
+	// TTL is the age after which a session is rotated.
+	TTL time.Duration
+	// IdleTimeout is the time after which an unused session is closed.
+	IdleTimeout time.Duration
+	// OnSession, if set, is called with the resource ID and the normalized
+	// EncryptionOptions of each session the pool creates, so that the resource
+	// IDs can be recorded. It is called without the locks of the pool held,
+	// possibly while other encryptions already use the session.
+	OnSession func(resourceID string, options EncryptionOptions)
+}
+
+// SessionPool encrypts data with EncryptionSessions shared by the data with
+// the same recipients, so that a key is not created for each encryption. The
+// sessions are keyed by a hash of the normalized EncryptionOptions, and rotated
+// according to the SessionPoolOptions. It is safe for concurrent use.
+//
+//	pool := core.NewSessionPool(tanker, core.SessionPoolOptions{MaxUses: 10000, TTL: time.Hour})
+//	defer pool.Close()
+//	encrypted, err := pool.Encrypt(record, &options)
+type SessionPool struct {
+	tanker  Client
+	options SessionPoolOptions
+
+	mutex  sync.Mutex
+	slots  map[string]*poolSlot
+	closed bool
+	done   chan struct{}
+}
+
+// poolSlot holds the current session for a set of EncryptionOptions. Its
+// mutex serializes the creation of the sessions, so that only one session is
+// created at once for a set of recipients.
+type poolSlot struct {
+	key     string
+	mutex   sync.Mutex
+	current *pooledSession
+	// lastUsed is the time of the last encryption, for the idle timeout
+	lastUsed time.Time
+	// removed is set once the slot is no longer in the slots of the pool, the
+	// callers which got it before must get a new one
+	removed bool
+}
+
+type pooledSession struct {
+	session EncryptionSessionClient
+	created time.Time
+	uses    int
+	bytes   int64
+	// active is the number of pending encryptions, a retired session is
+	// closed once it drops to zero. Both are protected by the slot mutex
+	active  int
+	retired bool
+}
+
+// NewSessionPool creates a SessionPool creating its sessions with t.
+func NewSessionPool(t Client, options SessionPoolOptions) *SessionPool {
+	p := &SessionPool{
+		tanker:  t,
+		options: options,
+		slots:   map[string]*poolSlot{},
+		done:    make(chan struct{}),
+	}
+	if options.IdleTimeout > 0 {
+		go p.closeIdleSessions()
+	}
+	return p
+}
+
+// normalizeEncryptionOptions sorts and deduplicates the recipients, nil
+// options are the default EncryptionOptions.
+func normalizeEncryptionOptions(options *EncryptionOptions) EncryptionOptions {
+	normalized := NewEncryptionOptions()
+	if options != nil {
+		normalized = *options
+	}
+	normalized.ShareWithUsers = sortedSet(normalized.ShareWithUsers)
+	normalized.ShareWithGroups = sortedSet(normalized.ShareWithGroups)
+	return normalized
+}
+
+func sortedSet(values []string) []string {
+	set := append([]string{}, values...)
+	sort.Strings(set)
+	unique := set[:0]
+	for i, value := range set {
+		if i == 0 || value != set[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// poolKey hashes normalized EncryptionOptions.
+func poolKey(options EncryptionOptions) string {
+	hash := sha256.New()
+	number := make([]byte, 8)
+	hashStrings(hash, options.ShareWithUsers)
+	hashStrings(hash, options.ShareWithGroups)
+	if options.ShareWithSelf {
+		number[0] = 1
+	} else {
+		number[0] = 0
+	}
+	hash.Write(number[:1]) // nolint: errcheck
+	binary.BigEndian.PutUint32(number, uint32(options.PaddingStep))
+	hash.Write(number[:4]) // nolint: errcheck
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// hashStrings writes an unambiguous encoding of values to hash.
+func hashStrings(hash hash.Hash, values []string) {
+	number := make([]byte, 8)
+	binary.BigEndian.PutUint64(number, uint64(len(values)))
+	hash.Write(number) // nolint: errcheck
+	for _, value := range values {
+		binary.BigEndian.PutUint64(number, uint64(len(value)))
+		hash.Write(number)        // nolint: errcheck
+		hash.Write([]byte(value)) // nolint: errcheck
+	}
+}
+
+// expired reports whether a session must be rotated before encrypting size bytes.
+func (p *SessionPool) expired(s *pooledSession, now time.Time, size int) bool {
+	return (p.options.MaxUses > 0 && s.uses >= p.options.MaxUses) ||
+		(p.options.MaxBytes > 0 && s.uses > 0 && s.bytes+int64(size) > p.options.MaxBytes) ||
+		(p.options.TTL > 0 && now.Sub(s.created) >= p.options.TTL)
+}
+
+// retire removes the current session of the slot, and returns it if it must be
+// closed by the caller. Must be called with the slot mutex held.
+func (slot *poolSlot) retire() *pooledSession {
+	s := slot.current
+	slot.current = nil
+	if s == nil {
+		return nil
+	}
+	s.retired = true
+	if s.active > 0 {
+		return nil
+	}
+	return s
+}
+
+func (p *SessionPool) checkOpen() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return newError(ErrorPreconditionFailed, "Encrypt", "the session pool has been closed")
+	}
+	return nil
+}
+
+func (p *SessionPool) slot(key string) *poolSlot {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	slot := p.slots[key]
+	if slot == nil {
+		slot = &poolSlot{key: key}
+		p.slots[key] = slot
+	}
+	return slot
+}
+
+// remove removes the slot from the pool if it has no session, so that the
+// slots of the recipients no longer used do not accumulate. Must be called
+// with the slot mutex held.
+func (p *SessionPool) remove(slot *poolSlot) {
+	if slot.current != nil || slot.removed {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.slots[slot.key] == slot {
+		delete(p.slots, slot.key)
+	}
+	slot.removed = true
+}
+
+// lockSlot returns the slot of key with its mutex held.
+func (p *SessionPool) lockSlot(key string) *poolSlot {
+	for {
+		slot := p.slot(key)
+		slot.mutex.Lock()
+		if !slot.removed {
+			return slot
+		}
+		slot.mutex.Unlock()
+	}
+}
+
+// acquire returns a session to encrypt size bytes with, which must be released.
+func (p *SessionPool) acquire(ctx context.Context, options *EncryptionOptions, size int) (*poolSlot, *pooledSession, error) {
+	normalized := normalizeEncryptionOptions(options)
+	slot := p.lockSlot(poolKey(normalized))
+	// Checked with the slot mutex held, so that Close() retires the sessions
+	// created before it
+	if err := p.checkOpen(); err != nil {
+		slot.mutex.Unlock()
+		return nil, nil, err
+	}
+	now := time.Now()
+	if slot.current != nil && p.expired(slot.current, now, size) {
+		if retired := slot.retire(); retired != nil {
+			retired.session.Destroy()
+		}
+	}
+	created := false
+	if slot.current == nil {
+		session, err := p.tanker.CreateEncryptionSessionContext(ctx, &normalized)
+		if err != nil {
+			p.remove(slot)
+			slot.mutex.Unlock()
+			return nil, nil, err
+		}
+		slot.current = &pooledSession{session: session, created: now}
+		created = true
+	}
+	s := slot.current
+	s.uses++
+	s.bytes += int64(size)
+	s.active++
+	slot.lastUsed = now
+	slot.mutex.Unlock()
+
+	// Called without the slot mutex, so that it does not delay the encryptions
+	if created && p.options.OnSession != nil {
+		p.options.OnSession(s.session.GetResourceId(), normalized)
+	}
+	return slot, s, nil
+}
+
+func (p *SessionPool) release(slot *poolSlot, s *pooledSession) {
+	slot.mutex.Lock()
+	s.active--
+	closeSession := s.retired && s.active == 0
+	slot.mutex.Unlock()
+	if closeSession {
+		s.session.Destroy()
+	}
+}
+
+// Encrypt encrypts clearData with the session of the given EncryptionOptions,
+// creating or rotating it if needed.
+func (p *SessionPool) Encrypt(clearData []byte, options *EncryptionOptions) ([]byte, error) {
+	return p.EncryptContext(context.Background(), clearData, options)
+}
+
+// EncryptContext is like Encrypt but stops waiting when ctx is done.
+func (p *SessionPool) EncryptContext(ctx context.Context, clearData []byte, options *EncryptionOptions) ([]byte, error) {
+	slot, s, err := p.acquire(ctx, options, len(clearData))
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(slot, s)
+	return s.session.EncryptContext(ctx, clearData)
+}
+
+// closeIdleSessions closes the sessions unused for IdleTimeout, until the pool is closed.
+func (p *SessionPool) closeIdleSessions() {
+	ticker := time.NewTicker(p.options.IdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.closeSessions(func(slot *poolSlot) bool {
+				return time.Since(slot.lastUsed) >= p.options.IdleTimeout
+			})
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// closeSessions retires the sessions of the slots selected by filter, and
+// removes these slots from the pool.
+func (p *SessionPool) closeSessions(filter func(*poolSlot) bool) {
+	p.mutex.Lock()
+	slots := make([]*poolSlot, 0, len(p.slots))
+	for _, slot := range p.slots {
+		slots = append(slots, slot)
+	}
+	p.mutex.Unlock()
+	for _, slot := range slots {
+		slot.mutex.Lock()
+		var retired *pooledSession
+		if slot.current != nil && filter(slot) {
+			retired = slot.retire()
+			p.remove(slot)
+		}
+		slot.mutex.Unlock()
+		if retired != nil {
+			retired.session.Destroy()
+		}
+	}
+}
+
+// Close closes the sessions of the pool, the pending encryptions complete
+// before their session is closed. The pool is no longer usable.
+func (p *SessionPool) Close() {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	p.mutex.Unlock()
+	p.closeSessions(func(*poolSlot) bool { return true })
+}
@@ -0,0 +1,66 @@
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+	"github.com/TankerHQ/sdk-go/v2/coremock"
+)
+
+var _ = Describe("SessionPool", func() {
+	var tanker *coremock.Tanker
+
+	BeforeEach(func() {
+		tanker = &coremock.Tanker{}
+		sessions := 0
+		tanker.CreateEncryptionSessionFunc = func(ctx context.Context, encryptionOptions *core.EncryptionOptions) (core.EncryptionSessionClient, error) {
+			sessions++
+			return &coremock.EncryptionSession{
+				ResourceID: fmt.Sprintf("session%d", sessions),
+				EncryptFunc: func(ctx context.Context, clearData []byte) ([]byte, error) {
+					return clearData, nil
+				},
+			}, nil
+		}
+	})
+
+	It("Forgets the recipients of the sessions closed once idle", func() {
+		pool := core.NewSessionPool(tanker, core.SessionPoolOptions{IdleTimeout: 20 * time.Millisecond})
+		defer pool.Close()
+		for _, user := range []string{"alice", "bob", "charlie"} {
+			options := core.NewEncryptionOptions()
+			options.ShareWithUsers = []string{user}
+			_, err := pool.Encrypt([]byte("data"), &options)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(core.SessionPoolSlots(pool)).To(Equal(3))
+		Eventually(func() int { return core.SessionPoolSlots(pool) }).Should(BeZero())
+
+		_, err := pool.Encrypt([]byte("data"), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(core.SessionPoolSlots(pool)).To(Equal(1))
+		Expect(tanker.CallsTo("CreateEncryptionSessionContext")).To(HaveLen(4))
+	})
+
+	It("Calls OnSession without holding the locks of the pool", func() {
+		var pool *core.SessionPool
+		var resourceIDs []string
+		pool = core.NewSessionPool(tanker, core.SessionPoolOptions{
+			OnSession: func(resourceID string, options core.EncryptionOptions) {
+				resourceIDs = append(resourceIDs, resourceID)
+				// Encrypting with the new session would deadlock if the slot was locked
+				_, err := pool.Encrypt([]byte("nested"), &options)
+				Expect(err).ToNot(HaveOccurred())
+			},
+		})
+		defer pool.Close()
+		_, err := pool.Encrypt([]byte("data"), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resourceIDs).To(Equal([]string{"session1"}))
+	})
+})
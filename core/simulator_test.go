@@ -0,0 +1,149 @@
+package core_test
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+	"github.com/TankerHQ/sdk-go/v2/coretest"
+)
+
+// startSimulatedDevice starts a simulated Tanker on the device stored at path.
+func startSimulatedDevice(server *coretest.Server, identity string, path string) *coretest.Tanker {
+	tanker, err := server.StartDevice(identity, path, core.PassphraseVerification{Passphrase: "multipass"})
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	return tanker
+}
+
+// The helpers only depend on core.Client, they are tested on the simulator of
+// the coretest package.
+var _ = Describe("Helpers on the simulator", func() {
+	var (
+		server              *coretest.Server
+		alice               string
+		alicePublicIdentity string
+		bob                 string
+		bobPublicIdentity   string
+		aliceSession        *coretest.Tanker
+	)
+
+	BeforeEach(func() {
+		server = coretest.NewServer()
+		alice, alicePublicIdentity = server.CreateIdentity("alice")
+		bob, bobPublicIdentity = server.CreateIdentity("bob")
+		aliceSession = startSimulatedDevice(server, alice, "alice-laptop")
+	})
+
+	Context("Bulk share", func() {
+		It("Shares in batches and reports the failed resources", func() {
+			bobSession := startSimulatedDevice(server, bob, "bob-laptop")
+			carol, carolPublicIdentity := server.CreateIdentity("carol")
+			carolSession := startSimulatedDevice(server, carol, "carol-laptop")
+			var resourceIDs []string
+			var encrypted [][]byte
+			for i := 0; i < 25; i++ {
+				data, err := aliceSession.Encrypt([]byte("data"), nil)
+				Expect(err).ToNot(HaveOccurred())
+				resourceID, err := aliceSession.GetResourceId(data)
+				Expect(err).ToNot(HaveOccurred())
+				resourceIDs = append(resourceIDs, *resourceID)
+				encrypted = append(encrypted, data)
+			}
+			unknown := "AAAAAAAAAAAAAAAAAAAAAA=="
+			resourceIDs = append(resourceIDs, unknown)
+
+			sharingOptions := core.SharingOptions{ShareWithUsers: []string{bobPublicIdentity, carolPublicIdentity}}
+			report, err := core.BulkShare(aliceSession, resourceIDs, sharingOptions, &core.BulkShareOptions{MaxResources: 10, MaxRecipients: 1})
+			expectErrorCode(err, core.ErrorInvalidArgument)
+			Expect(report.Batches).To(HaveLen(6))
+			Expect(report.Failed()).To(Equal(resourceIDs[20:]))
+			Expect(report.Succeeded()).To(Equal(resourceIDs[:20]))
+			Expect(report.Checkpoint().Done).To(Equal([]int{0, 1, 3, 4}))
+			for _, data := range encrypted[:20] {
+				Expect(bobSession.Decrypt(data)).To(Equal([]byte("data")))
+				Expect(carolSession.Decrypt(data)).To(Equal([]byte("data")))
+			}
+			_, err = bobSession.Decrypt(encrypted[20])
+			expectErrorCode(err, core.ErrorInvalidArgument)
+
+			checkpoint := report.Checkpoint()
+			_, err = core.BulkShare(aliceSession, resourceIDs[1:], sharingOptions, &core.BulkShareOptions{MaxResources: 10, MaxRecipients: 1, Checkpoint: &checkpoint})
+			expectErrorCode(err, core.ErrorInvalidArgument)
+		})
+	})
+
+	Context("Session pool", func() {
+		It("Shares sessions between encryptions with the same recipients", func() {
+			var resourceIDs []string
+			var mutex sync.Mutex
+			pool := core.NewSessionPool(aliceSession, core.SessionPoolOptions{
+				MaxUses: 3,
+				OnSession: func(resourceID string, options core.EncryptionOptions) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					resourceIDs = append(resourceIDs, resourceID)
+				},
+			})
+			defer pool.Close()
+			bobSession := startSimulatedDevice(server, bob, "bob-laptop")
+			options := core.NewEncryptionOptions()
+			options.ShareWithUsers = []string{bobPublicIdentity, alicePublicIdentity}
+			reordered := core.NewEncryptionOptions()
+			reordered.ShareWithUsers = []string{alicePublicIdentity, bobPublicIdentity, bobPublicIdentity}
+
+			var wg sync.WaitGroup
+			encrypted := make([][]byte, 6)
+			for i := range encrypted {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					var err error
+					if i%2 == 0 {
+						encrypted[i], err = pool.Encrypt([]byte("record"), &options)
+					} else {
+						encrypted[i], err = pool.Encrypt([]byte("record"), &reordered)
+					}
+					Expect(err).ToNot(HaveOccurred())
+				}(i)
+			}
+			wg.Wait()
+			Expect(resourceIDs).To(HaveLen(2))
+			for _, data := range encrypted {
+				resourceID, err := aliceSession.GetResourceId(data)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resourceIDs).To(ContainElement(*resourceID))
+				Expect(bobSession.Decrypt(data)).To(Equal([]byte("record")))
+			}
+
+			_, err := pool.Encrypt([]byte("record"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resourceIDs).To(HaveLen(3))
+			pool.Close()
+			_, err = pool.Encrypt([]byte("record"), nil)
+			expectErrorCode(err, core.ErrorPreconditionFailed)
+		})
+
+		It("Rotates sessions after a number of bytes or a duration", func() {
+			sessions := 0
+			pool := core.NewSessionPool(aliceSession, core.SessionPoolOptions{
+				MaxBytes:  10,
+				TTL:       50 * time.Millisecond,
+				OnSession: func(string, core.EncryptionOptions) { sessions++ },
+			})
+			defer pool.Close()
+			for _, size := range []int{4, 6, 1} {
+				_, err := pool.Encrypt(make([]byte, size), nil)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(sessions).To(Equal(2))
+			time.Sleep(60 * time.Millisecond)
+			_, err := pool.Encrypt([]byte("data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sessions).To(Equal(3))
+		})
+	})
+})
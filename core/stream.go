@@ -1,11 +1,14 @@
 package core
 
 import (
+	"context"
 	"io"
-	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
-	gopointer "github.com/mattn/go-pointer"
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
 )
 
 /*
@@ -28,71 +31,270 @@ static tanker_future_t *gotanker_stream_decrypt(tanker_t *ctanker, void *additio
 */
 import "C"
 
-type streamWrapper struct {
-	reader io.Reader
-	err    error
+// streamBuffers pools the buffers of the OutputStreams using the default
+// chunk size, other sizes are allocated for each stream.
+var streamBuffers = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, DefaultStreamChunkSize)
+		return &buffer
+	},
+}
+
+func getStreamBuffer(size int) *[]byte {
+	if size != DefaultStreamChunkSize {
+		buffer := make([]byte, size)
+		return &buffer
+	}
+	return streamBuffers.Get().(*[]byte)
+}
+
+func putStreamBuffer(buffer *[]byte) {
+	if len(*buffer) == DefaultStreamChunkSize {
+		streamBuffers.Put(buffer)
+	}
 }
 
 // OutputStream is returned StreamEncrypt() And StreamDecrypt().
-// It statisfies io.Reader, so you should call Read() to get the encrypted or clear data.
+// It statisfies io.ReadCloser, so you should call Read() to get the encrypted or clear data,
+// and Close() once done with it. It also satisfies io.WriterTo, so that io.Copy() does not
+// need an intermediate buffer.
 type OutputStream struct {
-	stream   *C.tanker_stream_t
-	wrapper  *streamWrapper
-	todelete unsafe.Pointer
-}
-
-//export gotanker_proxy_input_source_read
-func gotanker_proxy_input_source_read(
-	buffer *C.uint8_t,
-	buffer_size C.int64_t,
-	operation *C.tanker_stream_read_operation_t,
-	additional_data unsafe.Pointer,
-) {
-	go func() {
-		wrapper := gopointer.Restore(additional_data).(*streamWrapper)
-		slice := &reflect.SliceHeader{Data: uintptr(unsafe.Pointer(buffer)), Len: int(buffer_size), Cap: int(buffer_size)}
-		rbuf := *(*[]byte)(unsafe.Pointer(slice))
-		nb_read, err := wrapper.reader.Read(rbuf)
-		if err == io.EOF || err == nil {
-			C.tanker_stream_read_operation_finish(operation, C.int64_t(nb_read))
-		} else {
-			wrapper.err = err
-			C.tanker_stream_read_operation_finish(operation, -1)
-		}
-	}()
+	// The statistics are accessed atomically, they come first to be 64-bit
+	// aligned. elapsed is set on Close()
+	bytesProduced int64
+	chunks        int64
+	elapsed       int64
+	created       time.Time
+	progress      func(StreamStats)
+
+	stream      *C.tanker_stream_t
+	pump        *streamPump
+	awaiter     *awaiter
+	chunkSize   int
+	observation *observation
+
+	// mutex serializes the operations on the native stream, and its release
+	mutex  sync.Mutex
+	closed bool
+	// buffer holds the data read from the native library in advance, it is
+	// taken from the pool on first use. pending is its part which has not been
+	// read yet
+	buffer  *[]byte
+	pending []byte
+	// err is the first error of the reads, reported to the observer
+	err error
 }
 
-// Read reads from the OutputStream, fills the provided buffer
-// and returns the number of read bytes.
-func (s *OutputStream) Read(buffer []byte) (int, error) {
-	askedLen := C.int64_t(len(buffer))
-	result, err := await(C.tanker_stream_read(s.stream, (*C.uchar)(unsafe.Pointer(&buffer[0])), askedLen))
+func newOutputStream(stream unsafe.Pointer, pump *streamPump, options StreamOptions, observation *observation) *OutputStream {
+	return &OutputStream{
+		created:     time.Now(),
+		progress:    options.Progress,
+		stream:      (*C.tanker_stream_t)(stream),
+		pump:        pump,
+		awaiter:     newAwaiter(),
+		chunkSize:   streamChunkSize(options),
+		observation: observation,
+	}
+}
+
+// chunk returns the read buffer of the stream.
+// Must be called with the mutex held.
+func (s *OutputStream) chunk() []byte {
+	if s.buffer == nil {
+		s.buffer = getStreamBuffer(s.chunkSize)
+	}
+	return *s.buffer
+}
+
+// checkOpen must be called with the mutex held.
+func (s *OutputStream) checkOpen(operation string) error {
+	if s.closed {
+		return newError(ErrorPreconditionFailed, operation, "the stream has been closed")
+	}
+	return nil
+}
+
+// readNative reads from the native stream into buffer.
+// Must be called with the mutex held.
+func (s *OutputStream) readNative(buffer []byte) (int, error) {
+	result, err := s.awaiter.await(C.tanker_stream_read(s.stream, (*C.uchar)(unsafe.Pointer(&buffer[0])), C.int64_t(len(buffer))))
 	nb_read := int((uintptr)(result))
 	if err != nil {
-		if s.wrapper.err != nil {
-			return nb_read, s.wrapper.err
+		if sourceErr := s.pump.getErr(); sourceErr != nil {
+			err = sourceErr
+		} else {
+			err = withOperation(err, "Read")
+		}
+		if s.err == nil {
+			s.err = err
 		}
 		return nb_read, err
 	}
+	if nb_read > 0 {
+		atomic.AddInt64(&s.bytesProduced, int64(nb_read))
+		atomic.AddInt64(&s.chunks, 1)
+	}
+	if s.progress != nil {
+		s.progress(s.Stats())
+	}
 	if nb_read == 0 {
 		return 0, io.EOF
 	}
 	return nb_read, nil
 }
 
-// Destroy destroys the OutputStream, internal resource cleanup is performend
-// this object is no longer usable.
+// StreamStats are the statistics of an encryption or decryption stream.
+type StreamStats = coretypes.StreamStats
+
+// Stats returns the statistics of the stream. It is safe to call it
+// concurrently with the other methods, and after Close().
+func (s *OutputStream) Stats() StreamStats {
+	elapsed := time.Duration(atomic.LoadInt64(&s.elapsed))
+	if elapsed == 0 {
+		elapsed = time.Since(s.created)
+	}
+	return StreamStats{
+		BytesRead:     atomic.LoadInt64(&s.pump.bytesRead),
+		BytesProduced: atomic.LoadInt64(&s.bytesProduced),
+		Chunks:        atomic.LoadInt64(&s.chunks),
+		Elapsed:       elapsed,
+	}
+}
+
+// Read reads from the OutputStream, fills the provided buffer
+// and returns the number of read bytes. It fails with ErrorPreconditionFailed
+// once the stream has been closed.
+func (s *OutputStream) Read(buffer []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.checkOpen("Read"); err != nil {
+		return 0, err
+	}
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+	if len(s.pending) == 0 {
+		if len(buffer) >= s.chunkSize {
+			// Large reads need no intermediate copy
+			return s.readNative(buffer)
+		}
+		chunk := s.chunk()
+		n, err := s.readNative(chunk)
+		s.pending = chunk[:n]
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(buffer, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// WriteTo writes the rest of the data of the OutputStream to w, it returns the
+// number of bytes written and the first error encountered, other than io.EOF.
+func (s *OutputStream) WriteTo(w io.Writer) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.checkOpen("WriteTo"); err != nil {
+		return 0, err
+	}
+	var written int64
+	chunk := s.chunk()
+	for {
+		if len(s.pending) == 0 {
+			n, err := s.readNative(chunk)
+			s.pending = chunk[:n]
+			if err == io.EOF {
+				return written, nil
+			}
+			if err != nil {
+				return written, err
+			}
+		}
+		n, err := w.Write(s.pending)
+		written += int64(n)
+		s.pending = s.pending[n:]
+		if err != nil {
+			return written, err
+		}
+		if len(s.pending) > 0 {
+			return written, io.ErrShortWrite
+		}
+	}
+}
+
+// Close releases the native stream and the resources of the OutputStream,
+// which is no longer usable. Closing a stream more than once has no effect.
+func (s *OutputStream) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	atomic.StoreInt64(&s.elapsed, int64(time.Since(s.created)))
+	_, err := await(C.tanker_stream_close(s.stream))
+	s.stream = nil
+	s.pump.stop()
+	s.awaiter.release()
+	if s.buffer != nil {
+		putStreamBuffer(s.buffer)
+		s.buffer = nil
+	}
+	s.pending = nil
+	if err != nil {
+		err = withOperation(err, "Close")
+	}
+	observedErr := s.err
+	if observedErr == nil {
+		observedErr = err
+	}
+	s.observation.setInput(atomic.LoadInt64(&s.pump.bytesRead))
+	s.observation.end(atomic.LoadInt64(&s.bytesProduced), observedErr)
+	return err
+}
+
+// Destroy closes the OutputStream, ignoring the error. It is kept for
+// compatibility, prefer Close().
 func (s *OutputStream) Destroy() {
-	_, _ = await(C.tanker_stream_close(s.stream))
-	gopointer.Unref(unsafe.Pointer(s.todelete))
+	_ = s.Close()
+}
+
+// releaseStream closes a stream whose creation has been abandoned, it is meant
+// to be used as the release function of awaitContext().
+func releaseStream(result unsafe.Pointer) {
+	_, _ = await(C.tanker_stream_close((*C.tanker_stream_t)(result)))
+}
+
+// streamArgs returns the arguments of the creation of a stream reading from
+// pump. The native library reads the source while the stream is created, e.g.
+// the header of the encrypted data, so an abandoned creation keeps the pump
+// until it completes.
+func streamArgs(pump *streamPump) *nativeArgs {
+	args := &nativeArgs{}
+	args.onAbandon(pump.stop)
+	return args
+}
+
+// stopPump stops the pump of a stream whose creation failed, unless the
+// creation has been abandoned: the pump is then stopped once it completes.
+func stopPump(pump *streamPump, args *nativeArgs) {
+	if !args.detached {
+		pump.stop()
+	}
 }
 
 // GetResourceID returns the resource ID of the stream.
 // The resource ID can be passed to a call to Share()
 func (s *OutputStream) GetResourceID() (*string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.checkOpen("GetResourceID"); err != nil {
+		return nil, err
+	}
 	result, err := await(C.tanker_stream_get_resource_id(s.stream))
 	if err != nil {
-		return nil, err
+		return nil, withOperation(err, "GetResourceID")
 	}
 	streamID := unsafeANSIToString(result)
 	return &streamID, nil
@@ -100,55 +302,73 @@ func (s *OutputStream) GetResourceID() (*string, error) {
 
 // StreamEncrypt creates an OutputStream for encryption. The stream data will be shared according
 // to the EncryptionOptions passed. The Reader passed should contains the clear data.
-func (t *Tanker) StreamEncrypt(reader io.Reader, options *EncryptionOptions) (*OutputStream, error) {
-	var coptions *C.tanker_encrypt_options_t = nil
-	if options != nil {
-		coptions = convertEncryptionOptions(*options)
-		defer freeCArray(coptions.share_with_users, len(options.ShareWithUsers))
-		defer freeCArray(coptions.share_with_groups, len(options.ShareWithGroups))
-	}
-	wrapper := streamWrapper{
-		reader: reader,
-		err:    nil,
-	}
-	wrapped := gopointer.Save(&wrapper)
-	result, err := await(C.gotanker_stream_encrypt(t.instance, wrapped, coptions))
+func (t *Tanker) StreamEncrypt(reader io.Reader, options *EncryptionOptions) (Stream, error) {
+	return t.StreamEncryptContext(context.Background(), reader, options)
+}
+
+// StreamEncryptContext is like StreamEncrypt but stops waiting for the stream creation when ctx is done.
+// Reading from the returned OutputStream is not affected by ctx.
+func (t *Tanker) StreamEncryptContext(ctx context.Context, reader io.Reader, options *EncryptionOptions) (Stream, error) {
+	observation := observe(t.observer, "StreamEncrypt", encryptionRecipients(options), 0)
+	pump := newStreamPump(reader, t.streams)
+	args := streamArgs(pump)
+	defer args.release()
+	coptions := convertEncryptionOptions(args, options)
+	result, err := awaitContext(ctx, "StreamEncrypt", args, func() *C.tanker_future_t {
+		return C.gotanker_stream_encrypt(t.instance, pump.handle, coptions)
+	}, releaseStream)
 	if err != nil {
+		stopPump(pump, args)
+		observation.end(0, err)
 		return nil, err
 	}
-	return &OutputStream{
-		wrapper:  &wrapper,
-		todelete: wrapped,
-		stream:   (*C.tanker_stream_t)(unsafe.Pointer(result)),
-	}, nil
+	return newOutputStream(result, pump, t.streams, observation), nil
 }
 
 // StreamEncrypt creates an OutputStream of data encrypted with the encryption session.
 // The Reader passed should contain the clear data.
-func (s *EncryptionSession) StreamEncrypt(reader io.Reader) (*OutputStream, error) {
-	wrapper := streamWrapper{
-		reader: reader,
-		err:    nil,
-	}
-	wrapped := gopointer.Save(&wrapper)
-	result, err := await(C.gotanker_encryption_session_stream_encrypt(s.instance, wrapped))
+func (s *EncryptionSession) StreamEncrypt(reader io.Reader) (Stream, error) {
+	return s.StreamEncryptContext(context.Background(), reader)
+}
+
+// StreamEncryptContext is like StreamEncrypt but stops waiting for the stream creation when ctx is done.
+// Reading from the returned OutputStream is not affected by ctx.
+func (s *EncryptionSession) StreamEncryptContext(ctx context.Context, reader io.Reader) (Stream, error) {
+	observation := observe(s.observer, "EncryptionSession.StreamEncrypt", 0, 0)
+	pump := newStreamPump(reader, s.streams)
+	args := streamArgs(pump)
+	defer args.release()
+	result, err := awaitContext(ctx, "StreamEncrypt", args, func() *C.tanker_future_t {
+		return C.gotanker_encryption_session_stream_encrypt(s.instance, pump.handle)
+	}, releaseStream)
 	if err != nil {
+		stopPump(pump, args)
+		observation.end(0, err)
 		return nil, err
 	}
-	return &OutputStream{
-		wrapper:  &wrapper,
-		todelete: wrapped,
-		stream:   (*C.tanker_stream_t)(unsafe.Pointer(result)),
-	}, nil
+	return newOutputStream(result, pump, s.streams, observation), nil
 }
 
 // StreamDecrypt creates an OutputStream for encryption. The Reader passed should contain the encrypted
 // data.
-func (t *Tanker) StreamDecrypt(reader io.Reader) (*OutputStream, error) {
-	wrapper := streamWrapper{reader: reader, err: nil}
-	result, err := await(C.gotanker_stream_decrypt(t.instance, gopointer.Save(&wrapper)))
+func (t *Tanker) StreamDecrypt(reader io.Reader) (Stream, error) {
+	return t.StreamDecryptContext(context.Background(), reader)
+}
+
+// StreamDecryptContext is like StreamDecrypt but stops waiting for the stream creation when ctx is done.
+// Reading from the returned OutputStream is not affected by ctx.
+func (t *Tanker) StreamDecryptContext(ctx context.Context, reader io.Reader) (Stream, error) {
+	observation := observe(t.observer, "StreamDecrypt", 0, 0)
+	pump := newStreamPump(reader, t.streams)
+	args := streamArgs(pump)
+	defer args.release()
+	result, err := awaitContext(ctx, "StreamDecrypt", args, func() *C.tanker_future_t {
+		return C.gotanker_stream_decrypt(t.instance, pump.handle)
+	}, releaseStream)
 	if err != nil {
+		stopPump(pump, args)
+		observation.end(0, err)
 		return nil, err
 	}
-	return &OutputStream{stream: (*C.tanker_stream_t)(result), wrapper: &wrapper}, nil
+	return newOutputStream(result, pump, t.streams, observation), nil
 }
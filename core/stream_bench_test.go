@@ -0,0 +1,141 @@
+package core_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/TankerHQ/sdk-go/v2/core"
+	"github.com/TankerHQ/sdk-go/v2/helpers"
+)
+
+const benchmarkStreamSize = 8 * 1024 * 1024
+
+// benchmarkStreamCase is a configuration of the streams to benchmark.
+type benchmarkStreamCase struct {
+	name    string
+	options core.StreamOptions
+	// baseline serves the native reads on a goroutine each, straight into the
+	// native buffer, and reads the native stream straight into the caller's
+	// buffer, as the streams did before the read pump
+	baseline bool
+}
+
+var benchmarkStreamCases = []benchmarkStreamCase{
+	{name: "Baseline", options: core.StreamOptions{ChunkSize: 1, ReadAhead: -1}, baseline: true},
+	benchmarkOptions(core.StreamOptions{}),
+	benchmarkOptions(core.StreamOptions{ReadAhead: -1}),
+	benchmarkOptions(core.StreamOptions{ReadAhead: 64 * 1024}),
+	benchmarkOptions(core.StreamOptions{ChunkSize: 64 * 1024}),
+	benchmarkOptions(core.StreamOptions{ChunkSize: 4 * 1024 * 1024, ReadAhead: 4 * 1024 * 1024}),
+}
+
+func benchmarkOptions(options core.StreamOptions) benchmarkStreamCase {
+	return benchmarkStreamCase{
+		name:    fmt.Sprintf("ChunkSize=%d/ReadAhead=%d", options.ChunkSize, options.ReadAhead),
+		options: options,
+	}
+}
+
+// drain reads stream until its end. The streams had no WriteTo() before the
+// read pump, the baseline hides it.
+func (benchmark benchmarkStreamCase) drain(stream io.Reader) error {
+	if benchmark.baseline {
+		stream = struct{ io.Reader }{stream}
+	}
+	_, err := io.Copy(ioutil.Discard, stream)
+	return err
+}
+
+// startBenchmarkSession creates a test app and a started session using the
+// stream options of the case. The returned function destroys the session, the
+// device and the app.
+func startBenchmarkSession(b *testing.B, benchmark benchmarkStreamCase) (*core.Tanker, func()) {
+	config, err := helpers.LoadConfig()
+	if err != nil {
+		b.Fatal(err)
+	}
+	app, err := helpers.NewApp(*config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	device, err := app.CreateUser().CreateDevice()
+	if err != nil {
+		b.Fatal(err)
+	}
+	tanker, err := core.NewTanker(core.TankerOptions{
+		AppID:        device.AppID,
+		WritablePath: device.Path,
+		Url:          &device.Url,
+		Streams:      benchmark.options,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err = helpers.StartTankerSession(tanker, device.Identity); err != nil {
+		b.Fatal(err)
+	}
+	core.SetPerReadGoroutines(benchmark.baseline)
+	return tanker, func() {
+		core.SetPerReadGoroutines(false)
+		tanker.Stop()    // nolint: errcheck
+		tanker.Destroy() // nolint: errcheck
+		device.Destroy() // nolint: errcheck
+		app.Destroy()    // nolint: errcheck
+	}
+}
+
+func BenchmarkStreamEncrypt(b *testing.B) {
+	clearData := helpers.RandomBytes(benchmarkStreamSize)
+	for _, benchmark := range benchmarkStreamCases {
+		b.Run(benchmark.name, func(b *testing.B) {
+			tanker, stop := startBenchmarkSession(b, benchmark)
+			defer stop()
+			b.SetBytes(benchmarkStreamSize)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				stream, err := tanker.StreamEncrypt(bytes.NewReader(clearData), nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err = benchmark.drain(stream); err != nil {
+					b.Fatal(err)
+				}
+				stream.Destroy()
+			}
+		})
+	}
+}
+
+func BenchmarkStreamDecrypt(b *testing.B) {
+	clearData := helpers.RandomBytes(benchmarkStreamSize)
+	for _, benchmark := range benchmarkStreamCases {
+		b.Run(benchmark.name, func(b *testing.B) {
+			tanker, stop := startBenchmarkSession(b, benchmark)
+			defer stop()
+			stream, err := tanker.StreamEncrypt(bytes.NewReader(clearData), nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			encryptedData, err := ioutil.ReadAll(stream)
+			stream.Destroy()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(benchmarkStreamSize)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				stream, err := tanker.StreamDecrypt(bytes.NewReader(encryptedData))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err = benchmark.drain(stream); err != nil {
+					b.Fatal(err)
+				}
+				stream.Destroy()
+			}
+		})
+	}
+}
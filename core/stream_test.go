@@ -2,10 +2,17 @@ package core_test
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
+	"errors"
 	"io"
 	"io/ioutil"
+	"runtime"
+	"sync/atomic"
+	"testing/iotest"
+	"time"
 
+	"github.com/TankerHQ/sdk-go/v2/core"
 	"github.com/TankerHQ/sdk-go/v2/helpers"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -15,6 +22,27 @@ func createBigStream(size int) *bytes.Reader {
 	return bytes.NewReader(helpers.RandomBytes(size))
 }
 
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// gatedReader blocks its reads until the gate is closed.
+type gatedReader struct {
+	reader io.Reader
+	gate   chan struct{}
+	reads  int32
+}
+
+func (r *gatedReader) Read(buffer []byte) (int, error) {
+	<-r.gate
+	atomic.AddInt32(&r.reads, 1)
+	return r.reader.Read(buffer)
+}
+
 var _ = Describe("Streams", func() {
 	It("Encrypts and decrypts with stream", func() {
 		source := createBigStream((1024 * 1024 * 3))
@@ -51,4 +79,145 @@ var _ = Describe("Streams", func() {
 		Expect(*id).ToNot(BeEmpty())
 		encryptedStream.Destroy()
 	})
+
+	It("Encrypts and decrypts with small reads and custom stream options", func() {
+		clearData := helpers.RandomBytes(1024*64 + 13)
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, err := core.NewTanker(core.TankerOptions{
+			AppID:        aliceLaptop.AppID,
+			WritablePath: aliceLaptop.Path,
+			Url:          &aliceLaptop.Url,
+			Streams:      core.StreamOptions{ChunkSize: 1000, ReadAhead: -1},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = helpers.StartTankerSession(aliceSession, alice.Identity)
+		Expect(err).ToNot(HaveOccurred())
+		defer aliceSession.Stop() // nolint: errCheck
+		encryptedStream, err := aliceSession.StreamEncrypt(iotest.OneByteReader(bytes.NewReader(clearData)), nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer encryptedStream.Destroy()
+		decryptedStream, err := aliceSession.StreamDecrypt(iotest.HalfReader(encryptedStream))
+		Expect(err).ToNot(HaveOccurred())
+		defer decryptedStream.Destroy()
+		decrypted, err := ioutil.ReadAll(iotest.OneByteReader(decryptedStream))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decrypted).To(Equal(clearData))
+	})
+
+	It("Reports the errors of the source", func() {
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		defer aliceSession.Stop() // nolint: errCheck
+		sourceErr := errors.New("source failure")
+		source := io.MultiReader(createBigStream(1024), failingReader{sourceErr})
+		encryptedStream, err := aliceSession.StreamEncrypt(source, nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer encryptedStream.Destroy()
+		_, err = ioutil.ReadAll(encryptedStream)
+		Expect(err).To(MatchError(sourceErr))
+	})
+
+	It("Fails when the source makes no progress", func() {
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		defer aliceSession.Stop() // nolint: errCheck
+		// A source whose reads return neither data nor an error
+		source := io.MultiReader(createBigStream(1024), failingReader{nil})
+		encryptedStream, err := aliceSession.StreamEncrypt(source, nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer encryptedStream.Destroy()
+		_, err = ioutil.ReadAll(encryptedStream)
+		Expect(err).To(MatchError(io.ErrNoProgress))
+	})
+
+	It("Completes an abandoned decryption once its header is read", func() {
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		defer aliceSession.Stop() // nolint: errCheck
+		encrypted, err := aliceSession.Encrypt(helpers.RandomBytes(1024), nil)
+		Expect(err).ToNot(HaveOccurred())
+		goroutines := runtime.NumGoroutine()
+
+		source := &gatedReader{reader: bytes.NewReader(encrypted), gate: make(chan struct{})}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = aliceSession.StreamDecryptContext(ctx, source)
+		expectErrorCode(err, core.ErrorOperationCanceled)
+		Expect(atomic.LoadInt32(&source.reads)).To(BeZero())
+
+		// The pump still answers the reads of the header, so that the creation
+		// completes and the stream is closed
+		close(source.gate)
+		Eventually(func() int32 { return atomic.LoadInt32(&source.reads) }).ShouldNot(BeZero())
+		Eventually(runtime.NumGoroutine, 5*time.Second).Should(BeNumerically("<=", goroutines))
+	})
+
+	It("Copies a stream and fails to read it once closed", func() {
+		clearData := helpers.RandomBytes(1024*1024*2 + 7)
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		defer aliceSession.Stop() // nolint: errCheck
+		encryptedStream, err := aliceSession.StreamEncrypt(bytes.NewReader(clearData), nil)
+		Expect(err).ToNot(HaveOccurred())
+		var encrypted bytes.Buffer
+		_, err = io.Copy(&encrypted, encryptedStream)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encryptedStream.Close()).To(Succeed())
+		Expect(encryptedStream.Close()).To(Succeed())
+
+		decryptedStream, err := aliceSession.StreamDecrypt(&encrypted)
+		Expect(err).ToNot(HaveOccurred())
+		var decrypted bytes.Buffer
+		Expect(decryptedStream.(io.WriterTo).WriteTo(&decrypted)).To(Equal(int64(len(clearData))))
+		Expect(decrypted.Bytes()).To(Equal(clearData))
+		Expect(decryptedStream.Close()).To(Succeed())
+
+		_, err = decryptedStream.Read(make([]byte, 10))
+		expectErrorCode(err, core.ErrorPreconditionFailed)
+		_, err = decryptedStream.GetResourceID()
+		expectErrorCode(err, core.ErrorPreconditionFailed)
+		decryptedStream.Destroy()
+	})
+
+	It("Reports the progress of a stream", func() {
+		clearData := helpers.RandomBytes(1024*1024*3 + 5)
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		var reports []core.StreamStats
+		aliceSession, err := core.NewTanker(core.TankerOptions{
+			AppID:        aliceLaptop.AppID,
+			WritablePath: aliceLaptop.Path,
+			Url:          &aliceLaptop.Url,
+			Streams: core.StreamOptions{Progress: func(stats core.StreamStats) {
+				reports = append(reports, stats)
+			}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = helpers.StartTankerSession(aliceSession, alice.Identity)
+		Expect(err).ToNot(HaveOccurred())
+		defer aliceSession.Stop() // nolint: errCheck
+		encryptedStream, err := aliceSession.StreamEncrypt(bytes.NewReader(clearData), nil)
+		Expect(err).ToNot(HaveOccurred())
+		encrypted, err := ioutil.ReadAll(encryptedStream)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encryptedStream.Close()).To(Succeed())
+
+		stats := encryptedStream.Stats()
+		Expect(stats.BytesRead).To(Equal(int64(len(clearData))))
+		Expect(stats.BytesProduced).To(Equal(int64(len(encrypted))))
+		Expect(stats.Chunks).To(BeNumerically(">", 1))
+		Expect(stats.Elapsed).To(BeNumerically(">", 0))
+		Expect(encryptedStream.Stats().Elapsed).To(Equal(stats.Elapsed))
+		Expect(reports).ToNot(BeEmpty())
+		last := reports[len(reports)-1]
+		Expect(last.BytesProduced).To(Equal(stats.BytesProduced))
+		for i := 1; i < len(reports); i++ {
+			Expect(reports[i].BytesProduced).To(BeNumerically(">=", reports[i-1].BytesProduced))
+		}
+	})
 })
@@ -5,106 +5,155 @@ package core
 #include <stdlib.h>
 */
 import "C"
-import "unsafe"
+import (
+	"context"
+	"fmt"
+	"unsafe"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
 
 // This enumeration represents the different identity verification methods available.
-type VerificationMethodType uint32
+type VerificationMethodType = coretypes.VerificationMethodType
 
 const (
-	VerificationMethodEmail           VerificationMethodType = C.TANKER_VERIFICATION_METHOD_EMAIL
-	VerificationMethodPassphrase      VerificationMethodType = C.TANKER_VERIFICATION_METHOD_PASSPHRASE
-	VerificationMethodVerificationKey VerificationMethodType = C.TANKER_VERIFICATION_METHOD_VERIFICATION_KEY
-	VerificationMethodOidcIdToken     VerificationMethodType = C.TANKER_VERIFICATION_METHOD_OIDC_ID_TOKEN
+	VerificationMethodEmail           = coretypes.VerificationMethodEmail
+	VerificationMethodPassphrase      = coretypes.VerificationMethodPassphrase
+	VerificationMethodVerificationKey = coretypes.VerificationMethodVerificationKey
+	VerificationMethodOidcIdToken     = coretypes.VerificationMethodOidcIdToken
+)
+
+// The verification methods must match the ones of the native library
+var (
+	_ = [1]struct{}{}[VerificationMethodEmail-C.TANKER_VERIFICATION_METHOD_EMAIL]
+	_ = [1]struct{}{}[VerificationMethodPassphrase-C.TANKER_VERIFICATION_METHOD_PASSPHRASE]
+	_ = [1]struct{}{}[VerificationMethodVerificationKey-C.TANKER_VERIFICATION_METHOD_VERIFICATION_KEY]
+	_ = [1]struct{}{}[VerificationMethodOidcIdToken-C.TANKER_VERIFICATION_METHOD_OIDC_ID_TOKEN]
 )
 
 // VerificationMethod describes a type of method registered on the Tanker Server by a user.
 // It may contains the email registered if Type is a VerificationMethodEmail.
-type VerificationMethod struct {
-	Type  VerificationMethodType
-	Email *string
-}
+type VerificationMethod = coretypes.VerificationMethod
 
 // AttachResult is returned by AttachProvisionalIdentity(). It contains a Tanker
 // Status and the verificationMethod the user is required to use to register the identity provided.
-type AttachResult struct {
-	Status Status
-	Method *VerificationMethod
-}
+type AttachResult = coretypes.AttachResult
+
+// Verification is the interface of the verifications accepted by RegisterIdentity(),
+// VerifyIdentity(), SetVerificationMethod() and VerifyProvisionalIdentity().
+// It is implemented by EmailVerification, PassphraseVerification, KeyVerification
+// and OidcVerification, both as values and as pointers. It cannot be implemented
+// outside of the coretypes package.
+type Verification = coretypes.Verification
 
 // An Email Verification. The VerificationCode is provided to the user
 // by email and must be used here with the Email address.
-type EmailVerification struct {
-	Email            string
-	VerificationCode string
-}
+type EmailVerification = coretypes.EmailVerification
 
 // An Password Verification. The verificaiton password registered by the user
 // should be used here.
-type PassphraseVerification struct {
-	Passphrase string
-}
+type PassphraseVerification = coretypes.PassphraseVerification
 
 // A KeyVerification. The unlock key generated by the user should be used here.
-type KeyVerification struct {
-	Key string
-}
+type KeyVerification = coretypes.KeyVerification
 
 // A OidcVerification. The Open ID Connect ID token registered
 // by the user should be used here.
-type OidcVerification struct {
-	OidcIdToken string
-}
+type OidcVerification = coretypes.OidcVerification
 
-// Converts a Verificaton* to the C tanker type
-func convertVerificationToTanker(verif interface{}) *C.tanker_verification_t {
-	result := &C.tanker_verification_t{
-		version: 3,
+// Converts a Verificaton* to the C tanker type, owned by args. Unsupported verifications,
+// such as nil pointers, are rejected with an ErrorInvalidArgument error.
+func convertVerificationToTanker(args *nativeArgs, verif Verification) (*C.tanker_verification_t, error) {
+	switch t := verif.(type) {
+	case *EmailVerification:
+		if t != nil {
+			return convertVerificationToTanker(args, *t)
+		}
+	case *PassphraseVerification:
+		if t != nil {
+			return convertVerificationToTanker(args, *t)
+		}
+	case *KeyVerification:
+		if t != nil {
+			return convertVerificationToTanker(args, *t)
+		}
+	case *OidcVerification:
+		if t != nil {
+			return convertVerificationToTanker(args, *t)
+		}
 	}
 
+	result := (*C.tanker_verification_t)(args.alloc(C.sizeof_tanker_verification_t))
+	result.version = 3
 	switch t := verif.(type) {
 	case EmailVerification:
 		result.verification_method_type = C.TANKER_VERIFICATION_METHOD_EMAIL
 		result.email_verification = C.tanker_email_verification_t{
 			version:           1,
-			email:             C.CString(t.Email),
-			verification_code: C.CString(t.VerificationCode),
+			email:             args.cString(t.Email),
+			verification_code: args.secretCString(t.VerificationCode),
 		}
 	case PassphraseVerification:
+		passphrase, err := secretCString(args, t.Passphrase, t.SecretPassphrase)
+		if err != nil {
+			return nil, err
+		}
 		result.verification_method_type = C.TANKER_VERIFICATION_METHOD_PASSPHRASE
-		result.passphrase = C.CString(t.Passphrase)
+		result.passphrase = passphrase
 	case KeyVerification:
+		key, err := secretCString(args, t.Key, t.SecretKey)
+		if err != nil {
+			return nil, err
+		}
 		result.verification_method_type = C.TANKER_VERIFICATION_METHOD_VERIFICATION_KEY
-		result.verification_key = C.CString(t.Key)
+		result.verification_key = key
 	case OidcVerification:
 		result.verification_method_type = C.TANKER_VERIFICATION_METHOD_OIDC_ID_TOKEN
-		result.oidc_id_token = C.CString(t.OidcIdToken)
+		result.oidc_id_token = args.secretCString(t.OidcIdToken)
+	case nil:
+		return nil, newError(ErrorInvalidArgument, "", "verification must not be nil")
+	default:
+		return nil, newError(ErrorInvalidArgument, "", fmt.Sprintf("unsupported verification: nil %T", verif))
 	}
-	return result
+	return result, nil
 }
 
-// Frees the C verification content
-func freeVerif(verif *C.tanker_verification_t) {
-	switch verif.verification_method_type {
-	case C.TANKER_VERIFICATION_METHOD_EMAIL:
-		C.free(unsafe.Pointer(verif.email_verification.email))
-		C.free(unsafe.Pointer(verif.email_verification.verification_code))
-	case C.TANKER_VERIFICATION_METHOD_PASSPHRASE:
-		C.free(unsafe.Pointer(verif.passphrase))
-	case C.TANKER_VERIFICATION_METHOD_VERIFICATION_KEY:
-		C.free(unsafe.Pointer(verif.verification_key))
-	case C.TANKER_VERIFICATION_METHOD_OIDC_ID_TOKEN:
-		C.free(unsafe.Pointer(verif.oidc_id_token))
+// secretCString returns a C copy of secret, or of secretBuffer if it is set,
+// owned by args which zeroes it when it is freed.
+func secretCString(args *nativeArgs, secret string, secretBuffer *SecretBuffer) (*C.char, error) {
+	if secretBuffer == nil {
+		return args.secretCString(secret), nil
+	}
+	data := secretBuffer.Bytes()
+	if data == nil {
+		return nil, newError(ErrorInvalidArgument, "", "the secret buffer has been destroyed")
 	}
+	copied := secretCopy(data)
+	args.onRelease(func() { freeSecret(copied) })
+	return copied, nil
 }
 
 // RegisterIdentity registers an identity to be unlocked with the provided
 // verification, the one used in Start().
 // Tanker's status must be StatusIdentityRegistrationNeeded.
-func (t *Tanker) RegisterIdentity(verification interface{}) error {
-	cverif := convertVerificationToTanker(verification)
-	defer freeVerif(cverif)
+func (t *Tanker) RegisterIdentity(verification Verification) error {
+	return t.RegisterIdentityContext(context.Background(), verification)
+}
 
-	_, err := await(C.tanker_register_identity(t.instance, cverif))
+// RegisterIdentityContext is like RegisterIdentity but stops waiting when ctx is done.
+func (t *Tanker) RegisterIdentityContext(ctx context.Context, verification Verification) (err error) {
+	observation := observe(t.observer, "RegisterIdentity", 0, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	cverif, err := convertVerificationToTanker(args, verification)
+	if err != nil {
+		return withOperation(err, "RegisterIdentity")
+	}
+
+	_, err = awaitContext(ctx, "RegisterIdentity", args, func() *C.tanker_future_t {
+		return C.tanker_register_identity(t.instance, cverif)
+	}, nil)
 	return err
 }
 
@@ -112,18 +161,44 @@ func (t *Tanker) RegisterIdentity(verification interface{}) error {
 // and starts the session. This function verifies the user's identity based on the
 // provided verification. It must be called when the user has started a Tanker
 // session on a new device.
-func (t *Tanker) VerifyIdentity(verification interface{}) error {
-	cverif := convertVerificationToTanker(verification)
-	defer freeVerif(cverif)
-	_, err := await(C.tanker_verify_identity(t.instance, cverif))
+func (t *Tanker) VerifyIdentity(verification Verification) error {
+	return t.VerifyIdentityContext(context.Background(), verification)
+}
+
+// VerifyIdentityContext is like VerifyIdentity but stops waiting when ctx is done.
+func (t *Tanker) VerifyIdentityContext(ctx context.Context, verification Verification) (err error) {
+	observation := observe(t.observer, "VerifyIdentity", 0, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	cverif, err := convertVerificationToTanker(args, verification)
+	if err != nil {
+		return withOperation(err, "VerifyIdentity")
+	}
+	_, err = awaitContext(ctx, "VerifyIdentity", args, func() *C.tanker_future_t {
+		return C.tanker_verify_identity(t.instance, cverif)
+	}, nil)
 	return err
 }
 
 // SetVerificationMethod sets up the provided Verification for the user.
-func (t *Tanker) SetVerificationMethod(verification interface{}) error {
-	cverif := convertVerificationToTanker(verification)
-	defer freeVerif(cverif)
-	_, err := await(C.tanker_set_verification_method(t.instance, cverif))
+func (t *Tanker) SetVerificationMethod(verification Verification) error {
+	return t.SetVerificationMethodContext(context.Background(), verification)
+}
+
+// SetVerificationMethodContext is like SetVerificationMethod but stops waiting when ctx is done.
+func (t *Tanker) SetVerificationMethodContext(ctx context.Context, verification Verification) (err error) {
+	observation := observe(t.observer, "SetVerificationMethod", 0, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	cverif, err := convertVerificationToTanker(args, verification)
+	if err != nil {
+		return withOperation(err, "SetVerificationMethod")
+	}
+	_, err = awaitContext(ctx, "SetVerificationMethod", args, func() *C.tanker_future_t {
+		return C.tanker_set_verification_method(t.instance, cverif)
+	}, nil)
 	return err
 }
 
@@ -143,9 +218,25 @@ func convertVerificationMethodToTanker(cmethod *C.tanker_verification_method_t)
 }
 
 // GetVerificationMethods returns all the user verification methods available to the user.
-// Those have been registered through a call RegisterIdentity(), or SetVerificationMethod()
+// Those have been registered through a call RegisterIdentity(), or SetVerificationMethod().
+// It is retried according to TankerOptions.Retry.
 func (t *Tanker) GetVerificationMethods() ([]VerificationMethod, error) {
-	result, err := await(C.tanker_get_verification_methods(t.instance))
+	return t.GetVerificationMethodsContext(context.Background())
+}
+
+// GetVerificationMethodsContext is like GetVerificationMethods but stops waiting when ctx is done.
+func (t *Tanker) GetVerificationMethodsContext(ctx context.Context) (_ []VerificationMethod, err error) {
+	observation := observe(t.observer, "GetVerificationMethods", 0, 0)
+	defer func() { observation.end(0, err) }()
+	var result unsafe.Pointer
+	err = doWithRetry(ctx, t.retry, "GetVerificationMethods", func() (err error) {
+		result, err = awaitContext(ctx, "GetVerificationMethods", nil, func() *C.tanker_future_t {
+			return C.tanker_get_verification_methods(t.instance)
+		}, func(result unsafe.Pointer) {
+			C.tanker_free_verification_method_list((*C.tanker_verification_method_list_t)(result))
+		})
+		return
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -163,9 +254,21 @@ func (t *Tanker) GetVerificationMethods() ([]VerificationMethod, error) {
 // AttachProvisionalIdentity attaches a provisional identity to the current user and returns an AttachResult.
 // Depending on the result, you may have to call VerifyProvisionalIdentity() to finish the process.
 func (t *Tanker) AttachProvisionalIdentity(provisionalIdentity string) (*AttachResult, error) {
-	cidentity := C.CString(provisionalIdentity)
-	defer C.free(unsafe.Pointer(cidentity))
-	result, err := await(C.tanker_attach_provisional_identity(t.instance, cidentity))
+	return t.AttachProvisionalIdentityContext(context.Background(), provisionalIdentity)
+}
+
+// AttachProvisionalIdentityContext is like AttachProvisionalIdentity but stops waiting when ctx is done.
+func (t *Tanker) AttachProvisionalIdentityContext(ctx context.Context, provisionalIdentity string) (_ *AttachResult, err error) {
+	observation := observe(t.observer, "AttachProvisionalIdentity", 0, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	cidentity := args.secretCString(provisionalIdentity)
+	result, err := awaitContext(ctx, "AttachProvisionalIdentity", args, func() *C.tanker_future_t {
+		return C.tanker_attach_provisional_identity(t.instance, cidentity)
+	}, func(result unsafe.Pointer) {
+		C.tanker_free_attach_result((*C.tanker_attach_result_t)(result))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -182,8 +285,23 @@ func (t *Tanker) AttachProvisionalIdentity(provisionalIdentity string) (*AttachR
 // VerifyProvisionalIdentity verifies an attached provisional identity. Once the provisional identity is verified, every
 // resource shared with it can now be decrypted by the user. They also join every group in which the
 // provisional identity was a member.
-func (t *Tanker) VerifyProvisionalIdentity(verification interface{}) error {
-	_, err := await(C.tanker_verify_provisional_identity(t.instance, convertVerificationToTanker(verification)))
+func (t *Tanker) VerifyProvisionalIdentity(verification Verification) error {
+	return t.VerifyProvisionalIdentityContext(context.Background(), verification)
+}
+
+// VerifyProvisionalIdentityContext is like VerifyProvisionalIdentity but stops waiting when ctx is done.
+func (t *Tanker) VerifyProvisionalIdentityContext(ctx context.Context, verification Verification) (err error) {
+	observation := observe(t.observer, "VerifyProvisionalIdentity", 0, 0)
+	defer func() { observation.end(0, err) }()
+	args := &nativeArgs{}
+	defer args.release()
+	cverif, err := convertVerificationToTanker(args, verification)
+	if err != nil {
+		return withOperation(err, "VerifyProvisionalIdentity")
+	}
+	_, err = awaitContext(ctx, "VerifyProvisionalIdentity", args, func() *C.tanker_future_t {
+		return C.tanker_verify_provisional_identity(t.instance, cverif)
+	}, nil)
 	return err
 }
 
@@ -192,10 +310,19 @@ func (t *Tanker) VerifyProvisionalIdentity(verification interface{}) error {
 //
 // This is a low level function for specific use-cases only.
 func (t *Tanker) GenerateVerificationKey() (*string, error) {
-	result, err := await(C.tanker_generate_verification_key(t.instance))
+	return t.GenerateVerificationKeyContext(context.Background())
+}
+
+// GenerateVerificationKeyContext is like GenerateVerificationKey but stops waiting when ctx is done.
+func (t *Tanker) GenerateVerificationKeyContext(ctx context.Context) (_ *string, err error) {
+	observation := observe(t.observer, "GenerateVerificationKey", 0, 0)
+	defer func() { observation.end(0, err) }()
+	result, err := awaitContext(ctx, "GenerateVerificationKey", nil, func() *C.tanker_future_t {
+		return C.tanker_generate_verification_key(t.instance)
+	}, releaseSecret)
 	if err != nil {
 		return nil, err
 	}
-	key := unsafeANSIToString(result)
+	key := unsafeSecretToString(result)
 	return &key, nil
 }
@@ -45,7 +45,7 @@ func getOidcIdToken(oidcConfig helpers.OidcConfig, userName string) (*string, er
 	return &id_token, nil
 }
 
-func doVerification(tanker *core.Tanker, identity string, verification interface{}) (core.Status, error) {
+func doVerification(tanker *core.Tanker, identity string, verification core.Verification) (core.Status, error) {
 	status, err := tanker.Start(identity)
 	if err != nil {
 		return 0, err
@@ -84,6 +84,37 @@ var _ = Describe("functional", func() {
 		Expect(methods).To(HaveVerificationMethods(core.VerificationMethod{Type: core.VerificationMethodPassphrase}))
 	})
 
+	It("Accepts pointer verifications and rejects nil ones", func() {
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		session, _ := aliceLaptop.Start()
+		defer session.Stop() // nolint: errCheck
+		alicePhone, _ := alice.CreateDevice()
+		phoneSession, _ := alicePhone.CreateSession()
+		defer phoneSession.Stop() // nolint: errCheck
+
+		var verification *core.PassphraseVerification
+		_, err := doVerification(phoneSession, alice.Identity, verification)
+		terror, ok := err.(core.Error)
+		Expect(ok).To(BeTrue())
+		Expect(terror.Code()).To(Equal(core.ErrorInvalidArgument))
+		Expect(phoneSession.VerifyIdentity(&core.PassphraseVerification{Passphrase: "multipass"})).To(Succeed())
+	})
+
+	It("Verifies with a secret passphrase", func() {
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		session, _ := aliceLaptop.Start()
+		defer session.Stop() // nolint: errCheck
+		alicePhone, _ := alice.CreateDevice()
+		phoneSession, _ := alicePhone.CreateSession()
+		defer phoneSession.Stop() // nolint: errCheck
+
+		passphrase := core.NewSecretBufferFrom([]byte("multipass"))
+		defer passphrase.Destroy()
+		Expect(doVerification(phoneSession, alice.Identity, core.PassphraseVerification{SecretPassphrase: passphrase})).To(Equal(core.StatusReady))
+	})
+
 	It("Set verification method", func() {
 		alice := TestApp.CreateUser()
 		aliceEmail := "alice.test@tanker.io"
@@ -131,10 +162,10 @@ var _ = Describe("functional", func() {
 
 			martineIdToken, err := getOidcIdToken(TestApp.OidcConfig, "martine")
 			Expect(err).ToNot(HaveOccurred())
-			martineOidcVerification = core.OidcVerification{*martineIdToken}
+			martineOidcVerification = core.OidcVerification{OidcIdToken: *martineIdToken}
 			kevinIdToken, err := getOidcIdToken(TestApp.OidcConfig, "kevin")
 			Expect(err).ToNot(HaveOccurred())
-			kevinOidcVerification = core.OidcVerification{*kevinIdToken}
+			kevinOidcVerification = core.OidcVerification{OidcIdToken: *kevinIdToken}
 		})
 
 		AfterEach(func() {
@@ -154,7 +185,7 @@ var _ = Describe("functional", func() {
 		})
 
 		It("Updates and verifies with an oidc token", func() {
-			Expect(martineLaptop.RegisterIdentity(core.PassphraseVerification{"*****"})).To(Succeed())
+			Expect(martineLaptop.RegisterIdentity(core.PassphraseVerification{Passphrase: "*****"})).To(Succeed())
 			Expect(martineLaptop.SetVerificationMethod(martineOidcVerification)).To(Succeed())
 			Expect(martinePhone.Start(martine.Identity)).To(Equal(core.StatusIdentityVerificationNeeded))
 			Expect(martinePhone.VerifyIdentity(martineOidcVerification)).To(Succeed())
@@ -172,7 +203,7 @@ var _ = Describe("functional", func() {
 			aliceLaptop, _ := aliceDevice.Start()
 			defer aliceLaptop.Stop() // nolint: errCheck
 
-			Expect(martineLaptop.RegisterIdentity(core.PassphraseVerification{"*****"})).To(Succeed())
+			Expect(martineLaptop.RegisterIdentity(core.PassphraseVerification{Passphrase: "*****"})).To(Succeed())
 			martineEmail := TestApp.OidcConfig.Users["martine"].Email
 			martineProvisionalIdentity, _ := identity.CreateProvisional(TestApp.IdConfig, martineEmail)
 			martinePublicIdentity, _ := identity.GetPublicIdentity(*martineProvisionalIdentity)
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"io"
+)
+
+// InputStream is returned by NewEncryptWriter() and NewDecryptWriter().
+// It satisfies io.WriteCloser: the data written to it is encrypted or decrypted
+// and written to the destination writer. Close() must be called to flush the
+// last chunk, the destination writer is not closed.
+type InputStream struct {
+	pipe *io.PipeWriter
+
+	// ready is closed once the header has been processed, or the stream failed
+	ready      chan struct{}
+	resourceID *string
+	headerErr  error
+
+	// done is closed once everything has been written to the destination
+	done chan struct{}
+	err  error
+}
+
+// newInputStream pipes the data written to the returned InputStream to the
+// stream created by open, and copies the stream output to dst.
+func newInputStream(reader *io.PipeReader, writer *io.PipeWriter, dst io.Writer, open func(io.Reader) (Stream, error)) *InputStream {
+	s := &InputStream{
+		pipe:  writer,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go s.run(reader, dst, open)
+	return s
+}
+
+func (s *InputStream) run(reader *io.PipeReader, dst io.Writer, open func(io.Reader) (Stream, error)) {
+	defer close(s.done)
+	stream, err := open(reader)
+	if err == nil {
+		defer stream.Destroy()
+		s.resourceID, err = stream.GetResourceID()
+	}
+	s.headerErr = err
+	close(s.ready)
+	if err == nil {
+		_, err = io.Copy(dst, stream)
+	}
+	s.err = err
+	// Unblock and fail the pending and future writes
+	if err != nil {
+		reader.CloseWithError(err)
+	} else {
+		reader.Close()
+	}
+}
+
+// Write writes data to encrypt or decrypt. It fails with the error of the
+// stream or of the destination writer, if any.
+func (s *InputStream) Write(data []byte) (int, error) {
+	n, err := s.pipe.Write(data)
+	if err == io.ErrClosedPipe {
+		select {
+		case <-s.done:
+			if s.err != nil {
+				return n, s.err
+			}
+		default:
+		}
+		return n, newError(ErrorPreconditionFailed, "Write", "the stream has been closed")
+	}
+	return n, err
+}
+
+// Close flushes the last chunk to the destination writer and waits until
+// it has been written. It returns the first error encountered by the stream.
+func (s *InputStream) Close() error {
+	s.pipe.Close()
+	<-s.done
+	return s.err
+}
+
+// GetResourceID returns the resource ID of the stream. It blocks until
+// the header has been written, for decryption streams this happens once
+// enough data has been written, or the stream has been closed.
+func (s *InputStream) GetResourceID() (*string, error) {
+	<-s.ready
+	return s.resourceID, s.headerErr
+}
+
+// NewEncryptWriter creates an InputStream encrypting the data written to it, and
+// writing the encrypted data to dst. The data will be shared according to the
+// EncryptionOptions passed.
+//
+//	writer, err := tanker.NewEncryptWriter(file, nil)
+//	if err != nil {
+//		return err
+//	}
+//	if err = json.NewEncoder(writer).Encode(document); err != nil {
+//		writer.Close() // nolint: errcheck
+//		return err
+//	}
+//	return writer.Close()
+func (t *Tanker) NewEncryptWriter(dst io.Writer, options *EncryptionOptions) (StreamWriter, error) {
+	return t.NewEncryptWriterContext(context.Background(), dst, options)
+}
+
+// NewEncryptWriterContext is like NewEncryptWriter but stops waiting for the stream creation when ctx is done.
+// Writing to the returned InputStream is not affected by ctx.
+func (t *Tanker) NewEncryptWriterContext(ctx context.Context, dst io.Writer, options *EncryptionOptions) (StreamWriter, error) {
+	return newEncryptWriter(dst, func(reader io.Reader) (Stream, error) {
+		return t.StreamEncryptContext(ctx, reader, options)
+	})
+}
+
+// NewEncryptWriter creates an InputStream encrypting the data written to it with the
+// encryption session, and writing the encrypted data to dst.
+func (s *EncryptionSession) NewEncryptWriter(dst io.Writer) (StreamWriter, error) {
+	return s.NewEncryptWriterContext(context.Background(), dst)
+}
+
+// NewEncryptWriterContext is like NewEncryptWriter but stops waiting for the stream creation when ctx is done.
+// Writing to the returned InputStream is not affected by ctx.
+func (s *EncryptionSession) NewEncryptWriterContext(ctx context.Context, dst io.Writer) (StreamWriter, error) {
+	return newEncryptWriter(dst, func(reader io.Reader) (Stream, error) {
+		return s.StreamEncryptContext(ctx, reader)
+	})
+}
+
+// newEncryptWriter creates the encryption stream before returning, so that
+// its creation errors are reported by NewEncryptWriter().
+func newEncryptWriter(dst io.Writer, create func(io.Reader) (Stream, error)) (StreamWriter, error) {
+	reader, writer := io.Pipe()
+	stream, err := create(reader)
+	if err != nil {
+		return nil, err
+	}
+	return newInputStream(reader, writer, dst, func(io.Reader) (Stream, error) {
+		return stream, nil
+	}), nil
+}
+
+// NewDecryptWriter creates an InputStream decrypting the data written to it, and
+// writing the clear data to dst. Decryption errors, such as a missing key, are
+// returned by the first call to Write() or Close() following them.
+func (t *Tanker) NewDecryptWriter(dst io.Writer) (StreamWriter, error) {
+	return t.NewDecryptWriterContext(context.Background(), dst)
+}
+
+// NewDecryptWriterContext is like NewDecryptWriter but stops waiting for the stream creation,
+// which happens once the header has been written, when ctx is done.
+func (t *Tanker) NewDecryptWriterContext(ctx context.Context, dst io.Writer) (StreamWriter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newCanceledError("NewDecryptWriter", err)
+	}
+	// The native library reads the header while creating the stream
+	reader, writer := io.Pipe()
+	return newInputStream(reader, writer, dst, func(reader io.Reader) (Stream, error) {
+		return t.StreamDecryptContext(ctx, reader)
+	}), nil
+}
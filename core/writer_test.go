@@ -0,0 +1,52 @@
+package core_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io"
+
+	"github.com/TankerHQ/sdk-go/v2/helpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream writers", func() {
+	It("Encrypts and decrypts with writers", func() {
+		clearData := helpers.RandomBytes(1024 * 1024 * 3)
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		defer aliceSession.Stop() // nolint: errCheck
+
+		var encrypted, decrypted bytes.Buffer
+		encryptor, err := aliceSession.NewEncryptWriter(&encrypted, nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = io.Copy(encryptor, bytes.NewReader(clearData))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encryptor.Close()).To(Succeed())
+		resourceID, err := encryptor.GetResourceID()
+		Expect(err).ToNot(HaveOccurred())
+
+		decryptor, err := aliceSession.NewDecryptWriter(&decrypted)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = io.Copy(decryptor, &encrypted)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decryptor.Close()).To(Succeed())
+		Expect(decryptor.GetResourceID()).To(Equal(resourceID))
+		Expect(sha1.Sum(decrypted.Bytes())).To(Equal(sha1.Sum(clearData)))
+	})
+
+	It("Fails to write to a closed writer", func() {
+		alice := TestApp.CreateUser()
+		aliceLaptop, _ := alice.CreateDevice()
+		aliceSession, _ := aliceLaptop.Start()
+		defer aliceSession.Stop() // nolint: errCheck
+
+		var encrypted bytes.Buffer
+		encryptor, err := aliceSession.NewEncryptWriter(&encrypted, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encryptor.Close()).To(Succeed())
+		_, err = encryptor.Write([]byte("too late"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,13 @@
+package coremock_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCoreMock(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Core Mock Test Suite")
+}
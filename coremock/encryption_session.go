@@ -0,0 +1,90 @@
+package coremock
+
+import (
+	"context"
+	"io"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// EncryptionSession is a programmable fake of core.EncryptionSessionClient.
+// Every call is recorded, and stubbing works as for Tanker.
+type EncryptionSession struct {
+	Recorder
+
+	// Err is returned by the operations which are not stubbed.
+	Err error
+	// ResourceID is returned by GetResourceId().
+	ResourceID string
+
+	EncryptFunc          func(ctx context.Context, clearData []byte) ([]byte, error)
+	StreamEncryptFunc    func(ctx context.Context, reader io.Reader) (coretypes.Stream, error)
+	NewEncryptWriterFunc func(ctx context.Context, dst io.Writer) (coretypes.StreamWriter, error)
+}
+
+var _ coretypes.EncryptionSessionClient = (*EncryptionSession)(nil)
+
+// Destroy only records the call.
+func (s *EncryptionSession) Destroy() {
+	s.record("Destroy")
+}
+
+// GetResourceId returns ResourceID.
+func (s *EncryptionSession) GetResourceId() string {
+	s.record("GetResourceId")
+	return s.ResourceID
+}
+
+// Encrypt calls EncryptFunc if set.
+func (s *EncryptionSession) Encrypt(clearData []byte) ([]byte, error) {
+	s.record("Encrypt", clearData)
+	if s.EncryptFunc != nil {
+		return s.EncryptFunc(context.Background(), clearData)
+	}
+	return nil, s.Err
+}
+
+// EncryptContext calls EncryptFunc if set.
+func (s *EncryptionSession) EncryptContext(ctx context.Context, clearData []byte) ([]byte, error) {
+	s.record("EncryptContext", clearData)
+	if s.EncryptFunc != nil {
+		return s.EncryptFunc(ctx, clearData)
+	}
+	return nil, s.Err
+}
+
+// StreamEncrypt calls StreamEncryptFunc if set.
+func (s *EncryptionSession) StreamEncrypt(reader io.Reader) (coretypes.Stream, error) {
+	s.record("StreamEncrypt", reader)
+	if s.StreamEncryptFunc != nil {
+		return s.StreamEncryptFunc(context.Background(), reader)
+	}
+	return nil, s.Err
+}
+
+// StreamEncryptContext calls StreamEncryptFunc if set.
+func (s *EncryptionSession) StreamEncryptContext(ctx context.Context, reader io.Reader) (coretypes.Stream, error) {
+	s.record("StreamEncryptContext", reader)
+	if s.StreamEncryptFunc != nil {
+		return s.StreamEncryptFunc(ctx, reader)
+	}
+	return nil, s.Err
+}
+
+// NewEncryptWriter calls NewEncryptWriterFunc if set.
+func (s *EncryptionSession) NewEncryptWriter(dst io.Writer) (coretypes.StreamWriter, error) {
+	s.record("NewEncryptWriter", dst)
+	if s.NewEncryptWriterFunc != nil {
+		return s.NewEncryptWriterFunc(context.Background(), dst)
+	}
+	return nil, s.Err
+}
+
+// NewEncryptWriterContext calls NewEncryptWriterFunc if set.
+func (s *EncryptionSession) NewEncryptWriterContext(ctx context.Context, dst io.Writer) (coretypes.StreamWriter, error) {
+	s.record("NewEncryptWriterContext", dst)
+	if s.NewEncryptWriterFunc != nil {
+		return s.NewEncryptWriterFunc(ctx, dst)
+	}
+	return nil, s.Err
+}
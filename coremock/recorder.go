@@ -0,0 +1,56 @@
+package coremock
+
+import "sync"
+
+// Call records a call made on a fake: the name of the method called
+// and its arguments, context.Context arguments excepted.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Recorder records the calls made on a fake. It is safe for concurrent use.
+type Recorder struct {
+	mutex sync.Mutex
+	calls []Call
+}
+
+func (r *Recorder) record(method string, args ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns every call recorded so far, in order.
+func (r *Recorder) Calls() []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	calls := make([]Call, len(r.calls))
+	copy(calls, r.calls)
+	return calls
+}
+
+// CallsTo returns the calls recorded so far to the given method, in order.
+func (r *Recorder) CallsTo(method string) []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var calls []Call
+	for _, call := range r.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// CallCount returns the number of calls recorded so far to the given method.
+func (r *Recorder) CallCount(method string) int {
+	return len(r.CallsTo(method))
+}
+
+// Reset forgets every call recorded so far.
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = nil
+}
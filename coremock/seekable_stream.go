@@ -0,0 +1,41 @@
+package coremock
+
+import (
+	"bytes"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// SeekableStream is a programmable fake of core.SeekableStream. Every call is
+// recorded. Data is read from the embedded bytes.Reader over canned clear data.
+type SeekableStream struct {
+	Recorder
+	*bytes.Reader
+
+	// ResourceID is returned by GetResourceID() unless Err is set.
+	ResourceID string
+	// Err is returned by GetResourceID().
+	Err error
+}
+
+var _ coretypes.SeekableStream = (*SeekableStream)(nil)
+
+// NewSeekableStream creates a SeekableStream over data, with the given resource ID.
+func NewSeekableStream(data []byte, resourceID string) *SeekableStream {
+	return &SeekableStream{Reader: bytes.NewReader(data), ResourceID: resourceID}
+}
+
+// Destroy only records the call.
+func (s *SeekableStream) Destroy() {
+	s.record("Destroy")
+}
+
+// GetResourceID returns ResourceID, or Err if it is set.
+func (s *SeekableStream) GetResourceID() (*string, error) {
+	s.record("GetResourceID")
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	resourceID := s.ResourceID
+	return &resourceID, nil
+}
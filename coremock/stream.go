@@ -0,0 +1,69 @@
+package coremock
+
+import (
+	"io"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// Stream is a programmable fake of core.Stream. Every call is recorded.
+// Data is read from Reader, which is typically a *bytes.Reader over canned
+// encrypted or clear data.
+type Stream struct {
+	Recorder
+
+	// Reader is the data source of the stream. A nil Reader is an empty stream.
+	Reader io.Reader
+	// ResourceID is returned by GetResourceID() unless Err is set.
+	ResourceID string
+	// Err is returned by GetResourceID() and Close().
+	Err error
+	// Closed is set by Close().
+	Closed bool
+	// StreamStats is returned by Stats().
+	StreamStats coretypes.StreamStats
+}
+
+var _ coretypes.Stream = (*Stream)(nil)
+
+// NewStream creates a Stream reading from reader, with the given resource ID.
+func NewStream(reader io.Reader, resourceID string) *Stream {
+	return &Stream{Reader: reader, ResourceID: resourceID}
+}
+
+// Read reads from Reader.
+func (s *Stream) Read(buffer []byte) (int, error) {
+	s.record("Read", len(buffer))
+	if s.Reader == nil {
+		return 0, io.EOF
+	}
+	return s.Reader.Read(buffer)
+}
+
+// Close sets Closed and returns Err.
+func (s *Stream) Close() error {
+	s.record("Close")
+	s.Closed = true
+	return s.Err
+}
+
+// Destroy only records the call.
+func (s *Stream) Destroy() {
+	s.record("Destroy")
+}
+
+// Stats returns StreamStats.
+func (s *Stream) Stats() coretypes.StreamStats {
+	s.record("Stats")
+	return s.StreamStats
+}
+
+// GetResourceID returns ResourceID, or Err if it is set.
+func (s *Stream) GetResourceID() (*string, error) {
+	s.record("GetResourceID")
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	resourceID := s.ResourceID
+	return &resourceID, nil
+}
@@ -0,0 +1,56 @@
+package coremock
+
+import (
+	"io"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// StreamWriter is a programmable fake of core.StreamWriter. Every call is
+// recorded. The data written to it is written as is to Writer, which is
+// typically the destination writer passed to NewEncryptWriterFunc.
+type StreamWriter struct {
+	Recorder
+
+	// Writer receives the data written. A nil Writer discards it.
+	Writer io.Writer
+	// ResourceID is returned by GetResourceID() unless Err is set.
+	ResourceID string
+	// Err is returned by GetResourceID() and Close().
+	Err error
+	// Closed is set by Close().
+	Closed bool
+}
+
+var _ coretypes.StreamWriter = (*StreamWriter)(nil)
+
+// NewStreamWriter creates a StreamWriter writing to writer, with the given resource ID.
+func NewStreamWriter(writer io.Writer, resourceID string) *StreamWriter {
+	return &StreamWriter{Writer: writer, ResourceID: resourceID}
+}
+
+// Write writes data to Writer.
+func (s *StreamWriter) Write(data []byte) (int, error) {
+	s.record("Write", len(data))
+	if s.Writer == nil {
+		return len(data), nil
+	}
+	return s.Writer.Write(data)
+}
+
+// Close sets Closed and returns Err.
+func (s *StreamWriter) Close() error {
+	s.record("Close")
+	s.Closed = true
+	return s.Err
+}
+
+// GetResourceID returns ResourceID, or Err if it is set.
+func (s *StreamWriter) GetResourceID() (*string, error) {
+	s.record("GetResourceID")
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	resourceID := s.ResourceID
+	return &resourceID, nil
+}
@@ -0,0 +1,622 @@
+// Package coremock provides programmable fakes of the interfaces of the core
+// package. Code depending on core.Client rather than *core.Tanker can be unit
+// tested with them, without the native library nor a Tanker server: coremock
+// only depends on the coretypes package, which builds without cgo.
+//
+//	tanker := &coremock.Tanker{}
+//	tanker.EncryptFunc = func(ctx context.Context, clearData []byte, options *core.EncryptionOptions) ([]byte, error) {
+//		return []byte("encrypted"), nil
+//	}
+//	service := NewService(tanker)
+//	...
+//	Expect(tanker.CallsTo("Encrypt")).To(HaveLen(1))
+package coremock
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// eventBufferSize is the capacity of the channels returned by Events().
+const eventBufferSize = 8
+
+// Tanker is a programmable fake of core.Client. Every call is recorded.
+//
+// Each operation calls the matching <Operation>Func field when it is set,
+// both the plain and the Context variants of an operation call the same
+// function, with context.Background() for the former. When the field is not
+// set, the operation returns zero values and Err.
+type Tanker struct {
+	Recorder
+
+	// Err is returned by the operations which are not stubbed.
+	Err error
+	// Status is returned by GetStatus().
+	Status coretypes.Status
+
+	StartFunc                     func(ctx context.Context, identity string) (coretypes.Status, error)
+	StopFunc                      func(ctx context.Context) error
+	EncryptFunc                   func(ctx context.Context, clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error)
+	DecryptFunc                   func(ctx context.Context, encryptedData []byte) ([]byte, error)
+	EncryptToFunc                 func(dst []byte, clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error)
+	DecryptToFunc                 func(dst []byte, encryptedData []byte) ([]byte, error)
+	DecryptSecretFunc             func(encryptedData []byte) (*coretypes.SecretBuffer, error)
+	GetResourceIdFunc             func(encryptedData []byte) (*string, error)
+	GetResourceIDFromReaderFunc   func(r io.Reader) (*string, io.Reader, error)
+	CreateEncryptionSessionFunc   func(ctx context.Context, encryptionOptions *coretypes.EncryptionOptions) (coretypes.EncryptionSessionClient, error)
+	StreamEncryptFunc             func(ctx context.Context, reader io.Reader, options *coretypes.EncryptionOptions) (coretypes.Stream, error)
+	StreamDecryptFunc             func(ctx context.Context, reader io.Reader) (coretypes.Stream, error)
+	NewEncryptWriterFunc          func(ctx context.Context, dst io.Writer, options *coretypes.EncryptionOptions) (coretypes.StreamWriter, error)
+	NewDecryptWriterFunc          func(ctx context.Context, dst io.Writer) (coretypes.StreamWriter, error)
+	NewDecryptReaderAtFunc        func(ctx context.Context, src io.ReaderAt, size int64) (coretypes.SeekableStream, error)
+	ShareFunc                     func(ctx context.Context, resourceIDs []string, sharingOptions coretypes.SharingOptions) error
+	CreateGroupFunc               func(ctx context.Context, publicIdentities []string) (*string, error)
+	UpdateGroupMembersFunc        func(ctx context.Context, groupID string, publicIdentitiesToAdd []string) error
+	EditGroupMembersFunc          func(ctx context.Context, groupID string, update coretypes.GroupMembersUpdate) error
+	RegisterIdentityFunc          func(ctx context.Context, verification coretypes.Verification) error
+	VerifyIdentityFunc            func(ctx context.Context, verification coretypes.Verification) error
+	SetVerificationMethodFunc     func(ctx context.Context, verification coretypes.Verification) error
+	GetVerificationMethodsFunc    func(ctx context.Context) ([]coretypes.VerificationMethod, error)
+	AttachProvisionalIdentityFunc func(ctx context.Context, provisionalIdentity string) (*coretypes.AttachResult, error)
+	VerifyProvisionalIdentityFunc func(ctx context.Context, verification coretypes.Verification) error
+	GenerateVerificationKeyFunc   func(ctx context.Context) (*string, error)
+	GetDeviceIDFunc               func(ctx context.Context) (*string, error)
+	GetDeviceListFunc             func(ctx context.Context) ([]coretypes.DeviceDescription, error)
+	RevokeDeviceFunc              func(ctx context.Context, deviceID string) error
+
+	eventMutex  sync.Mutex
+	nextID      uint64
+	handlers    map[coretypes.EventType]map[uint64]coretypes.EventHandler
+	subscribers []chan coretypes.EventType
+}
+
+var _ coretypes.Client = (*Tanker)(nil)
+
+// Destroy closes the channels returned by Events().
+func (m *Tanker) Destroy() error {
+	m.record("Destroy")
+	m.eventMutex.Lock()
+	defer m.eventMutex.Unlock()
+	for _, subscriber := range m.subscribers {
+		close(subscriber)
+	}
+	m.subscribers = nil
+	return m.Err
+}
+
+// GetStatus returns Status.
+func (m *Tanker) GetStatus() coretypes.Status {
+	m.record("GetStatus")
+	return m.Status
+}
+
+// RegisterEventHandler registers a handler called by Emit().
+func (m *Tanker) RegisterEventHandler(event coretypes.EventType, handler coretypes.EventHandler) (func(), error) {
+	m.record("RegisterEventHandler", event)
+	m.eventMutex.Lock()
+	defer m.eventMutex.Unlock()
+	if m.handlers == nil {
+		m.handlers = make(map[coretypes.EventType]map[uint64]coretypes.EventHandler)
+	}
+	if m.handlers[event] == nil {
+		m.handlers[event] = make(map[uint64]coretypes.EventHandler)
+	}
+	id := m.nextID
+	m.nextID++
+	m.handlers[event][id] = handler
+	return func() {
+		m.eventMutex.Lock()
+		defer m.eventMutex.Unlock()
+		delete(m.handlers[event], id)
+	}, nil
+}
+
+// Events returns a channel on which the events passed to Emit() are sent.
+func (m *Tanker) Events() <-chan coretypes.EventType {
+	m.record("Events")
+	m.eventMutex.Lock()
+	defer m.eventMutex.Unlock()
+	subscriber := make(chan coretypes.EventType, eventBufferSize)
+	m.subscribers = append(m.subscribers, subscriber)
+	return subscriber
+}
+
+// Emit simulates an event emitted by the native library: the registered handlers
+// are called synchronously, and the event is sent to the channels returned by Events().
+func (m *Tanker) Emit(event coretypes.EventType) {
+	m.eventMutex.Lock()
+	handlers := make([]coretypes.EventHandler, 0, len(m.handlers[event]))
+	for _, handler := range m.handlers[event] {
+		handlers = append(handlers, handler)
+	}
+	for _, subscriber := range m.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+	m.eventMutex.Unlock()
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+// Start calls StartFunc if set.
+func (m *Tanker) Start(identity string) (coretypes.Status, error) {
+	m.record("Start", identity)
+	if m.StartFunc != nil {
+		return m.StartFunc(context.Background(), identity)
+	}
+	return coretypes.StatusStopped, m.Err
+}
+
+// StartContext calls StartFunc if set.
+func (m *Tanker) StartContext(ctx context.Context, identity string) (coretypes.Status, error) {
+	m.record("StartContext", identity)
+	if m.StartFunc != nil {
+		return m.StartFunc(ctx, identity)
+	}
+	return coretypes.StatusStopped, m.Err
+}
+
+// Stop calls StopFunc if set.
+func (m *Tanker) Stop() error {
+	m.record("Stop")
+	if m.StopFunc != nil {
+		return m.StopFunc(context.Background())
+	}
+	return m.Err
+}
+
+// StopContext calls StopFunc if set.
+func (m *Tanker) StopContext(ctx context.Context) error {
+	m.record("StopContext")
+	if m.StopFunc != nil {
+		return m.StopFunc(ctx)
+	}
+	return m.Err
+}
+
+// Encrypt calls EncryptFunc if set.
+func (m *Tanker) Encrypt(clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error) {
+	m.record("Encrypt", clearData, options)
+	if m.EncryptFunc != nil {
+		return m.EncryptFunc(context.Background(), clearData, options)
+	}
+	return nil, m.Err
+}
+
+// EncryptContext calls EncryptFunc if set.
+func (m *Tanker) EncryptContext(ctx context.Context, clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error) {
+	m.record("EncryptContext", clearData, options)
+	if m.EncryptFunc != nil {
+		return m.EncryptFunc(ctx, clearData, options)
+	}
+	return nil, m.Err
+}
+
+// Decrypt calls DecryptFunc if set.
+func (m *Tanker) Decrypt(encryptedData []byte) ([]byte, error) {
+	m.record("Decrypt", encryptedData)
+	if m.DecryptFunc != nil {
+		return m.DecryptFunc(context.Background(), encryptedData)
+	}
+	return nil, m.Err
+}
+
+// DecryptContext calls DecryptFunc if set.
+func (m *Tanker) DecryptContext(ctx context.Context, encryptedData []byte) ([]byte, error) {
+	m.record("DecryptContext", encryptedData)
+	if m.DecryptFunc != nil {
+		return m.DecryptFunc(ctx, encryptedData)
+	}
+	return nil, m.Err
+}
+
+// EncryptTo calls EncryptToFunc if set.
+func (m *Tanker) EncryptTo(dst []byte, clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error) {
+	m.record("EncryptTo", dst, clearData, options)
+	if m.EncryptToFunc != nil {
+		return m.EncryptToFunc(dst, clearData, options)
+	}
+	return nil, m.Err
+}
+
+// DecryptTo calls DecryptToFunc if set.
+func (m *Tanker) DecryptTo(dst []byte, encryptedData []byte) ([]byte, error) {
+	m.record("DecryptTo", dst, encryptedData)
+	if m.DecryptToFunc != nil {
+		return m.DecryptToFunc(dst, encryptedData)
+	}
+	return nil, m.Err
+}
+
+// DecryptSecret calls DecryptSecretFunc if set.
+func (m *Tanker) DecryptSecret(encryptedData []byte) (*coretypes.SecretBuffer, error) {
+	m.record("DecryptSecret", encryptedData)
+	if m.DecryptSecretFunc != nil {
+		return m.DecryptSecretFunc(encryptedData)
+	}
+	return nil, m.Err
+}
+
+// GetResourceId calls GetResourceIdFunc if set.
+func (m *Tanker) GetResourceId(encryptedData []byte) (*string, error) {
+	m.record("GetResourceId", encryptedData)
+	if m.GetResourceIdFunc != nil {
+		return m.GetResourceIdFunc(encryptedData)
+	}
+	return nil, m.Err
+}
+
+// GetResourceIDFromReader calls GetResourceIDFromReaderFunc if set.
+func (m *Tanker) GetResourceIDFromReader(r io.Reader) (*string, io.Reader, error) {
+	m.record("GetResourceIDFromReader", r)
+	if m.GetResourceIDFromReaderFunc != nil {
+		return m.GetResourceIDFromReaderFunc(r)
+	}
+	return nil, nil, m.Err
+}
+
+// CreateEncryptionSession calls CreateEncryptionSessionFunc if set.
+func (m *Tanker) CreateEncryptionSession(encryptionOptions *coretypes.EncryptionOptions) (coretypes.EncryptionSessionClient, error) {
+	m.record("CreateEncryptionSession", encryptionOptions)
+	if m.CreateEncryptionSessionFunc != nil {
+		return m.CreateEncryptionSessionFunc(context.Background(), encryptionOptions)
+	}
+	return nil, m.Err
+}
+
+// CreateEncryptionSessionContext calls CreateEncryptionSessionFunc if set.
+func (m *Tanker) CreateEncryptionSessionContext(ctx context.Context, encryptionOptions *coretypes.EncryptionOptions) (coretypes.EncryptionSessionClient, error) {
+	m.record("CreateEncryptionSessionContext", encryptionOptions)
+	if m.CreateEncryptionSessionFunc != nil {
+		return m.CreateEncryptionSessionFunc(ctx, encryptionOptions)
+	}
+	return nil, m.Err
+}
+
+// StreamEncrypt calls StreamEncryptFunc if set.
+func (m *Tanker) StreamEncrypt(reader io.Reader, options *coretypes.EncryptionOptions) (coretypes.Stream, error) {
+	m.record("StreamEncrypt", reader, options)
+	if m.StreamEncryptFunc != nil {
+		return m.StreamEncryptFunc(context.Background(), reader, options)
+	}
+	return nil, m.Err
+}
+
+// StreamEncryptContext calls StreamEncryptFunc if set.
+func (m *Tanker) StreamEncryptContext(ctx context.Context, reader io.Reader, options *coretypes.EncryptionOptions) (coretypes.Stream, error) {
+	m.record("StreamEncryptContext", reader, options)
+	if m.StreamEncryptFunc != nil {
+		return m.StreamEncryptFunc(ctx, reader, options)
+	}
+	return nil, m.Err
+}
+
+// StreamDecrypt calls StreamDecryptFunc if set.
+func (m *Tanker) StreamDecrypt(reader io.Reader) (coretypes.Stream, error) {
+	m.record("StreamDecrypt", reader)
+	if m.StreamDecryptFunc != nil {
+		return m.StreamDecryptFunc(context.Background(), reader)
+	}
+	return nil, m.Err
+}
+
+// StreamDecryptContext calls StreamDecryptFunc if set.
+func (m *Tanker) StreamDecryptContext(ctx context.Context, reader io.Reader) (coretypes.Stream, error) {
+	m.record("StreamDecryptContext", reader)
+	if m.StreamDecryptFunc != nil {
+		return m.StreamDecryptFunc(ctx, reader)
+	}
+	return nil, m.Err
+}
+
+// NewEncryptWriter calls NewEncryptWriterFunc if set.
+func (m *Tanker) NewEncryptWriter(dst io.Writer, options *coretypes.EncryptionOptions) (coretypes.StreamWriter, error) {
+	m.record("NewEncryptWriter", dst, options)
+	if m.NewEncryptWriterFunc != nil {
+		return m.NewEncryptWriterFunc(context.Background(), dst, options)
+	}
+	return nil, m.Err
+}
+
+// NewEncryptWriterContext calls NewEncryptWriterFunc if set.
+func (m *Tanker) NewEncryptWriterContext(ctx context.Context, dst io.Writer, options *coretypes.EncryptionOptions) (coretypes.StreamWriter, error) {
+	m.record("NewEncryptWriterContext", dst, options)
+	if m.NewEncryptWriterFunc != nil {
+		return m.NewEncryptWriterFunc(ctx, dst, options)
+	}
+	return nil, m.Err
+}
+
+// NewDecryptWriter calls NewDecryptWriterFunc if set.
+func (m *Tanker) NewDecryptWriter(dst io.Writer) (coretypes.StreamWriter, error) {
+	m.record("NewDecryptWriter", dst)
+	if m.NewDecryptWriterFunc != nil {
+		return m.NewDecryptWriterFunc(context.Background(), dst)
+	}
+	return nil, m.Err
+}
+
+// NewDecryptWriterContext calls NewDecryptWriterFunc if set.
+func (m *Tanker) NewDecryptWriterContext(ctx context.Context, dst io.Writer) (coretypes.StreamWriter, error) {
+	m.record("NewDecryptWriterContext", dst)
+	if m.NewDecryptWriterFunc != nil {
+		return m.NewDecryptWriterFunc(ctx, dst)
+	}
+	return nil, m.Err
+}
+
+// NewDecryptReaderAt calls NewDecryptReaderAtFunc if set.
+func (m *Tanker) NewDecryptReaderAt(src io.ReaderAt, size int64) (coretypes.SeekableStream, error) {
+	m.record("NewDecryptReaderAt", src, size)
+	if m.NewDecryptReaderAtFunc != nil {
+		return m.NewDecryptReaderAtFunc(context.Background(), src, size)
+	}
+	return nil, m.Err
+}
+
+// NewDecryptReaderAtContext calls NewDecryptReaderAtFunc if set.
+func (m *Tanker) NewDecryptReaderAtContext(ctx context.Context, src io.ReaderAt, size int64) (coretypes.SeekableStream, error) {
+	m.record("NewDecryptReaderAtContext", src, size)
+	if m.NewDecryptReaderAtFunc != nil {
+		return m.NewDecryptReaderAtFunc(ctx, src, size)
+	}
+	return nil, m.Err
+}
+
+// Share calls ShareFunc if set.
+func (m *Tanker) Share(resourceIDs []string, sharingOptions coretypes.SharingOptions) error {
+	m.record("Share", resourceIDs, sharingOptions)
+	if m.ShareFunc != nil {
+		return m.ShareFunc(context.Background(), resourceIDs, sharingOptions)
+	}
+	return m.Err
+}
+
+// ShareContext calls ShareFunc if set.
+func (m *Tanker) ShareContext(ctx context.Context, resourceIDs []string, sharingOptions coretypes.SharingOptions) error {
+	m.record("ShareContext", resourceIDs, sharingOptions)
+	if m.ShareFunc != nil {
+		return m.ShareFunc(ctx, resourceIDs, sharingOptions)
+	}
+	return m.Err
+}
+
+// CreateGroup calls CreateGroupFunc if set.
+func (m *Tanker) CreateGroup(publicIdentities []string) (*string, error) {
+	m.record("CreateGroup", publicIdentities)
+	if m.CreateGroupFunc != nil {
+		return m.CreateGroupFunc(context.Background(), publicIdentities)
+	}
+	return nil, m.Err
+}
+
+// CreateGroupContext calls CreateGroupFunc if set.
+func (m *Tanker) CreateGroupContext(ctx context.Context, publicIdentities []string) (*string, error) {
+	m.record("CreateGroupContext", publicIdentities)
+	if m.CreateGroupFunc != nil {
+		return m.CreateGroupFunc(ctx, publicIdentities)
+	}
+	return nil, m.Err
+}
+
+// UpdateGroupMembers calls UpdateGroupMembersFunc if set.
+func (m *Tanker) UpdateGroupMembers(groupID string, publicIdentitiesToAdd []string) error {
+	m.record("UpdateGroupMembers", groupID, publicIdentitiesToAdd)
+	if m.UpdateGroupMembersFunc != nil {
+		return m.UpdateGroupMembersFunc(context.Background(), groupID, publicIdentitiesToAdd)
+	}
+	return m.Err
+}
+
+// UpdateGroupMembersContext calls UpdateGroupMembersFunc if set.
+func (m *Tanker) UpdateGroupMembersContext(ctx context.Context, groupID string, publicIdentitiesToAdd []string) error {
+	m.record("UpdateGroupMembersContext", groupID, publicIdentitiesToAdd)
+	if m.UpdateGroupMembersFunc != nil {
+		return m.UpdateGroupMembersFunc(ctx, groupID, publicIdentitiesToAdd)
+	}
+	return m.Err
+}
+
+// EditGroupMembers calls EditGroupMembersFunc if set.
+func (m *Tanker) EditGroupMembers(groupID string, update coretypes.GroupMembersUpdate) error {
+	m.record("EditGroupMembers", groupID, update)
+	if m.EditGroupMembersFunc != nil {
+		return m.EditGroupMembersFunc(context.Background(), groupID, update)
+	}
+	return m.Err
+}
+
+// EditGroupMembersContext calls EditGroupMembersFunc if set.
+func (m *Tanker) EditGroupMembersContext(ctx context.Context, groupID string, update coretypes.GroupMembersUpdate) error {
+	m.record("EditGroupMembersContext", groupID, update)
+	if m.EditGroupMembersFunc != nil {
+		return m.EditGroupMembersFunc(ctx, groupID, update)
+	}
+	return m.Err
+}
+
+// RegisterIdentity calls RegisterIdentityFunc if set.
+func (m *Tanker) RegisterIdentity(verification coretypes.Verification) error {
+	m.record("RegisterIdentity", verification)
+	if m.RegisterIdentityFunc != nil {
+		return m.RegisterIdentityFunc(context.Background(), verification)
+	}
+	return m.Err
+}
+
+// RegisterIdentityContext calls RegisterIdentityFunc if set.
+func (m *Tanker) RegisterIdentityContext(ctx context.Context, verification coretypes.Verification) error {
+	m.record("RegisterIdentityContext", verification)
+	if m.RegisterIdentityFunc != nil {
+		return m.RegisterIdentityFunc(ctx, verification)
+	}
+	return m.Err
+}
+
+// VerifyIdentity calls VerifyIdentityFunc if set.
+func (m *Tanker) VerifyIdentity(verification coretypes.Verification) error {
+	m.record("VerifyIdentity", verification)
+	if m.VerifyIdentityFunc != nil {
+		return m.VerifyIdentityFunc(context.Background(), verification)
+	}
+	return m.Err
+}
+
+// VerifyIdentityContext calls VerifyIdentityFunc if set.
+func (m *Tanker) VerifyIdentityContext(ctx context.Context, verification coretypes.Verification) error {
+	m.record("VerifyIdentityContext", verification)
+	if m.VerifyIdentityFunc != nil {
+		return m.VerifyIdentityFunc(ctx, verification)
+	}
+	return m.Err
+}
+
+// SetVerificationMethod calls SetVerificationMethodFunc if set.
+func (m *Tanker) SetVerificationMethod(verification coretypes.Verification) error {
+	m.record("SetVerificationMethod", verification)
+	if m.SetVerificationMethodFunc != nil {
+		return m.SetVerificationMethodFunc(context.Background(), verification)
+	}
+	return m.Err
+}
+
+// SetVerificationMethodContext calls SetVerificationMethodFunc if set.
+func (m *Tanker) SetVerificationMethodContext(ctx context.Context, verification coretypes.Verification) error {
+	m.record("SetVerificationMethodContext", verification)
+	if m.SetVerificationMethodFunc != nil {
+		return m.SetVerificationMethodFunc(ctx, verification)
+	}
+	return m.Err
+}
+
+// GetVerificationMethods calls GetVerificationMethodsFunc if set.
+func (m *Tanker) GetVerificationMethods() ([]coretypes.VerificationMethod, error) {
+	m.record("GetVerificationMethods")
+	if m.GetVerificationMethodsFunc != nil {
+		return m.GetVerificationMethodsFunc(context.Background())
+	}
+	return nil, m.Err
+}
+
+// GetVerificationMethodsContext calls GetVerificationMethodsFunc if set.
+func (m *Tanker) GetVerificationMethodsContext(ctx context.Context) ([]coretypes.VerificationMethod, error) {
+	m.record("GetVerificationMethodsContext")
+	if m.GetVerificationMethodsFunc != nil {
+		return m.GetVerificationMethodsFunc(ctx)
+	}
+	return nil, m.Err
+}
+
+// AttachProvisionalIdentity calls AttachProvisionalIdentityFunc if set.
+func (m *Tanker) AttachProvisionalIdentity(provisionalIdentity string) (*coretypes.AttachResult, error) {
+	m.record("AttachProvisionalIdentity", provisionalIdentity)
+	if m.AttachProvisionalIdentityFunc != nil {
+		return m.AttachProvisionalIdentityFunc(context.Background(), provisionalIdentity)
+	}
+	return nil, m.Err
+}
+
+// AttachProvisionalIdentityContext calls AttachProvisionalIdentityFunc if set.
+func (m *Tanker) AttachProvisionalIdentityContext(ctx context.Context, provisionalIdentity string) (*coretypes.AttachResult, error) {
+	m.record("AttachProvisionalIdentityContext", provisionalIdentity)
+	if m.AttachProvisionalIdentityFunc != nil {
+		return m.AttachProvisionalIdentityFunc(ctx, provisionalIdentity)
+	}
+	return nil, m.Err
+}
+
+// VerifyProvisionalIdentity calls VerifyProvisionalIdentityFunc if set.
+func (m *Tanker) VerifyProvisionalIdentity(verification coretypes.Verification) error {
+	m.record("VerifyProvisionalIdentity", verification)
+	if m.VerifyProvisionalIdentityFunc != nil {
+		return m.VerifyProvisionalIdentityFunc(context.Background(), verification)
+	}
+	return m.Err
+}
+
+// VerifyProvisionalIdentityContext calls VerifyProvisionalIdentityFunc if set.
+func (m *Tanker) VerifyProvisionalIdentityContext(ctx context.Context, verification coretypes.Verification) error {
+	m.record("VerifyProvisionalIdentityContext", verification)
+	if m.VerifyProvisionalIdentityFunc != nil {
+		return m.VerifyProvisionalIdentityFunc(ctx, verification)
+	}
+	return m.Err
+}
+
+// GenerateVerificationKey calls GenerateVerificationKeyFunc if set.
+func (m *Tanker) GenerateVerificationKey() (*string, error) {
+	m.record("GenerateVerificationKey")
+	if m.GenerateVerificationKeyFunc != nil {
+		return m.GenerateVerificationKeyFunc(context.Background())
+	}
+	return nil, m.Err
+}
+
+// GenerateVerificationKeyContext calls GenerateVerificationKeyFunc if set.
+func (m *Tanker) GenerateVerificationKeyContext(ctx context.Context) (*string, error) {
+	m.record("GenerateVerificationKeyContext")
+	if m.GenerateVerificationKeyFunc != nil {
+		return m.GenerateVerificationKeyFunc(ctx)
+	}
+	return nil, m.Err
+}
+
+// GetDeviceID calls GetDeviceIDFunc if set.
+func (m *Tanker) GetDeviceID() (*string, error) {
+	m.record("GetDeviceID")
+	if m.GetDeviceIDFunc != nil {
+		return m.GetDeviceIDFunc(context.Background())
+	}
+	return nil, m.Err
+}
+
+// GetDeviceIDContext calls GetDeviceIDFunc if set.
+func (m *Tanker) GetDeviceIDContext(ctx context.Context) (*string, error) {
+	m.record("GetDeviceIDContext")
+	if m.GetDeviceIDFunc != nil {
+		return m.GetDeviceIDFunc(ctx)
+	}
+	return nil, m.Err
+}
+
+// GetDeviceList calls GetDeviceListFunc if set.
+func (m *Tanker) GetDeviceList() ([]coretypes.DeviceDescription, error) {
+	m.record("GetDeviceList")
+	if m.GetDeviceListFunc != nil {
+		return m.GetDeviceListFunc(context.Background())
+	}
+	return nil, m.Err
+}
+
+// GetDeviceListContext calls GetDeviceListFunc if set.
+func (m *Tanker) GetDeviceListContext(ctx context.Context) ([]coretypes.DeviceDescription, error) {
+	m.record("GetDeviceListContext")
+	if m.GetDeviceListFunc != nil {
+		return m.GetDeviceListFunc(ctx)
+	}
+	return nil, m.Err
+}
+
+// RevokeDevice calls RevokeDeviceFunc if set.
+func (m *Tanker) RevokeDevice(deviceID string) error {
+	m.record("RevokeDevice", deviceID)
+	if m.RevokeDeviceFunc != nil {
+		return m.RevokeDeviceFunc(context.Background(), deviceID)
+	}
+	return m.Err
+}
+
+// RevokeDeviceContext calls RevokeDeviceFunc if set.
+func (m *Tanker) RevokeDeviceContext(ctx context.Context, deviceID string) error {
+	m.record("RevokeDeviceContext", deviceID)
+	if m.RevokeDeviceFunc != nil {
+		return m.RevokeDeviceFunc(ctx, deviceID)
+	}
+	return m.Err
+}
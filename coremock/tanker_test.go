@@ -0,0 +1,133 @@
+package coremock_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/coremock"
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// encryptWith is an example of code under test, depending on core.Client only.
+func encryptWith(client coretypes.Client, data []byte) ([]byte, error) {
+	options := coretypes.NewEncryptionOptions()
+	return client.Encrypt(data, &options)
+}
+
+var _ = Describe("Tanker", func() {
+	var tanker *coremock.Tanker
+
+	BeforeEach(func() {
+		tanker = &coremock.Tanker{}
+	})
+
+	It("Returns canned results and records the calls", func() {
+		tanker.EncryptFunc = func(ctx context.Context, clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error) {
+			return append([]byte("encrypted:"), clearData...), nil
+		}
+		encrypted, err := encryptWith(tanker, []byte("data"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encrypted).To(Equal([]byte("encrypted:data")))
+
+		calls := tanker.CallsTo("Encrypt")
+		Expect(calls).To(HaveLen(1))
+		Expect(calls[0].Args[0]).To(Equal([]byte("data")))
+		Expect(calls[0].Args[1]).To(Equal(&coretypes.EncryptionOptions{ShareWithSelf: true}))
+	})
+
+	It("Passes the context to the stub of Context variants", func() {
+		type key struct{}
+		ctx := context.WithValue(context.Background(), key{}, "value")
+		tanker.ShareFunc = func(ctx context.Context, resourceIDs []string, sharingOptions coretypes.SharingOptions) error {
+			Expect(ctx.Value(key{})).To(Equal("value"))
+			return nil
+		}
+		Expect(tanker.ShareContext(ctx, []string{"id"}, coretypes.NewSharingOptions())).To(Succeed())
+		Expect(tanker.CallCount("ShareContext")).To(Equal(1))
+		Expect(tanker.CallCount("Share")).To(Equal(0))
+	})
+
+	It("Returns Err from operations which are not stubbed", func() {
+		tanker.Err = errors.New("canned error")
+		_, err := tanker.Decrypt([]byte("data"))
+		Expect(err).To(MatchError("canned error"))
+		Expect(tanker.Stop()).To(MatchError("canned error"))
+	})
+
+	It("Forgets the calls on Reset", func() {
+		_ = tanker.GetStatus()
+		Expect(tanker.Calls()).To(HaveLen(1))
+		tanker.Reset()
+		Expect(tanker.Calls()).To(BeEmpty())
+	})
+
+	It("Emits events to the handlers and subscribers", func() {
+		called := 0
+		unregister, err := tanker.RegisterEventHandler(coretypes.EventDeviceRevoked, func() { called++ })
+		Expect(err).ToNot(HaveOccurred())
+		events := tanker.Events()
+
+		tanker.Emit(coretypes.EventDeviceRevoked)
+		Expect(called).To(Equal(1))
+		Expect(events).To(Receive(Equal(coretypes.EventDeviceRevoked)))
+
+		unregister()
+		tanker.Emit(coretypes.EventDeviceRevoked)
+		Expect(called).To(Equal(1))
+		Expect(events).To(Receive(Equal(coretypes.EventDeviceRevoked)))
+
+		Expect(tanker.Destroy()).To(Succeed())
+		Expect(events).To(BeClosed())
+	})
+
+	It("Returns fake streams and encryption sessions", func() {
+		tanker.StreamEncryptFunc = func(ctx context.Context, reader io.Reader, options *coretypes.EncryptionOptions) (coretypes.Stream, error) {
+			stream := coremock.NewStream(bytes.NewReader([]byte("encrypted")), "resource")
+			stream.StreamStats = coretypes.StreamStats{BytesProduced: 9, Chunks: 1}
+			return stream, nil
+		}
+		tanker.CreateEncryptionSessionFunc = func(ctx context.Context, encryptionOptions *coretypes.EncryptionOptions) (coretypes.EncryptionSessionClient, error) {
+			return &coremock.EncryptionSession{ResourceID: "session"}, nil
+		}
+
+		stream, err := tanker.StreamEncrypt(bytes.NewReader([]byte("clear")), nil)
+		Expect(err).ToNot(HaveOccurred())
+		encrypted, err := ioutil.ReadAll(stream)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encrypted).To(Equal([]byte("encrypted")))
+		resourceID, err := stream.GetResourceID()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*resourceID).To(Equal("resource"))
+		Expect(stream.Stats().BytesProduced).To(Equal(int64(9)))
+		Expect(stream.Close()).To(Succeed())
+		Expect(stream.(*coremock.Stream).Closed).To(BeTrue())
+
+		session, err := tanker.CreateEncryptionSession(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(session.GetResourceId()).To(Equal("session"))
+	})
+
+	It("Returns fake stream writers", func() {
+		var fake *coremock.StreamWriter
+		tanker.NewEncryptWriterFunc = func(ctx context.Context, dst io.Writer, options *coretypes.EncryptionOptions) (coretypes.StreamWriter, error) {
+			fake = coremock.NewStreamWriter(dst, "resource")
+			return fake, nil
+		}
+
+		var output bytes.Buffer
+		writer, err := tanker.NewEncryptWriter(&output, nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = writer.Write([]byte("data"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+		Expect(output.String()).To(Equal("data"))
+		Expect(fake.Closed).To(BeTrue())
+		Expect(fake.CallCount("Write")).To(Equal(1))
+	})
+})
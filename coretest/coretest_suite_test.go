@@ -0,0 +1,40 @@
+package coretest_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/coretest"
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+type user struct {
+	Identity       string
+	PublicIdentity string
+}
+
+func createUser(server *coretest.Server, userID string) user {
+	identity, publicIdentity := server.CreateIdentity(userID)
+	return user{identity, publicIdentity}
+}
+
+// startDevice starts a Tanker on the device stored at path, registering or verifying the identity with a passphrase.
+func startDevice(server *coretest.Server, u user, path string) *coretest.Tanker {
+	tanker, err := server.StartDevice(u.Identity, path, coretypes.PassphraseVerification{Passphrase: "multipass"})
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	return tanker
+}
+
+func expectErrorCode(err error, code coretypes.ErrorCode) {
+	ExpectWithOffset(1, err).To(HaveOccurred())
+	terror, ok := err.(coretypes.Error)
+	ExpectWithOffset(1, ok).To(BeTrue())
+	ExpectWithOffset(1, terror.Code()).To(Equal(code))
+}
+
+func TestCoreTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Core Test Simulator Suite")
+}
@@ -0,0 +1,86 @@
+package coretest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// Layout of an encrypted resource:
+//  version (1 byte) | resource ID (16 bytes) | nonce (12 bytes) | AES-GCM ciphertext and tag
+// The version and resource ID are authenticated as additional data.
+const (
+	formatVersion  = 0xf1
+	resourceIDSize = 16
+	keySize        = 32
+	nonceSize      = 12
+	tagSize        = 16
+	headerSize     = 1 + resourceIDSize
+	overhead       = headerSize + nonceSize + tagSize
+)
+
+func randomBytes(size int) []byte {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+func randomID(size int) string {
+	return base64.StdEncoding.EncodeToString(randomBytes(size))
+}
+
+// EncryptedSize returns the size of the encrypted resource for a clear data of the given size.
+func EncryptedSize(clearSize int) int {
+	return clearSize + overhead
+}
+
+func newAEAD(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// seal encrypts clearData with key for the given raw resource ID.
+func seal(key []byte, resourceID []byte, clearData []byte) []byte {
+	encrypted := make([]byte, 0, EncryptedSize(len(clearData)))
+	encrypted = append(encrypted, formatVersion)
+	encrypted = append(encrypted, resourceID...)
+	nonce := randomBytes(nonceSize)
+	encrypted = append(encrypted, nonce...)
+	return newAEAD(key).Seal(encrypted, nonce, clearData, encrypted[:headerSize])
+}
+
+// parseResourceID extracts the resource ID of an encrypted resource.
+func parseResourceID(encryptedData []byte) (string, error) {
+	if len(encryptedData) < overhead {
+		return "", newError(coretypes.ErrorInvalidArgument, "encrypted data is too short")
+	}
+	if encryptedData[0] != formatVersion {
+		return "", newError(coretypes.ErrorInvalidArgument, "unsupported encryption format version %d", encryptedData[0])
+	}
+	return base64.StdEncoding.EncodeToString(encryptedData[1:headerSize]), nil
+}
+
+// open decrypts an encrypted resource with its key.
+func open(key []byte, encryptedData []byte) ([]byte, error) {
+	nonce := encryptedData[headerSize : headerSize+nonceSize]
+	clearData, err := newAEAD(key).Open(nil, nonce, encryptedData[headerSize+nonceSize:], encryptedData[:headerSize])
+	if err != nil {
+		return nil, newError(coretypes.ErrorDecryptionFailed, "decryption failed: %v", err)
+	}
+	if clearData == nil {
+		clearData = []byte{}
+	}
+	return clearData, nil
+}
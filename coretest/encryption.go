@@ -0,0 +1,326 @@
+package coretest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// newSharedResource creates a resource key shared according to the options.
+// Must be called with the server mutex held.
+func (t *Tanker) newSharedResource(options *coretypes.EncryptionOptions) (string, []byte, []byte, error) {
+	if options == nil {
+		defaults := coretypes.NewEncryptionOptions()
+		options = &defaults
+	}
+	with, err := t.server.resolveRecipients(options.ShareWithUsers, options.ShareWithGroups)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if !options.ShareWithSelf && len(with.users)+len(with.groups)+len(with.provisionals) == 0 {
+		return "", nil, nil, newError(coretypes.ErrorInvalidArgument, "cannot encrypt without sharing with anybody")
+	}
+	resourceID, rawID, r := t.server.newResource()
+	if options.ShareWithSelf {
+		r.users[t.user.id] = true
+	}
+	r.share(with)
+	return resourceID, rawID, r.key, nil
+}
+
+// Encrypt encrypts clearData for the recipients of the options.
+func (t *Tanker) Encrypt(clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error) {
+	return t.EncryptContext(context.Background(), clearData, options)
+}
+
+// EncryptContext is like Encrypt but fails if ctx is done.
+func (t *Tanker) EncryptContext(ctx context.Context, clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	if clearData == nil {
+		return nil, newError(coretypes.ErrorInvalidArgument, "clearData must not be nil")
+	}
+	_, rawID, key, err := t.newSharedResource(options)
+	if err != nil {
+		return nil, err
+	}
+	return seal(key, rawID, clearData), nil
+}
+
+// Decrypt decrypts a resource the current user has access to.
+func (t *Tanker) Decrypt(encryptedData []byte) ([]byte, error) {
+	return t.DecryptContext(context.Background(), encryptedData)
+}
+
+// DecryptContext is like Decrypt but fails if ctx is done.
+func (t *Tanker) DecryptContext(ctx context.Context, encryptedData []byte) ([]byte, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	resourceID, err := parseResourceID(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := t.server.resources[resourceID]
+	if !ok || !t.server.canDecrypt(t.user, r) {
+		return nil, newError(coretypes.ErrorInvalidArgument, "key not found for resource %s", resourceID)
+	}
+	return open(r.key, encryptedData)
+}
+
+// EncryptTo is like Encrypt, but appends the encrypted data to dst. On error,
+// dst is returned unchanged.
+func (t *Tanker) EncryptTo(dst []byte, clearData []byte, options *coretypes.EncryptionOptions) ([]byte, error) {
+	encryptedData, err := t.Encrypt(clearData, options)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, encryptedData...), nil
+}
+
+// DecryptTo is like Decrypt, but appends the clear data to dst. On error, dst
+// is returned unchanged.
+func (t *Tanker) DecryptTo(dst []byte, encryptedData []byte) ([]byte, error) {
+	clearData, err := t.Decrypt(encryptedData)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, clearData...), nil
+}
+
+// DecryptSecret is like Decrypt, but returns the clear data in a SecretBuffer.
+func (t *Tanker) DecryptSecret(encryptedData []byte) (*coretypes.SecretBuffer, error) {
+	clearData, err := t.Decrypt(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	return coretypes.NewSecretBufferFrom(clearData), nil
+}
+
+// GetResourceId returns the ID of an encrypted resource.
+func (t *Tanker) GetResourceId(encryptedData []byte) (*string, error) {
+	resourceID, err := parseResourceID(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	return &resourceID, nil
+}
+
+// GetResourceIDFromReader returns the ID of the encrypted resource read from r,
+// reading only its header, and a reader replaying the whole encrypted data.
+func (t *Tanker) GetResourceIDFromReader(r io.Reader) (*string, io.Reader, error) {
+	header := make([]byte, overhead)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	resourceID, err := parseResourceID(header[:n])
+	if err != nil {
+		return nil, nil, err
+	}
+	return &resourceID, io.MultiReader(bytes.NewReader(header), r), nil
+}
+
+// Share shares resources the current user has access to. It either fully succeeds or fails.
+func (t *Tanker) Share(resourceIDs []string, sharingOptions coretypes.SharingOptions) error {
+	return t.ShareContext(context.Background(), resourceIDs, sharingOptions)
+}
+
+// ShareContext is like Share but fails if ctx is done.
+func (t *Tanker) ShareContext(ctx context.Context, resourceIDs []string, sharingOptions coretypes.SharingOptions) error {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return err
+	}
+	if len(resourceIDs) == 0 {
+		return newError(coretypes.ErrorInvalidArgument, "resourceIDs must not be nil nor empty")
+	}
+	with, err := t.server.resolveRecipients(sharingOptions.ShareWithUsers, sharingOptions.ShareWithGroups)
+	if err != nil {
+		return err
+	}
+	resources := make([]*resource, 0, len(resourceIDs))
+	for _, resourceID := range resourceIDs {
+		r, ok := t.server.resources[resourceID]
+		if !ok || !t.server.canDecrypt(t.user, r) {
+			return newError(coretypes.ErrorInvalidArgument, "key not found for resource %s", resourceID)
+		}
+		resources = append(resources, r)
+	}
+	for _, r := range resources {
+		r.share(with)
+	}
+	return nil
+}
+
+// CreateGroup creates a group with the given members and returns its ID.
+func (t *Tanker) CreateGroup(publicIdentities []string) (*string, error) {
+	return t.CreateGroupContext(context.Background(), publicIdentities)
+}
+
+// CreateGroupContext is like CreateGroup but fails if ctx is done.
+func (t *Tanker) CreateGroupContext(ctx context.Context, publicIdentities []string) (*string, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	if len(publicIdentities) == 0 {
+		return nil, newError(coretypes.ErrorInvalidArgument, "cannot create an empty group")
+	}
+	if len(publicIdentities) > maxGroupSize {
+		return nil, coretypes.NewGroupTooBigError("", fmt.Sprintf("cannot create a group with more than %d members", maxGroupSize), len(publicIdentities), maxGroupSize)
+	}
+	members, err := t.server.resolveRecipients(publicIdentities, nil)
+	if err != nil {
+		return nil, err
+	}
+	g := &group{
+		id:           randomID(keySize),
+		users:        make(map[string]bool),
+		provisionals: make(map[string]bool),
+	}
+	g.add(members)
+	t.server.groups[g.id] = g
+	groupID := g.id
+	return &groupID, nil
+}
+
+// UpdateGroupMembers adds members to a group the current user is a member of.
+func (t *Tanker) UpdateGroupMembers(groupID string, publicIdentitiesToAdd []string) error {
+	return t.UpdateGroupMembersContext(context.Background(), groupID, publicIdentitiesToAdd)
+}
+
+// UpdateGroupMembersContext is like UpdateGroupMembers but fails if ctx is done.
+func (t *Tanker) UpdateGroupMembersContext(ctx context.Context, groupID string, publicIdentitiesToAdd []string) error {
+	return t.EditGroupMembersContext(ctx, groupID, coretypes.GroupMembersUpdate{UsersToAdd: publicIdentitiesToAdd})
+}
+
+// EditGroupMembers adds and removes members of a group the current user is a member of.
+func (t *Tanker) EditGroupMembers(groupID string, update coretypes.GroupMembersUpdate) error {
+	return t.EditGroupMembersContext(context.Background(), groupID, update)
+}
+
+// EditGroupMembersContext is like EditGroupMembers but fails if ctx is done.
+func (t *Tanker) EditGroupMembersContext(ctx context.Context, groupID string, update coretypes.GroupMembersUpdate) error {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return err
+	}
+	g, ok := t.server.groups[groupID]
+	if !ok {
+		return newError(coretypes.ErrorInvalidArgument, "group not found: %s", groupID)
+	}
+	if !t.server.isMember(t.user, g) {
+		return newError(coretypes.ErrorInvalidArgument, "the current user is not a member of group %s", groupID)
+	}
+	if len(update.UsersToAdd) == 0 && len(update.UsersToRemove) == 0 {
+		return newError(coretypes.ErrorInvalidArgument, "no members to add or remove")
+	}
+	for _, size := range []int{len(update.UsersToAdd), len(update.UsersToRemove)} {
+		if size > maxGroupSize {
+			return coretypes.NewGroupTooBigError("", fmt.Sprintf("cannot add or remove more than %d members at once", maxGroupSize), size, maxGroupSize)
+		}
+	}
+	toAdd, err := t.server.resolveRecipients(update.UsersToAdd, nil)
+	if err != nil {
+		return err
+	}
+	toRemove, err := t.server.resolveRecipients(update.UsersToRemove, nil)
+	if err != nil {
+		return err
+	}
+	if err = g.checkRemoval(toAdd, toRemove); err != nil {
+		return err
+	}
+	g.remove(toRemove)
+	g.add(toAdd)
+	return nil
+}
+
+// EncryptionSession simulates an encryption session: every resource it encrypts
+// shares the same resource ID and key.
+type EncryptionSession struct {
+	tanker     *Tanker
+	resourceID string
+	rawID      []byte
+	key        []byte
+}
+
+var _ coretypes.EncryptionSessionClient = (*EncryptionSession)(nil)
+
+// CreateEncryptionSession creates an encryption session sharing its resources according to the options.
+func (t *Tanker) CreateEncryptionSession(encryptionOptions *coretypes.EncryptionOptions) (coretypes.EncryptionSessionClient, error) {
+	return t.CreateEncryptionSessionContext(context.Background(), encryptionOptions)
+}
+
+// CreateEncryptionSessionContext is like CreateEncryptionSession but fails if ctx is done.
+func (t *Tanker) CreateEncryptionSessionContext(ctx context.Context, encryptionOptions *coretypes.EncryptionOptions) (coretypes.EncryptionSessionClient, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	resourceID, rawID, key, err := t.newSharedResource(encryptionOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptionSession{tanker: t, resourceID: resourceID, rawID: rawID, key: key}, nil
+}
+
+// Destroy does nothing, sessions hold no resources.
+func (s *EncryptionSession) Destroy() {}
+
+// GetResourceId returns the resource ID shared by all the resources of the session.
+func (s *EncryptionSession) GetResourceId() string {
+	return s.resourceID
+}
+
+// Encrypt encrypts clearData with the session key.
+func (s *EncryptionSession) Encrypt(clearData []byte) ([]byte, error) {
+	return s.EncryptContext(context.Background(), clearData)
+}
+
+// EncryptContext is like Encrypt but fails if ctx is done.
+func (s *EncryptionSession) EncryptContext(ctx context.Context, clearData []byte) ([]byte, error) {
+	unlock, err := s.tanker.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = s.tanker.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	if clearData == nil {
+		return nil, newError(coretypes.ErrorInvalidArgument, "clearData must not be nil")
+	}
+	return seal(s.key, s.rawID, clearData), nil
+}
@@ -0,0 +1,59 @@
+package coretest
+
+import (
+	"fmt"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// tankerError satisfies core.Error and matches the core.Err* sentinels, so that
+// code under test can inspect the errors of the simulator the same way as the
+// ones of core. The simulator does not name the failing operations.
+type tankerError struct {
+	code    coretypes.ErrorCode
+	message string
+	cause   error
+}
+
+func newError(code coretypes.ErrorCode, format string, args ...interface{}) error {
+	return &tankerError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func newCanceledError(cause error) error {
+	return &tankerError{
+		code:    coretypes.ErrorOperationCanceled,
+		message: "operation canceled: " + cause.Error(),
+		cause:   cause,
+	}
+}
+
+func (e tankerError) Error() string {
+	return e.message
+}
+
+func (e tankerError) Code() coretypes.ErrorCode {
+	return e.code
+}
+
+func (e tankerError) Operation() string {
+	return ""
+}
+
+func (e tankerError) Message() string {
+	return e.message
+}
+
+func (e tankerError) Attempts() int {
+	return 1
+}
+
+func (e tankerError) Unwrap() error {
+	return e.cause
+}
+
+func (e tankerError) Is(target error) bool {
+	code, ok := target.(coretypes.ErrorCode)
+	return ok && code == e.code
+}
+
+var _ coretypes.Error = (*tankerError)(nil)
@@ -0,0 +1,97 @@
+package coretest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// SeekableStream simulates a random-access decryption stream. The simulator
+// has no chunks: the whole resource is decrypted when the stream is created.
+type SeekableStream struct {
+	resourceID string
+
+	mutex     sync.Mutex
+	reader    *bytes.Reader
+	destroyed bool
+}
+
+var _ coretypes.SeekableStream = (*SeekableStream)(nil)
+
+func (s *SeekableStream) check() error {
+	if s.destroyed {
+		return newError(coretypes.ErrorPreconditionFailed, "the stream has been destroyed")
+	}
+	return nil
+}
+
+// Read reads the clear data from the current position.
+func (s *SeekableStream) Read(buffer []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+	return s.reader.Read(buffer)
+}
+
+// ReadAt reads the clear data at the given offset.
+func (s *SeekableStream) ReadAt(buffer []byte, off int64) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+	return s.reader.ReadAt(buffer, off)
+}
+
+// Seek sets the position of the next Read.
+func (s *SeekableStream) Seek(offset int64, whence int) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reader.Seek(offset, whence)
+}
+
+// Size returns the size of the clear data.
+func (s *SeekableStream) Size() int64 {
+	return s.reader.Size()
+}
+
+// Destroy makes the stream unusable.
+func (s *SeekableStream) Destroy() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.destroyed = true
+}
+
+// GetResourceID returns the resource ID of the stream.
+func (s *SeekableStream) GetResourceID() (*string, error) {
+	resourceID := s.resourceID
+	return &resourceID, nil
+}
+
+// NewDecryptReaderAt creates a random-access stream decrypting the size bytes read from src.
+func (t *Tanker) NewDecryptReaderAt(src io.ReaderAt, size int64) (coretypes.SeekableStream, error) {
+	return t.NewDecryptReaderAtContext(context.Background(), src, size)
+}
+
+// NewDecryptReaderAtContext is like NewDecryptReaderAt but fails if ctx is done.
+func (t *Tanker) NewDecryptReaderAtContext(ctx context.Context, src io.ReaderAt, size int64) (coretypes.SeekableStream, error) {
+	if size <= 0 {
+		return nil, newError(coretypes.ErrorInvalidArgument, "size must be positive")
+	}
+	encryptedData, err := ioutil.ReadAll(io.NewSectionReader(src, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	clearData, err := t.DecryptContext(ctx, encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	resourceID, _ := parseResourceID(encryptedData)
+	return &SeekableStream{resourceID: resourceID, reader: bytes.NewReader(clearData)}, nil
+}
@@ -0,0 +1,390 @@
+// Package coretest provides an in-memory functional fake of the Tanker SDK.
+//
+// A Server simulates a Tanker application and its server: users, devices,
+// groups, provisional identities and resource keys. The Tanker instances it
+// creates behave like core.Tanker and satisfy core.Client: data is really
+// encrypted (with AES-GCM) and can only be decrypted by its recipients, and
+// failures are reported with the error codes of the core package.
+// No native library nor network access is needed: coretest only depends on the
+// coretypes package, which builds without cgo.
+//
+//  server := coretest.NewServer()
+//  aliceIdentity, _ := server.CreateIdentity("alice")
+//  bobIdentity, bobPublicIdentity := server.CreateIdentity("bob")
+//  alice := server.NewTanker(core.TankerOptions{WritablePath: "alice-laptop"})
+//  ...
+//
+// Identities must be created with the Server, they are not compatible
+// with the ones of the identity-go package. EncryptionOptions.PaddingStep is
+// ignored, the encrypted data is never padded.
+package coretest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+const (
+	// maxGroupSize is the maximum number of members of a group creation, and of
+	// the members added or removed at once.
+	maxGroupSize = 1000
+	// maxVerificationAttempts is the number of consecutive failed identity
+	// verifications after which ErrorTooManyAttempts is returned.
+	maxVerificationAttempts = 3
+
+	targetUser  = "user"
+	targetEmail = "email"
+)
+
+// identity is the content of the identities created by a Server.
+// Public identities have no secret.
+type identity struct {
+	Target string `json:"target"`
+	Value  string `json:"value"`
+	Secret string `json:"secret,omitempty"`
+}
+
+func (id identity) encode() string {
+	bytes, err := json.Marshal(id)
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(bytes)
+}
+
+func decodeIdentity(encoded string) (*identity, error) {
+	bytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, newError(coretypes.ErrorInvalidArgument, "invalid identity: %v", err)
+	}
+	id := identity{}
+	if err = json.Unmarshal(bytes, &id); err != nil {
+		return nil, newError(coretypes.ErrorInvalidArgument, "invalid identity: %v", err)
+	}
+	if id.Target != targetUser && id.Target != targetEmail {
+		return nil, newError(coretypes.ErrorInvalidArgument, "invalid identity target %q", id.Target)
+	}
+	return &id, nil
+}
+
+type user struct {
+	id                 string
+	devices            []*device
+	passphrase         *string
+	verificationKey    *string
+	oidcIDToken        *string
+	email              *string
+	failedVerification int
+}
+
+func (u *user) verificationMethods() []coretypes.VerificationMethod {
+	methods := []coretypes.VerificationMethod{}
+	if u.email != nil {
+		email := *u.email
+		methods = append(methods, coretypes.VerificationMethod{Type: coretypes.VerificationMethodEmail, Email: &email})
+	}
+	if u.passphrase != nil {
+		methods = append(methods, coretypes.VerificationMethod{Type: coretypes.VerificationMethodPassphrase})
+	}
+	if u.verificationKey != nil {
+		methods = append(methods, coretypes.VerificationMethod{Type: coretypes.VerificationMethodVerificationKey})
+	}
+	if u.oidcIDToken != nil {
+		methods = append(methods, coretypes.VerificationMethod{Type: coretypes.VerificationMethodOidcIdToken})
+	}
+	return methods
+}
+
+type device struct {
+	id      string
+	user    *user
+	revoked bool
+	// tanker is the instance currently using this device, if any
+	tanker *Tanker
+}
+
+type provisional struct {
+	email     string
+	secret    string
+	claimedBy *user
+}
+
+type group struct {
+	id           string
+	users        map[string]bool
+	provisionals map[string]bool
+}
+
+type resource struct {
+	key          []byte
+	users        map[string]bool
+	groups       map[string]bool
+	provisionals map[string]bool
+}
+
+// recipients is a resolved list of public identities and group IDs.
+type recipients struct {
+	users        []string
+	groups       []string
+	provisionals []string
+}
+
+// Server simulates a Tanker application and the Tanker server.
+// It is safe for concurrent use.
+type Server struct {
+	mutex sync.Mutex
+	// secrets of the user identities, by user ID
+	identities   map[string]string
+	provisionals map[string]*provisional
+	users        map[string]*user
+	// devices by writable path and user ID
+	devices           map[string]map[string]*device
+	groups            map[string]*group
+	resources         map[string]*resource
+	verificationCodes map[string]string
+}
+
+// NewServer creates an empty Server.
+func NewServer() *Server {
+	return &Server{
+		identities:        make(map[string]string),
+		provisionals:      make(map[string]*provisional),
+		users:             make(map[string]*user),
+		devices:           make(map[string]map[string]*device),
+		groups:            make(map[string]*group),
+		resources:         make(map[string]*resource),
+		verificationCodes: make(map[string]string),
+	}
+}
+
+// CreateIdentity creates the private identity of a user, to be passed to Start(),
+// and the matching public identity, to be used to share with this user.
+func (s *Server) CreateIdentity(userID string) (privateIdentity string, publicIdentity string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	secret, ok := s.identities[userID]
+	if !ok {
+		secret = randomID(keySize)
+		s.identities[userID] = secret
+	}
+	return identity{Target: targetUser, Value: userID, Secret: secret}.encode(),
+		identity{Target: targetUser, Value: userID}.encode()
+}
+
+// CreateProvisionalIdentity creates the private provisional identity for an email address,
+// to be passed to AttachProvisionalIdentity(), and the matching public identity, to be used
+// to share with the future owner of this email address.
+func (s *Server) CreateProvisionalIdentity(email string) (privateIdentity string, publicIdentity string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p, ok := s.provisionals[email]
+	if !ok {
+		p = &provisional{email: email, secret: randomID(keySize)}
+		s.provisionals[email] = p
+	}
+	return identity{Target: targetEmail, Value: email, Secret: p.secret}.encode(),
+		identity{Target: targetEmail, Value: email}.encode()
+}
+
+// GetVerificationCode returns the verification code which would have been sent to the
+// given email address, to be used in a core.EmailVerification.
+func (s *Server) GetVerificationCode(email string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	code, ok := s.verificationCodes[email]
+	if !ok {
+		code = fmt.Sprintf("%08x", randomBytes(4))
+		s.verificationCodes[email] = code
+	}
+	return code
+}
+
+// NewTanker creates a Tanker instance. The WritablePath of the options identifies
+// the local storage of the device: a Tanker instance created later with the same
+// path is started on the same device. Other options are ignored.
+func (s *Server) NewTanker(options coretypes.TankerOptions) *Tanker {
+	return &Tanker{
+		server:       s,
+		writablePath: options.WritablePath,
+		status:       coretypes.StatusStopped,
+		handlers:     make(map[coretypes.EventType]map[uint64]coretypes.EventHandler),
+	}
+}
+
+// StartDevice creates a Tanker instance on the device stored at path, and starts
+// it with the identity: the identity is registered or verified with the
+// verification if needed, so that the returned Tanker is ready.
+func (s *Server) StartDevice(identity string, path string, verification coretypes.Verification) (*Tanker, error) {
+	tanker := s.NewTanker(coretypes.TankerOptions{WritablePath: path})
+	status, err := tanker.Start(identity)
+	switch {
+	case err != nil:
+	case status == coretypes.StatusIdentityRegistrationNeeded:
+		err = tanker.RegisterIdentity(verification)
+	case status == coretypes.StatusIdentityVerificationNeeded:
+		err = tanker.VerifyIdentity(verification)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tanker, nil
+}
+
+// checkPrivateIdentity must be called with the mutex held.
+func (s *Server) checkPrivateIdentity(encoded string, target string) (*identity, error) {
+	id, err := decodeIdentity(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if id.Target != target || id.Secret == "" {
+		return nil, newError(coretypes.ErrorInvalidArgument, "expected a private %s identity", target)
+	}
+	var secret string
+	if target == targetUser {
+		secret = s.identities[id.Value]
+	} else if p, ok := s.provisionals[id.Value]; ok {
+		secret = p.secret
+	}
+	if secret == "" || secret != id.Secret {
+		return nil, newError(coretypes.ErrorInvalidArgument, "unknown identity")
+	}
+	return id, nil
+}
+
+// resolveRecipients checks the recipients of an encryption or a sharing.
+// Must be called with the mutex held.
+func (s *Server) resolveRecipients(publicIdentities []string, groupIDs []string) (*recipients, error) {
+	resolved := &recipients{}
+	for _, encoded := range publicIdentities {
+		id, err := decodeIdentity(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if id.Secret != "" {
+			return nil, newError(coretypes.ErrorInvalidArgument, "expected a public identity, got a private one")
+		}
+		switch id.Target {
+		case targetUser:
+			if _, ok := s.users[id.Value]; !ok {
+				return nil, newError(coretypes.ErrorInvalidArgument, "user not found: %s", id.Value)
+			}
+			resolved.users = append(resolved.users, id.Value)
+		case targetEmail:
+			if _, ok := s.provisionals[id.Value]; !ok {
+				return nil, newError(coretypes.ErrorInvalidArgument, "unknown provisional identity")
+			}
+			resolved.provisionals = append(resolved.provisionals, id.Value)
+		}
+	}
+	for _, groupID := range groupIDs {
+		if _, ok := s.groups[groupID]; !ok {
+			return nil, newError(coretypes.ErrorInvalidArgument, "group not found: %s", groupID)
+		}
+		resolved.groups = append(resolved.groups, groupID)
+	}
+	return resolved, nil
+}
+
+// isMember must be called with the mutex held.
+func (s *Server) isMember(u *user, g *group) bool {
+	if g.users[u.id] {
+		return true
+	}
+	for email := range g.provisionals {
+		if s.provisionals[email].claimedBy == u {
+			return true
+		}
+	}
+	return false
+}
+
+// canDecrypt must be called with the mutex held.
+func (s *Server) canDecrypt(u *user, r *resource) bool {
+	if r.users[u.id] {
+		return true
+	}
+	for groupID := range r.groups {
+		if s.isMember(u, s.groups[groupID]) {
+			return true
+		}
+	}
+	for email := range r.provisionals {
+		if s.provisionals[email].claimedBy == u {
+			return true
+		}
+	}
+	return false
+}
+
+// newResource must be called with the mutex held.
+func (s *Server) newResource() (string, []byte, *resource) {
+	rawID := randomBytes(resourceIDSize)
+	r := &resource{
+		key:          randomBytes(keySize),
+		users:        make(map[string]bool),
+		groups:       make(map[string]bool),
+		provisionals: make(map[string]bool),
+	}
+	resourceID := base64.StdEncoding.EncodeToString(rawID)
+	s.resources[resourceID] = r
+	return resourceID, rawID, r
+}
+
+func (r *resource) share(with *recipients) {
+	for _, userID := range with.users {
+		r.users[userID] = true
+	}
+	for _, groupID := range with.groups {
+		r.groups[groupID] = true
+	}
+	for _, email := range with.provisionals {
+		r.provisionals[email] = true
+	}
+}
+
+func (g *group) add(members *recipients) {
+	for _, userID := range members.users {
+		g.users[userID] = true
+	}
+	for _, email := range members.provisionals {
+		g.provisionals[email] = true
+	}
+}
+
+// checkRemoval fails if members cannot be removed from the group, or are also added.
+func (g *group) checkRemoval(toAdd *recipients, toRemove *recipients) error {
+	added := make(map[string]bool)
+	for _, member := range append(append([]string{}, toAdd.users...), toAdd.provisionals...) {
+		added[member] = true
+	}
+	for _, userID := range toRemove.users {
+		if !g.users[userID] {
+			return newError(coretypes.ErrorInvalidArgument, "user %s is not a member of the group", userID)
+		}
+		if added[userID] {
+			return newError(coretypes.ErrorInvalidArgument, "user %s cannot be both added and removed", userID)
+		}
+	}
+	for _, email := range toRemove.provisionals {
+		if !g.provisionals[email] {
+			return newError(coretypes.ErrorInvalidArgument, "provisional identity %s is not a member of the group", email)
+		}
+		if added[email] {
+			return newError(coretypes.ErrorInvalidArgument, "provisional identity %s cannot be both added and removed", email)
+		}
+	}
+	return nil
+}
+
+func (g *group) remove(members *recipients) {
+	for _, userID := range members.users {
+		delete(g.users, userID)
+	}
+	for _, email := range members.provisionals {
+		delete(g.provisionals, email)
+	}
+}
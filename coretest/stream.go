@@ -0,0 +1,169 @@
+package coretest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// Stream simulates an encryption or decryption stream. The whole input
+// is buffered in memory: encryption streams read their source on the first
+// Read(), decryption streams on creation. The encrypted format is the one of
+// Encrypt(), so that streams and buffers can be decrypted either way.
+type Stream struct {
+	resourceID string
+	created    time.Time
+
+	mutex   sync.Mutex
+	produce func() ([]byte, error)
+	output  *bytes.Reader
+	err     error
+	closed  bool
+	// stats.Elapsed is only set on Close()
+	stats coretypes.StreamStats
+}
+
+var _ coretypes.Stream = (*Stream)(nil)
+
+// Read reads the encrypted or clear data.
+func (s *Stream) Read(buffer []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return 0, newError(coretypes.ErrorPreconditionFailed, "the stream has been closed")
+	}
+	if s.output == nil && s.err == nil {
+		output, err := s.produce()
+		s.output, s.err = bytes.NewReader(output), err
+		s.stats.BytesProduced = int64(len(output))
+		if len(output) > 0 {
+			s.stats.Chunks = 1
+		}
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.output.Read(buffer)
+}
+
+// Close makes the stream unusable.
+func (s *Stream) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.closed {
+		s.stats.Elapsed = time.Since(s.created)
+	}
+	s.closed = true
+	return nil
+}
+
+// Stats returns the statistics of the stream. The whole output is produced
+// at once, as a single chunk.
+func (s *Stream) Stats() coretypes.StreamStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats := s.stats
+	if !s.closed {
+		stats.Elapsed = time.Since(s.created)
+	}
+	return stats
+}
+
+// Destroy closes the stream.
+func (s *Stream) Destroy() {
+	_ = s.Close()
+}
+
+// GetResourceID returns the resource ID of the stream.
+func (s *Stream) GetResourceID() (*string, error) {
+	resourceID := s.resourceID
+	return &resourceID, nil
+}
+
+func newEncryptionStream(reader io.Reader, resourceID string, rawID []byte, key []byte) *Stream {
+	s := &Stream{resourceID: resourceID, created: time.Now()}
+	// produce is called with the mutex held
+	s.produce = func() ([]byte, error) {
+		clearData, err := ioutil.ReadAll(reader)
+		s.stats.BytesRead = int64(len(clearData))
+		if err != nil {
+			return nil, err
+		}
+		return seal(key, rawID, clearData), nil
+	}
+	return s
+}
+
+// StreamEncrypt creates a stream encrypting the data read from reader.
+func (t *Tanker) StreamEncrypt(reader io.Reader, options *coretypes.EncryptionOptions) (coretypes.Stream, error) {
+	return t.StreamEncryptContext(context.Background(), reader, options)
+}
+
+// StreamEncryptContext is like StreamEncrypt but fails if ctx is done.
+func (t *Tanker) StreamEncryptContext(ctx context.Context, reader io.Reader, options *coretypes.EncryptionOptions) (coretypes.Stream, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	resourceID, rawID, key, err := t.newSharedResource(options)
+	if err != nil {
+		return nil, err
+	}
+	return newEncryptionStream(reader, resourceID, rawID, key), nil
+}
+
+// StreamEncrypt creates a stream encrypting the data read from reader with the session key.
+func (s *EncryptionSession) StreamEncrypt(reader io.Reader) (coretypes.Stream, error) {
+	return s.StreamEncryptContext(context.Background(), reader)
+}
+
+// StreamEncryptContext is like StreamEncrypt but fails if ctx is done.
+func (s *EncryptionSession) StreamEncryptContext(ctx context.Context, reader io.Reader) (coretypes.Stream, error) {
+	unlock, err := s.tanker.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = s.tanker.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	return newEncryptionStream(reader, s.resourceID, s.rawID, s.key), nil
+}
+
+// StreamDecrypt creates a stream decrypting the data read from reader.
+func (t *Tanker) StreamDecrypt(reader io.Reader) (coretypes.Stream, error) {
+	return t.StreamDecryptContext(context.Background(), reader)
+}
+
+// StreamDecryptContext is like StreamDecrypt but fails if ctx is done.
+func (t *Tanker) StreamDecryptContext(ctx context.Context, reader io.Reader) (coretypes.Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newCanceledError(err)
+	}
+	encryptedData, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	clearData, err := t.DecryptContext(ctx, encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	resourceID, _ := parseResourceID(encryptedData)
+	return &Stream{
+		resourceID: resourceID,
+		created:    time.Now(),
+		produce: func() ([]byte, error) {
+			return clearData, nil
+		},
+		stats: coretypes.StreamStats{BytesRead: int64(len(encryptedData))},
+	}, nil
+}
@@ -0,0 +1,308 @@
+package coretest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// eventBufferSize is the capacity of the channels returned by Events().
+const eventBufferSize = 8
+
+// Tanker simulates a Tanker instance running on a device, it satisfies core.Client.
+// Tanker instances are created with Server.NewTanker().
+type Tanker struct {
+	server       *Server
+	writablePath string
+
+	// The session state is guarded by the server mutex
+	status          coretypes.Status
+	identity        *identity
+	user            *user
+	device          *device
+	verificationKey *string
+	attached        *provisional
+	destroyed       bool
+	// events to emit once the server mutex is released
+	pending []coretypes.EventType
+
+	eventMutex  sync.Mutex
+	nextID      uint64
+	handlers    map[coretypes.EventType]map[uint64]coretypes.EventHandler
+	subscribers []chan coretypes.EventType
+}
+
+var _ coretypes.Client = (*Tanker)(nil)
+
+// lock checks ctx and locks the server mutex. The returned function must be called to unlock it.
+func (t *Tanker) lock(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newCanceledError(err)
+	}
+	t.server.mutex.Lock()
+	return t.unlock, nil
+}
+
+func (t *Tanker) unlock() {
+	pending := t.pending
+	t.pending = nil
+	t.server.mutex.Unlock()
+	for _, event := range pending {
+		t.emit(event)
+	}
+}
+
+// checkStatus must be called with the server mutex held.
+func (t *Tanker) checkStatus(expected coretypes.Status) error {
+	if t.destroyed {
+		return newError(coretypes.ErrorPreconditionFailed, "this Tanker instance has been destroyed")
+	}
+	if t.device != nil && t.device.revoked {
+		t.stop()
+		t.pending = append(t.pending, coretypes.EventDeviceRevoked)
+		return newError(coretypes.ErrorDeviceRevoked, "this device has been revoked")
+	}
+	if t.status != expected {
+		return newError(coretypes.ErrorPreconditionFailed, "invalid status %d, expected %d", t.status, expected)
+	}
+	return nil
+}
+
+// stop must be called with the server mutex held.
+func (t *Tanker) stop() {
+	if t.status == coretypes.StatusStopped {
+		return
+	}
+	if t.device != nil && t.device.tanker == t {
+		t.device.tanker = nil
+	}
+	t.status = coretypes.StatusStopped
+	t.identity = nil
+	t.user = nil
+	t.device = nil
+	t.verificationKey = nil
+	t.attached = nil
+	t.pending = append(t.pending, coretypes.EventSessionClosed)
+}
+
+// openDevice creates a device for the current user and starts the session.
+// Must be called with the server mutex held.
+func (t *Tanker) openDevice() {
+	d := &device{id: randomID(keySize), user: t.user, tanker: t}
+	t.user.devices = append(t.user.devices, d)
+	if t.server.devices[t.writablePath] == nil {
+		t.server.devices[t.writablePath] = make(map[string]*device)
+	}
+	t.server.devices[t.writablePath][t.user.id] = d
+	t.device = d
+	t.status = coretypes.StatusReady
+}
+
+// Destroy stops the session, no further operation is possible on this instance.
+func (t *Tanker) Destroy() error {
+	unlock, _ := t.lock(context.Background())
+	t.stop()
+	t.destroyed = true
+	unlock()
+
+	t.eventMutex.Lock()
+	defer t.eventMutex.Unlock()
+	for _, subscriber := range t.subscribers {
+		close(subscriber)
+	}
+	t.subscribers = nil
+	return nil
+}
+
+// Start starts a session for the given private identity, created with Server.CreateIdentity().
+func (t *Tanker) Start(identity string) (coretypes.Status, error) {
+	return t.StartContext(context.Background(), identity)
+}
+
+// StartContext is like Start but fails if ctx is done.
+func (t *Tanker) StartContext(ctx context.Context, identity string) (coretypes.Status, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return coretypes.StatusStopped, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusStopped); err != nil {
+		return coretypes.StatusStopped, err
+	}
+	id, err := t.server.checkPrivateIdentity(identity, targetUser)
+	if err != nil {
+		return coretypes.StatusStopped, err
+	}
+	d := t.server.devices[t.writablePath][id.Value]
+	if d != nil && d.revoked {
+		delete(t.server.devices[t.writablePath], id.Value)
+		return coretypes.StatusStopped, newError(coretypes.ErrorDeviceRevoked, "this device has been revoked")
+	}
+	if d != nil && d.tanker != nil {
+		return coretypes.StatusStopped, newError(coretypes.ErrorPreconditionFailed, "this device is already used by another Tanker instance")
+	}
+
+	t.identity = id
+	t.user = t.server.users[id.Value]
+	switch {
+	case d != nil:
+		d.tanker = t
+		t.device = d
+		t.status = coretypes.StatusReady
+	case t.user == nil:
+		t.status = coretypes.StatusIdentityRegistrationNeeded
+	default:
+		t.status = coretypes.StatusIdentityVerificationNeeded
+	}
+	return t.status, nil
+}
+
+// Stop stops the session.
+func (t *Tanker) Stop() error {
+	return t.StopContext(context.Background())
+}
+
+// StopContext is like Stop but fails if ctx is done.
+func (t *Tanker) StopContext(ctx context.Context) error {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	t.stop()
+	return nil
+}
+
+// GetStatus returns the current session status.
+func (t *Tanker) GetStatus() coretypes.Status {
+	unlock, _ := t.lock(context.Background())
+	defer unlock()
+	return t.status
+}
+
+// RegisterEventHandler registers an event handler for the given EventType.
+// Handlers are called synchronously, once the operation emitting the event has completed.
+func (t *Tanker) RegisterEventHandler(event coretypes.EventType, handler coretypes.EventHandler) (func(), error) {
+	if event != coretypes.EventSessionClosed && event != coretypes.EventDeviceRevoked {
+		return nil, newError(coretypes.ErrorInvalidArgument, "unknown event type")
+	}
+	if handler == nil {
+		return nil, newError(coretypes.ErrorInvalidArgument, "handler must not be nil")
+	}
+	t.eventMutex.Lock()
+	defer t.eventMutex.Unlock()
+	if t.handlers[event] == nil {
+		t.handlers[event] = make(map[uint64]coretypes.EventHandler)
+	}
+	id := t.nextID
+	t.nextID++
+	t.handlers[event][id] = handler
+	return func() {
+		t.eventMutex.Lock()
+		defer t.eventMutex.Unlock()
+		delete(t.handlers[event], id)
+	}, nil
+}
+
+// Events returns a channel on which every event of this instance is sent.
+// The channel is closed by Destroy().
+func (t *Tanker) Events() <-chan coretypes.EventType {
+	t.eventMutex.Lock()
+	defer t.eventMutex.Unlock()
+	subscriber := make(chan coretypes.EventType, eventBufferSize)
+	if t.destroyed {
+		close(subscriber)
+		return subscriber
+	}
+	t.subscribers = append(t.subscribers, subscriber)
+	return subscriber
+}
+
+func (t *Tanker) emit(event coretypes.EventType) {
+	t.eventMutex.Lock()
+	handlers := make([]coretypes.EventHandler, 0, len(t.handlers[event]))
+	for _, handler := range t.handlers[event] {
+		handlers = append(handlers, handler)
+	}
+	for _, subscriber := range t.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+	t.eventMutex.Unlock()
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+// GetDeviceID returns the ID of the current device.
+func (t *Tanker) GetDeviceID() (*string, error) {
+	return t.GetDeviceIDContext(context.Background())
+}
+
+// GetDeviceIDContext is like GetDeviceID but fails if ctx is done.
+func (t *Tanker) GetDeviceIDContext(ctx context.Context) (*string, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	deviceID := t.device.id
+	return &deviceID, nil
+}
+
+// GetDeviceList returns the devices of the current user.
+func (t *Tanker) GetDeviceList() ([]coretypes.DeviceDescription, error) {
+	return t.GetDeviceListContext(context.Background())
+}
+
+// GetDeviceListContext is like GetDeviceList but fails if ctx is done.
+func (t *Tanker) GetDeviceListContext(ctx context.Context) ([]coretypes.DeviceDescription, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	devices := make([]coretypes.DeviceDescription, 0, len(t.user.devices))
+	for _, d := range t.user.devices {
+		devices = append(devices, coretypes.DeviceDescription{DeviceID: d.id, IsRevoked: d.revoked})
+	}
+	return devices, nil
+}
+
+// RevokeDevice revokes one of the devices of the current user. The Tanker instance
+// running on the revoked device fails with ErrorDeviceRevoked on its next operation,
+// and emits EventDeviceRevoked.
+func (t *Tanker) RevokeDevice(deviceID string) error {
+	return t.RevokeDeviceContext(context.Background(), deviceID)
+}
+
+// RevokeDeviceContext is like RevokeDevice but fails if ctx is done.
+func (t *Tanker) RevokeDeviceContext(ctx context.Context, deviceID string) error {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return err
+	}
+	for _, d := range t.user.devices {
+		if d.id == deviceID {
+			if d.revoked {
+				return newError(coretypes.ErrorInvalidArgument, "device %s is already revoked", deviceID)
+			}
+			d.revoked = true
+			return nil
+		}
+	}
+	return newError(coretypes.ErrorInvalidArgument, "unknown device %s", deviceID)
+}
@@ -0,0 +1,439 @@
+package coretest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/coretest"
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+var _ = Describe("Simulator", func() {
+	var (
+		server       *coretest.Server
+		alice        user
+		bob          user
+		aliceSession *coretest.Tanker
+	)
+
+	BeforeEach(func() {
+		server = coretest.NewServer()
+		alice = createUser(server, "alice")
+		bob = createUser(server, "bob")
+		aliceSession = startDevice(server, alice, "alice-laptop")
+	})
+
+	Context("Session", func() {
+		It("Goes through the status transitions", func() {
+			laptop := server.NewTanker(coretypes.TankerOptions{WritablePath: "bob-laptop"})
+			status, err := laptop.Start(bob.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).To(Equal(coretypes.StatusIdentityRegistrationNeeded))
+			Expect(laptop.RegisterIdentity(coretypes.PassphraseVerification{Passphrase: "pass"})).To(Succeed())
+			Expect(laptop.GetStatus()).To(Equal(coretypes.StatusReady))
+			Expect(laptop.Stop()).To(Succeed())
+			Expect(laptop.GetStatus()).To(Equal(coretypes.StatusStopped))
+
+			status, err = laptop.Start(bob.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).To(Equal(coretypes.StatusReady))
+
+			mobile := server.NewTanker(coretypes.TankerOptions{WritablePath: "bob-mobile"})
+			status, err = mobile.Start(bob.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).To(Equal(coretypes.StatusIdentityVerificationNeeded))
+			expectErrorCode(mobile.VerifyIdentity(coretypes.PassphraseVerification{Passphrase: "wrong"}), coretypes.ErrorInvalidVerification)
+			Expect(mobile.VerifyIdentity(coretypes.PassphraseVerification{Passphrase: "pass"})).To(Succeed())
+			Expect(mobile.GetStatus()).To(Equal(coretypes.StatusReady))
+		})
+
+		It("Verifies identities with secret buffers", func() {
+			laptop := server.NewTanker(coretypes.TankerOptions{WritablePath: "bob-laptop"})
+			_, err := laptop.Start(bob.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			passphrase := coretypes.NewSecretBufferFrom([]byte("pass"))
+			Expect(laptop.RegisterIdentity(&coretypes.PassphraseVerification{SecretPassphrase: passphrase})).To(Succeed())
+
+			mobile := server.NewTanker(coretypes.TankerOptions{WritablePath: "bob-mobile"})
+			_, err = mobile.Start(bob.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			passphrase.Destroy()
+			expectErrorCode(mobile.VerifyIdentity(coretypes.PassphraseVerification{SecretPassphrase: passphrase}), coretypes.ErrorInvalidArgument)
+			Expect(mobile.VerifyIdentity(coretypes.PassphraseVerification{Passphrase: "pass"})).To(Succeed())
+		})
+
+		It("Fails when it opens the same device twice", func() {
+			again := server.NewTanker(coretypes.TankerOptions{WritablePath: "alice-laptop"})
+			_, err := again.Start(alice.Identity)
+			expectErrorCode(err, coretypes.ErrorPreconditionFailed)
+		})
+
+		It("Rejects operations with an invalid status", func() {
+			Expect(aliceSession.Stop()).To(Succeed())
+			_, err := aliceSession.Encrypt([]byte("data"), nil)
+			expectErrorCode(err, coretypes.ErrorPreconditionFailed)
+		})
+
+		It("Refuses identities it did not create", func() {
+			tanker := server.NewTanker(coretypes.TankerOptions{WritablePath: "somewhere"})
+			_, err := tanker.Start("not an identity")
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+			_, err = tanker.Start(alice.PublicIdentity)
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+		})
+
+		It("Limits the number of verification attempts", func() {
+			mobile := server.NewTanker(coretypes.TankerOptions{WritablePath: "alice-mobile"})
+			_, err := mobile.Start(alice.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 3; i++ {
+				expectErrorCode(mobile.VerifyIdentity(coretypes.PassphraseVerification{Passphrase: "wrong"}), coretypes.ErrorInvalidVerification)
+			}
+			expectErrorCode(mobile.VerifyIdentity(coretypes.PassphraseVerification{Passphrase: "multipass"}), coretypes.ErrorTooManyAttempts)
+		})
+
+		It("Fails with ErrorOperationCanceled when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := aliceSession.EncryptContext(ctx, []byte("data"), nil)
+			expectErrorCode(err, coretypes.ErrorOperationCanceled)
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+			Expect(errors.Is(err, coretypes.ErrOperationCanceled)).To(BeTrue())
+		})
+	})
+
+	Context("Encryption", func() {
+		var bobSession *coretest.Tanker
+
+		BeforeEach(func() {
+			bobSession = startDevice(server, bob, "bob-laptop")
+		})
+
+		It("Encrypts and decrypts", func() {
+			encrypted, err := aliceSession.Encrypt([]byte("data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encrypted).To(HaveLen(coretest.EncryptedSize(4)))
+			decrypted, err := aliceSession.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("data")))
+		})
+
+		It("Only lets the recipients decrypt", func() {
+			encrypted, err := aliceSession.Encrypt([]byte("data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = bobSession.Decrypt(encrypted)
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+
+			options := coretypes.NewEncryptionOptions()
+			options.ShareWithUsers = []string{bob.PublicIdentity}
+			options.ShareWithSelf = false
+			encrypted, err = aliceSession.Encrypt([]byte("data"), &options)
+			Expect(err).ToNot(HaveOccurred())
+			decrypted, err := bobSession.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("data")))
+			_, err = aliceSession.Decrypt(encrypted)
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+		})
+
+		It("Detects tampered data", func() {
+			encrypted, err := aliceSession.Encrypt([]byte("data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			encrypted[len(encrypted)-1] ^= 1
+			_, err = aliceSession.Decrypt(encrypted)
+			expectErrorCode(err, coretypes.ErrorDecryptionFailed)
+		})
+
+		It("Encrypts and decrypts into buffers", func() {
+			buffer := make([]byte, 0, 128)
+			encrypted, err := aliceSession.EncryptTo(buffer, []byte("data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			decrypted, err := aliceSession.DecryptTo([]byte("clear:"), encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("clear:data")))
+			decrypted, err = aliceSession.DecryptTo(decrypted, encrypted[:10])
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+			Expect(decrypted).To(Equal([]byte("clear:data")))
+		})
+
+		It("Decrypts to secret buffers", func() {
+			encrypted, err := aliceSession.Encrypt([]byte("secret data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			secret, err := aliceSession.DecryptSecret(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(secret.Bytes()).To(Equal([]byte("secret data")))
+			secret.Destroy()
+			Expect(secret.Bytes()).To(BeNil())
+		})
+
+		It("Gets the resource ID from a reader", func() {
+			encrypted, err := aliceSession.Encrypt([]byte("some data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			expected, err := aliceSession.GetResourceId(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			source := bytes.NewReader(encrypted)
+			resourceID, replay, err := aliceSession.GetResourceIDFromReader(source)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resourceID).To(Equal(expected))
+			Expect(source.Len()).To(BeNumerically(">", 0))
+			Expect(ioutil.ReadAll(replay)).To(Equal(encrypted))
+
+			_, _, err = aliceSession.GetResourceIDFromReader(bytes.NewReader(encrypted[:10]))
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+		})
+
+		It("Encrypts then shares", func() {
+			encrypted, err := aliceSession.Encrypt([]byte("data"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			resourceID, err := aliceSession.GetResourceId(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			options := coretypes.NewSharingOptions()
+			options.ShareWithUsers = []string{bob.PublicIdentity}
+			Expect(aliceSession.Share([]string{*resourceID}, options)).To(Succeed())
+			decrypted, err := bobSession.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("data")))
+		})
+
+		It("Refuses to share with unknown users", func() {
+			unknown := createUser(server, "unknown")
+			options := coretypes.NewEncryptionOptions()
+			options.ShareWithUsers = []string{unknown.PublicIdentity}
+			_, err := aliceSession.Encrypt([]byte("data"), &options)
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+		})
+
+		It("Shares with groups", func() {
+			groupID, err := aliceSession.CreateGroup([]string{alice.PublicIdentity})
+			Expect(err).ToNot(HaveOccurred())
+			options := coretypes.NewEncryptionOptions()
+			options.ShareWithGroups = []string{*groupID}
+			encrypted, err := aliceSession.Encrypt([]byte("data"), &options)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = bobSession.Decrypt(encrypted)
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+
+			Expect(aliceSession.UpdateGroupMembers(*groupID, []string{bob.PublicIdentity})).To(Succeed())
+			decrypted, err := bobSession.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("data")))
+		})
+
+		It("Adds and removes group members at once", func() {
+			groupID, err := aliceSession.CreateGroup([]string{alice.PublicIdentity, bob.PublicIdentity})
+			Expect(err).ToNot(HaveOccurred())
+			carol := createUser(server, "carol")
+			carolSession := startDevice(server, carol, "carol-laptop")
+
+			err = aliceSession.EditGroupMembers(*groupID, coretypes.GroupMembersUpdate{
+				UsersToAdd:    []string{carol.PublicIdentity},
+				UsersToRemove: []string{carol.PublicIdentity},
+			})
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+			Expect(aliceSession.EditGroupMembers(*groupID, coretypes.GroupMembersUpdate{
+				UsersToAdd:    []string{carol.PublicIdentity},
+				UsersToRemove: []string{bob.PublicIdentity},
+			})).To(Succeed())
+
+			options := coretypes.NewEncryptionOptions()
+			options.ShareWithGroups = []string{*groupID}
+			encrypted, err := aliceSession.Encrypt([]byte("data"), &options)
+			Expect(err).ToNot(HaveOccurred())
+			decrypted, err := carolSession.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("data")))
+			_, err = bobSession.Decrypt(encrypted)
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+			err = aliceSession.EditGroupMembers(*groupID, coretypes.GroupMembersUpdate{UsersToRemove: []string{bob.PublicIdentity}})
+			expectErrorCode(err, coretypes.ErrorInvalidArgument)
+		})
+
+		It("Reports the size of a group too big", func() {
+			// The simulated server accepts 1000 members
+			identities := make([]string, 1001)
+			for i := range identities {
+				identities[i] = alice.PublicIdentity
+			}
+			_, err := aliceSession.CreateGroup(identities)
+			var tooBig *coretypes.GroupTooBigError
+			Expect(errors.As(err, &tooBig)).To(BeTrue())
+			Expect(errors.Is(err, coretypes.ErrGroupTooBig)).To(BeTrue())
+			Expect(tooBig.Size).To(Equal(1001))
+			Expect(tooBig.MaxSize).To(Equal(1000))
+
+			groupID, err := aliceSession.CreateGroup([]string{alice.PublicIdentity})
+			Expect(err).ToNot(HaveOccurred())
+			err = aliceSession.EditGroupMembers(*groupID, coretypes.GroupMembersUpdate{UsersToRemove: identities})
+			Expect(errors.As(err, &tooBig)).To(BeTrue())
+			Expect(tooBig.Size).To(Equal(1001))
+		})
+
+		It("Shares with provisional identities", func() {
+			provisional, publicProvisional := server.CreateProvisionalIdentity("bob@tanker.io")
+			options := coretypes.NewEncryptionOptions()
+			options.ShareWithUsers = []string{publicProvisional}
+			encrypted, err := aliceSession.Encrypt([]byte("data"), &options)
+			Expect(err).ToNot(HaveOccurred())
+
+			result, err := bobSession.AttachProvisionalIdentity(provisional)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Status).To(Equal(coretypes.StatusIdentityVerificationNeeded))
+			Expect(*result.Method.Email).To(Equal("bob@tanker.io"))
+			code := server.GetVerificationCode("bob@tanker.io")
+			Expect(bobSession.VerifyProvisionalIdentity(coretypes.EmailVerification{Email: "bob@tanker.io", VerificationCode: code})).To(Succeed())
+
+			decrypted, err := bobSession.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("data")))
+			result, err = bobSession.AttachProvisionalIdentity(provisional)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Status).To(Equal(coretypes.StatusReady))
+		})
+
+		It("Encrypts with an encryption session", func() {
+			options := coretypes.NewEncryptionOptions()
+			options.ShareWithUsers = []string{bob.PublicIdentity}
+			session, err := aliceSession.CreateEncryptionSession(&options)
+			Expect(err).ToNot(HaveOccurred())
+			encrypted1, err := session.Encrypt([]byte("one"))
+			Expect(err).ToNot(HaveOccurred())
+			encrypted2, err := session.Encrypt([]byte("two"))
+			Expect(err).ToNot(HaveOccurred())
+			resourceID, err := aliceSession.GetResourceId(encrypted2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*resourceID).To(Equal(session.GetResourceId()))
+			decrypted, err := bobSession.Decrypt(encrypted1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("one")))
+		})
+
+		It("Encrypts and decrypts with streams", func() {
+			options := coretypes.NewEncryptionOptions()
+			options.ShareWithUsers = []string{bob.PublicIdentity}
+			encryptedStream, err := aliceSession.StreamEncrypt(bytes.NewReader([]byte("streamed data")), &options)
+			Expect(err).ToNot(HaveOccurred())
+			decryptedStream, err := bobSession.StreamDecrypt(encryptedStream)
+			Expect(err).ToNot(HaveOccurred())
+			decrypted, err := ioutil.ReadAll(decryptedStream)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal([]byte("streamed data")))
+			Expect(encryptedStream.Stats().BytesRead).To(Equal(int64(len("streamed data"))))
+			Expect(decryptedStream.Stats().BytesProduced).To(Equal(int64(len("streamed data"))))
+			Expect(decryptedStream.Close()).To(Succeed())
+			_, err = decryptedStream.Read(make([]byte, 1))
+			expectErrorCode(err, coretypes.ErrorPreconditionFailed)
+		})
+
+		It("Decrypts at random offsets", func() {
+			clearData := []byte("0123456789abcdefghij")
+			encrypted, err := aliceSession.Encrypt(clearData, nil)
+			Expect(err).ToNot(HaveOccurred())
+			stream, err := aliceSession.NewDecryptReaderAt(bytes.NewReader(encrypted), int64(len(encrypted)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stream.Size()).To(Equal(int64(len(clearData))))
+
+			request := httptest.NewRequest("GET", "/data", nil)
+			request.Header.Set("Range", "bytes=10-14")
+			recorder := httptest.NewRecorder()
+			http.ServeContent(recorder, request, "data", time.Now(), stream)
+			Expect(recorder.Code).To(Equal(http.StatusPartialContent))
+			Expect(recorder.Body.String()).To(Equal("abcde"))
+
+			stream.Destroy()
+			_, err = stream.ReadAt(make([]byte, 1), 0)
+			expectErrorCode(err, coretypes.ErrorPreconditionFailed)
+		})
+
+		It("Encrypts and decrypts with writers", func() {
+			var encrypted, decrypted bytes.Buffer
+			encryptor, err := aliceSession.NewEncryptWriter(&encrypted, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = encryptor.Write([]byte("written data"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encryptor.Close()).To(Succeed())
+			resourceID, err := encryptor.GetResourceID()
+			Expect(err).ToNot(HaveOccurred())
+
+			decryptor, err := aliceSession.NewDecryptWriter(&decrypted)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = decryptor.GetResourceID()
+			expectErrorCode(err, coretypes.ErrorPreconditionFailed)
+			_, err = io.Copy(decryptor, &encrypted)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decryptor.GetResourceID()).To(Equal(resourceID))
+			Expect(decryptor.Close()).To(Succeed())
+			Expect(decrypted.String()).To(Equal("written data"))
+			_, err = decryptor.Write([]byte("more"))
+			expectErrorCode(err, coretypes.ErrorPreconditionFailed)
+		})
+	})
+
+	Context("Verification methods", func() {
+		It("Registers and verifies with a verification key", func() {
+			carol := createUser(server, "carol")
+			laptop := server.NewTanker(coretypes.TankerOptions{WritablePath: "carol-laptop"})
+			_, err := laptop.Start(carol.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			key, err := laptop.GenerateVerificationKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(laptop.RegisterIdentity(coretypes.KeyVerification{Key: *key})).To(Succeed())
+			expectErrorCode(laptop.SetVerificationMethod(coretypes.PassphraseVerification{Passphrase: "pass"}), coretypes.ErrorPreconditionFailed)
+
+			mobile := server.NewTanker(coretypes.TankerOptions{WritablePath: "carol-mobile"})
+			_, err = mobile.Start(carol.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mobile.VerifyIdentity(coretypes.KeyVerification{Key: *key})).To(Succeed())
+		})
+
+		It("Lists the verification methods", func() {
+			code := server.GetVerificationCode("alice@tanker.io")
+			Expect(aliceSession.SetVerificationMethod(coretypes.EmailVerification{Email: "alice@tanker.io", VerificationCode: code})).To(Succeed())
+			methods, err := aliceSession.GetVerificationMethods()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(methods).To(HaveLen(2))
+			Expect(methods[0].Type).To(Equal(coretypes.VerificationMethodEmail))
+			Expect(*methods[0].Email).To(Equal("alice@tanker.io"))
+			Expect(methods[1].Type).To(Equal(coretypes.VerificationMethodPassphrase))
+		})
+
+		It("Accepts pointer verifications and rejects nil ones", func() {
+			mobile := server.NewTanker(coretypes.TankerOptions{WritablePath: "alice-mobile"})
+			_, err := mobile.Start(alice.Identity)
+			Expect(err).ToNot(HaveOccurred())
+			var nilVerification *coretypes.PassphraseVerification
+			expectErrorCode(mobile.VerifyIdentity(nilVerification), coretypes.ErrorInvalidArgument)
+			expectErrorCode(mobile.VerifyIdentity(nil), coretypes.ErrorInvalidArgument)
+			Expect(mobile.VerifyIdentity(&coretypes.PassphraseVerification{Passphrase: "multipass"})).To(Succeed())
+		})
+	})
+
+	Context("Devices", func() {
+		It("Revokes a device", func() {
+			mobile := startDevice(server, alice, "alice-mobile")
+			revoked := make(chan bool, 1)
+			_, err := mobile.RegisterEventHandler(coretypes.EventDeviceRevoked, func() { revoked <- true })
+			Expect(err).ToNot(HaveOccurred())
+			mobileID, err := mobile.GetDeviceID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(aliceSession.RevokeDevice(*mobileID)).To(Succeed())
+
+			_, err = mobile.Encrypt([]byte("data"), nil)
+			expectErrorCode(err, coretypes.ErrorDeviceRevoked)
+			Expect(revoked).To(Receive())
+			Expect(mobile.GetStatus()).To(Equal(coretypes.StatusStopped))
+
+			devices, err := aliceSession.GetDeviceList()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(devices).To(ContainElement(coretypes.DeviceDescription{DeviceID: *mobileID, IsRevoked: true}))
+		})
+	})
+})
@@ -0,0 +1,340 @@
+package coretest
+
+import (
+	"context"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// normalizeVerification dereferences the pointer forms of the verifications,
+// and rejects nil ones like core does. The secrets held by SecretBuffers are
+// copied to the string fields.
+func normalizeVerification(verification coretypes.Verification) (coretypes.Verification, error) {
+	switch v := verification.(type) {
+	case coretypes.PassphraseVerification:
+		if v.SecretPassphrase != nil {
+			if v.SecretPassphrase.Bytes() == nil {
+				return nil, newError(coretypes.ErrorInvalidArgument, "the secret buffer has been destroyed")
+			}
+			v.Passphrase, v.SecretPassphrase = string(v.SecretPassphrase.Bytes()), nil
+		}
+		return v, nil
+	case coretypes.KeyVerification:
+		if v.SecretKey != nil {
+			if v.SecretKey.Bytes() == nil {
+				return nil, newError(coretypes.ErrorInvalidArgument, "the secret buffer has been destroyed")
+			}
+			v.Key, v.SecretKey = string(v.SecretKey.Bytes()), nil
+		}
+		return v, nil
+	case *coretypes.EmailVerification:
+		if v != nil {
+			return *v, nil
+		}
+	case *coretypes.PassphraseVerification:
+		if v != nil {
+			return normalizeVerification(*v)
+		}
+	case *coretypes.KeyVerification:
+		if v != nil {
+			return normalizeVerification(*v)
+		}
+	case *coretypes.OidcVerification:
+		if v != nil {
+			return *v, nil
+		}
+	case nil:
+	default:
+		return verification, nil
+	}
+	return nil, newError(coretypes.ErrorInvalidArgument, "verification must not be nil")
+}
+
+// checkEmailCode must be called with the server mutex held.
+func (t *Tanker) checkEmailCode(verification coretypes.EmailVerification) error {
+	code, ok := t.server.verificationCodes[verification.Email]
+	if !ok || code != verification.VerificationCode {
+		return newError(coretypes.ErrorInvalidVerification, "invalid verification code")
+	}
+	return nil
+}
+
+// setVerification registers the verification as a verification method of the current user.
+// Must be called with the server mutex held.
+func (t *Tanker) setVerification(verification coretypes.Verification) error {
+	u := t.user
+	switch v := verification.(type) {
+	case coretypes.EmailVerification:
+		if err := t.checkEmailCode(v); err != nil {
+			return err
+		}
+		email := v.Email
+		u.email = &email
+	case coretypes.PassphraseVerification:
+		if v.Passphrase == "" {
+			return newError(coretypes.ErrorInvalidArgument, "passphrase must not be empty")
+		}
+		passphrase := v.Passphrase
+		u.passphrase = &passphrase
+	case coretypes.OidcVerification:
+		if v.OidcIdToken == "" {
+			return newError(coretypes.ErrorInvalidArgument, "OIDC ID token must not be empty")
+		}
+		token := v.OidcIdToken
+		u.oidcIDToken = &token
+	case coretypes.KeyVerification:
+		if t.verificationKey == nil || *t.verificationKey != v.Key {
+			return newError(coretypes.ErrorInvalidVerification, "invalid verification key")
+		}
+		key := v.Key
+		u.verificationKey = &key
+	default:
+		return newError(coretypes.ErrorInvalidArgument, "unsupported verification type %T", verification)
+	}
+	return nil
+}
+
+// checkVerification checks the verification against the verification methods of the current user.
+// Must be called with the server mutex held.
+func (t *Tanker) checkVerification(verification coretypes.Verification) error {
+	u := t.user
+	if u.failedVerification >= maxVerificationAttempts {
+		return newError(coretypes.ErrorTooManyAttempts, "too many failed verification attempts")
+	}
+	var registered *string
+	var given string
+	switch v := verification.(type) {
+	case coretypes.EmailVerification:
+		if u.email == nil || *u.email != v.Email {
+			return newError(coretypes.ErrorPreconditionFailed, "no email verification method registered for %s", v.Email)
+		}
+		if err := t.checkEmailCode(v); err != nil {
+			u.failedVerification++
+			return err
+		}
+		u.failedVerification = 0
+		return nil
+	case coretypes.PassphraseVerification:
+		registered, given = u.passphrase, v.Passphrase
+	case coretypes.KeyVerification:
+		registered, given = u.verificationKey, v.Key
+	case coretypes.OidcVerification:
+		registered, given = u.oidcIDToken, v.OidcIdToken
+	default:
+		return newError(coretypes.ErrorInvalidArgument, "unsupported verification type %T", verification)
+	}
+	if registered == nil {
+		return newError(coretypes.ErrorPreconditionFailed, "no such verification method registered")
+	}
+	if *registered != given {
+		u.failedVerification++
+		return newError(coretypes.ErrorInvalidVerification, "invalid verification")
+	}
+	u.failedVerification = 0
+	return nil
+}
+
+// RegisterIdentity registers the user with a first verification method, the status must be
+// StatusIdentityRegistrationNeeded. EmailVerification codes are obtained with Server.GetVerificationCode(),
+// and KeyVerification keys with GenerateVerificationKey().
+func (t *Tanker) RegisterIdentity(verification coretypes.Verification) error {
+	return t.RegisterIdentityContext(context.Background(), verification)
+}
+
+// RegisterIdentityContext is like RegisterIdentity but fails if ctx is done.
+func (t *Tanker) RegisterIdentityContext(ctx context.Context, verification coretypes.Verification) error {
+	verification, err := normalizeVerification(verification)
+	if err != nil {
+		return err
+	}
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusIdentityRegistrationNeeded); err != nil {
+		return err
+	}
+	if _, ok := t.server.users[t.identity.Value]; ok {
+		return newError(coretypes.ErrorConflict, "user %s has already been registered", t.identity.Value)
+	}
+	t.user = &user{id: t.identity.Value}
+	if err = t.setVerification(verification); err != nil {
+		t.user = nil
+		return err
+	}
+	t.server.users[t.user.id] = t.user
+	t.openDevice()
+	return nil
+}
+
+// VerifyIdentity verifies the user identity and creates a new device, the status must be
+// StatusIdentityVerificationNeeded.
+func (t *Tanker) VerifyIdentity(verification coretypes.Verification) error {
+	return t.VerifyIdentityContext(context.Background(), verification)
+}
+
+// VerifyIdentityContext is like VerifyIdentity but fails if ctx is done.
+func (t *Tanker) VerifyIdentityContext(ctx context.Context, verification coretypes.Verification) error {
+	verification, err := normalizeVerification(verification)
+	if err != nil {
+		return err
+	}
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusIdentityVerificationNeeded); err != nil {
+		return err
+	}
+	if err = t.checkVerification(verification); err != nil {
+		return err
+	}
+	t.openDevice()
+	return nil
+}
+
+// SetVerificationMethod adds or replaces a verification method of the current user.
+func (t *Tanker) SetVerificationMethod(verification coretypes.Verification) error {
+	return t.SetVerificationMethodContext(context.Background(), verification)
+}
+
+// SetVerificationMethodContext is like SetVerificationMethod but fails if ctx is done.
+func (t *Tanker) SetVerificationMethodContext(ctx context.Context, verification coretypes.Verification) error {
+	verification, err := normalizeVerification(verification)
+	if err != nil {
+		return err
+	}
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return err
+	}
+	if t.user.verificationKey != nil {
+		return newError(coretypes.ErrorPreconditionFailed, "cannot set a verification method when using a verification key")
+	}
+	if _, ok := verification.(coretypes.KeyVerification); ok {
+		return newError(coretypes.ErrorInvalidArgument, "cannot set a verification key as a verification method")
+	}
+	return t.setVerification(verification)
+}
+
+// GetVerificationMethods returns the verification methods of the current user.
+func (t *Tanker) GetVerificationMethods() ([]coretypes.VerificationMethod, error) {
+	return t.GetVerificationMethodsContext(context.Background())
+}
+
+// GetVerificationMethodsContext is like GetVerificationMethods but fails if ctx is done.
+func (t *Tanker) GetVerificationMethodsContext(ctx context.Context) ([]coretypes.VerificationMethod, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	return t.user.verificationMethods(), nil
+}
+
+// GenerateVerificationKey generates a verification key, to be used in a KeyVerification
+// passed to RegisterIdentity(). The status must be StatusIdentityRegistrationNeeded.
+func (t *Tanker) GenerateVerificationKey() (*string, error) {
+	return t.GenerateVerificationKeyContext(context.Background())
+}
+
+// GenerateVerificationKeyContext is like GenerateVerificationKey but fails if ctx is done.
+func (t *Tanker) GenerateVerificationKeyContext(ctx context.Context) (*string, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusIdentityRegistrationNeeded); err != nil {
+		return nil, err
+	}
+	key := randomID(keySize)
+	t.verificationKey = &key
+	return &key, nil
+}
+
+// AttachProvisionalIdentity attaches a provisional identity, created with
+// Server.CreateProvisionalIdentity(), to the current user.
+func (t *Tanker) AttachProvisionalIdentity(provisionalIdentity string) (*coretypes.AttachResult, error) {
+	return t.AttachProvisionalIdentityContext(context.Background(), provisionalIdentity)
+}
+
+// AttachProvisionalIdentityContext is like AttachProvisionalIdentity but fails if ctx is done.
+func (t *Tanker) AttachProvisionalIdentityContext(ctx context.Context, provisionalIdentity string) (*coretypes.AttachResult, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	id, err := t.server.checkPrivateIdentity(provisionalIdentity, targetEmail)
+	if err != nil {
+		return nil, err
+	}
+	p := t.server.provisionals[id.Value]
+	switch {
+	case p.claimedBy == t.user:
+		return &coretypes.AttachResult{Status: coretypes.StatusReady}, nil
+	case p.claimedBy != nil:
+		return nil, newError(coretypes.ErrorPreconditionFailed, "this provisional identity has already been claimed by another user")
+	case t.user.email != nil && *t.user.email == p.email:
+		// The email address has already been verified by this user
+		p.claimedBy = t.user
+		return &coretypes.AttachResult{Status: coretypes.StatusReady}, nil
+	}
+	t.attached = p
+	email := p.email
+	return &coretypes.AttachResult{
+		Status: coretypes.StatusIdentityVerificationNeeded,
+		Method: &coretypes.VerificationMethod{Type: coretypes.VerificationMethodEmail, Email: &email},
+	}, nil
+}
+
+// VerifyProvisionalIdentity verifies the provisional identity previously attached, the current user
+// then gets access to every resource shared with it and joins every group it is a member of.
+func (t *Tanker) VerifyProvisionalIdentity(verification coretypes.Verification) error {
+	return t.VerifyProvisionalIdentityContext(context.Background(), verification)
+}
+
+// VerifyProvisionalIdentityContext is like VerifyProvisionalIdentity but fails if ctx is done.
+func (t *Tanker) VerifyProvisionalIdentityContext(ctx context.Context, verification coretypes.Verification) error {
+	verification, err := normalizeVerification(verification)
+	if err != nil {
+		return err
+	}
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return err
+	}
+	if t.attached == nil {
+		return newError(coretypes.ErrorPreconditionFailed, "no provisional identity attached")
+	}
+	emailVerification, ok := verification.(coretypes.EmailVerification)
+	if !ok {
+		return newError(coretypes.ErrorInvalidArgument, "unsupported verification type %T for a provisional identity", verification)
+	}
+	if emailVerification.Email != t.attached.email {
+		return newError(coretypes.ErrorInvalidArgument, "verification email does not match the provisional identity")
+	}
+	if err = t.checkEmailCode(emailVerification); err != nil {
+		return err
+	}
+	t.attached.claimedBy = t.user
+	t.attached = nil
+	return nil
+}
@@ -0,0 +1,132 @@
+package coretest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+// StreamWriter simulates an encrypting or decrypting writer. The data written
+// is buffered in memory until Close(), which encrypts or decrypts it at once
+// and writes the result to the destination writer.
+//
+// Unlike core, GetResourceID() does not block on decryption writers: it fails
+// with ErrorPreconditionFailed until the header has been written.
+type StreamWriter struct {
+	dst        io.Writer
+	resourceID string
+	finish     func(data []byte) ([]byte, error)
+
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	closed bool
+	err    error
+}
+
+var _ coretypes.StreamWriter = (*StreamWriter)(nil)
+
+// Write buffers data until Close().
+func (w *StreamWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return 0, newError(coretypes.ErrorPreconditionFailed, "the stream has been closed")
+	}
+	return w.buffer.Write(data)
+}
+
+// Close encrypts or decrypts the buffered data and writes it to the destination writer.
+func (w *StreamWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	output, err := w.finish(w.buffer.Bytes())
+	if err == nil {
+		_, err = w.dst.Write(output)
+	}
+	w.err = err
+	return err
+}
+
+// GetResourceID returns the resource ID of the stream.
+func (w *StreamWriter) GetResourceID() (*string, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.resourceID != "" {
+		resourceID := w.resourceID
+		return &resourceID, nil
+	}
+	if w.buffer.Len() < headerSize {
+		return nil, newError(coretypes.ErrorPreconditionFailed, "the header has not been written yet")
+	}
+	resourceID, err := parseResourceID(w.buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &resourceID, nil
+}
+
+// NewEncryptWriter creates a writer encrypting the data written to it to dst.
+func (t *Tanker) NewEncryptWriter(dst io.Writer, options *coretypes.EncryptionOptions) (coretypes.StreamWriter, error) {
+	return t.NewEncryptWriterContext(context.Background(), dst, options)
+}
+
+// NewEncryptWriterContext is like NewEncryptWriter but fails if ctx is done.
+func (t *Tanker) NewEncryptWriterContext(ctx context.Context, dst io.Writer, options *coretypes.EncryptionOptions) (coretypes.StreamWriter, error) {
+	unlock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = t.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	resourceID, rawID, key, err := t.newSharedResource(options)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamWriter{dst: dst, resourceID: resourceID, finish: func(data []byte) ([]byte, error) {
+		return seal(key, rawID, data), nil
+	}}, nil
+}
+
+// NewEncryptWriter creates a writer encrypting the data written to it to dst with the session key.
+func (s *EncryptionSession) NewEncryptWriter(dst io.Writer) (coretypes.StreamWriter, error) {
+	return s.NewEncryptWriterContext(context.Background(), dst)
+}
+
+// NewEncryptWriterContext is like NewEncryptWriter but fails if ctx is done.
+func (s *EncryptionSession) NewEncryptWriterContext(ctx context.Context, dst io.Writer) (coretypes.StreamWriter, error) {
+	unlock, err := s.tanker.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = s.tanker.checkStatus(coretypes.StatusReady); err != nil {
+		return nil, err
+	}
+	return &StreamWriter{dst: dst, resourceID: s.resourceID, finish: func(data []byte) ([]byte, error) {
+		return seal(s.key, s.rawID, data), nil
+	}}, nil
+}
+
+// NewDecryptWriter creates a writer decrypting the data written to it to dst.
+func (t *Tanker) NewDecryptWriter(dst io.Writer) (coretypes.StreamWriter, error) {
+	return t.NewDecryptWriterContext(context.Background(), dst)
+}
+
+// NewDecryptWriterContext is like NewDecryptWriter but fails if ctx is done.
+func (t *Tanker) NewDecryptWriterContext(ctx context.Context, dst io.Writer) (coretypes.StreamWriter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newCanceledError(err)
+	}
+	return &StreamWriter{dst: dst, finish: func(data []byte) ([]byte, error) {
+		return t.DecryptContext(ctx, data)
+	}}, nil
+}
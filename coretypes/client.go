@@ -0,0 +1,121 @@
+package coretypes
+
+import (
+	"context"
+	"io"
+)
+
+// Client is the set of operations available on a Tanker instance.
+// It is satisfied by *core.Tanker, code depending on it rather than on *core.Tanker
+// can be unit tested with the fakes of the coremock and coretest packages.
+type Client interface {
+	// Session
+	Destroy() error
+	Start(identity string) (Status, error)
+	StartContext(ctx context.Context, identity string) (Status, error)
+	Stop() error
+	StopContext(ctx context.Context) error
+	GetStatus() Status
+	RegisterEventHandler(event EventType, handler EventHandler) (func(), error)
+	Events() <-chan EventType
+
+	// Encryption
+	Encrypt(clearData []byte, options *EncryptionOptions) ([]byte, error)
+	EncryptContext(ctx context.Context, clearData []byte, options *EncryptionOptions) ([]byte, error)
+	Decrypt(encryptedData []byte) ([]byte, error)
+	DecryptContext(ctx context.Context, encryptedData []byte) ([]byte, error)
+	EncryptTo(dst []byte, clearData []byte, options *EncryptionOptions) ([]byte, error)
+	DecryptTo(dst []byte, encryptedData []byte) ([]byte, error)
+	DecryptSecret(encryptedData []byte) (*SecretBuffer, error)
+	GetResourceId(encryptedData []byte) (*string, error)
+	GetResourceIDFromReader(r io.Reader) (*string, io.Reader, error)
+	CreateEncryptionSession(encryptionOptions *EncryptionOptions) (EncryptionSessionClient, error)
+	CreateEncryptionSessionContext(ctx context.Context, encryptionOptions *EncryptionOptions) (EncryptionSessionClient, error)
+
+	// Streams
+	StreamEncrypt(reader io.Reader, options *EncryptionOptions) (Stream, error)
+	StreamEncryptContext(ctx context.Context, reader io.Reader, options *EncryptionOptions) (Stream, error)
+	StreamDecrypt(reader io.Reader) (Stream, error)
+	StreamDecryptContext(ctx context.Context, reader io.Reader) (Stream, error)
+	NewEncryptWriter(dst io.Writer, options *EncryptionOptions) (StreamWriter, error)
+	NewEncryptWriterContext(ctx context.Context, dst io.Writer, options *EncryptionOptions) (StreamWriter, error)
+	NewDecryptWriter(dst io.Writer) (StreamWriter, error)
+	NewDecryptWriterContext(ctx context.Context, dst io.Writer) (StreamWriter, error)
+	NewDecryptReaderAt(src io.ReaderAt, size int64) (SeekableStream, error)
+	NewDecryptReaderAtContext(ctx context.Context, src io.ReaderAt, size int64) (SeekableStream, error)
+
+	// Sharing
+	Share(resourceIDs []string, sharingOptions SharingOptions) error
+	ShareContext(ctx context.Context, resourceIDs []string, sharingOptions SharingOptions) error
+
+	// Groups
+	CreateGroup(publicIdentities []string) (*string, error)
+	CreateGroupContext(ctx context.Context, publicIdentities []string) (*string, error)
+	UpdateGroupMembers(groupID string, publicIdentitiesToAdd []string) error
+	UpdateGroupMembersContext(ctx context.Context, groupID string, publicIdentitiesToAdd []string) error
+	EditGroupMembers(groupID string, update GroupMembersUpdate) error
+	EditGroupMembersContext(ctx context.Context, groupID string, update GroupMembersUpdate) error
+
+	// Verification
+	RegisterIdentity(verification Verification) error
+	RegisterIdentityContext(ctx context.Context, verification Verification) error
+	VerifyIdentity(verification Verification) error
+	VerifyIdentityContext(ctx context.Context, verification Verification) error
+	SetVerificationMethod(verification Verification) error
+	SetVerificationMethodContext(ctx context.Context, verification Verification) error
+	GetVerificationMethods() ([]VerificationMethod, error)
+	GetVerificationMethodsContext(ctx context.Context) ([]VerificationMethod, error)
+	AttachProvisionalIdentity(provisionalIdentity string) (*AttachResult, error)
+	AttachProvisionalIdentityContext(ctx context.Context, provisionalIdentity string) (*AttachResult, error)
+	VerifyProvisionalIdentity(verification Verification) error
+	VerifyProvisionalIdentityContext(ctx context.Context, verification Verification) error
+	GenerateVerificationKey() (*string, error)
+	GenerateVerificationKeyContext(ctx context.Context) (*string, error)
+
+	// Devices
+	GetDeviceID() (*string, error)
+	GetDeviceIDContext(ctx context.Context) (*string, error)
+	GetDeviceList() ([]DeviceDescription, error)
+	GetDeviceListContext(ctx context.Context) ([]DeviceDescription, error)
+	RevokeDevice(deviceID string) error
+	RevokeDeviceContext(ctx context.Context, deviceID string) error
+}
+
+// EncryptionSessionClient is the set of operations available on an encryption session.
+// It is satisfied by *core.EncryptionSession.
+type EncryptionSessionClient interface {
+	Destroy()
+	GetResourceId() string
+	Encrypt(clearData []byte) ([]byte, error)
+	EncryptContext(ctx context.Context, clearData []byte) ([]byte, error)
+	StreamEncrypt(reader io.Reader) (Stream, error)
+	StreamEncryptContext(ctx context.Context, reader io.Reader) (Stream, error)
+	NewEncryptWriter(dst io.Writer) (StreamWriter, error)
+	NewEncryptWriterContext(ctx context.Context, dst io.Writer) (StreamWriter, error)
+}
+
+// Stream is the set of operations available on an encryption or decryption stream.
+// It is satisfied by *core.OutputStream.
+type Stream interface {
+	io.ReadCloser
+	Destroy()
+	GetResourceID() (*string, error)
+	Stats() StreamStats
+}
+
+// StreamWriter is the set of operations available on an encrypting or decrypting writer.
+// It is satisfied by *core.InputStream.
+type StreamWriter interface {
+	io.WriteCloser
+	GetResourceID() (*string, error)
+}
+
+// SeekableStream is the set of operations available on a random-access decryption stream.
+// It is satisfied by *core.RandomAccessStream.
+type SeekableStream interface {
+	io.ReadSeeker
+	io.ReaderAt
+	Size() int64
+	Destroy()
+	GetResourceID() (*string, error)
+}
@@ -0,0 +1,13 @@
+package coretypes_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCoreTypes(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Core Types Test Suite")
+}
@@ -0,0 +1,245 @@
+package coretypes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode represents a Tanker error code.
+//
+// ErrorCode implements error so that each code can be compared against with
+// errors.Is(), see the Err* sentinel errors.
+type ErrorCode uint32
+
+const (
+	ErrorInvalidArgument ErrorCode = iota + 1
+	ErrorInternalError
+	ErrorNetworkError
+	ErrorPreconditionFailed
+	ErrorOperationCanceled
+
+	ErrorDecryptionFailed
+
+	ErrorGroupTooBig
+
+	ErrorInvalidVerification
+	ErrorTooManyAttempts
+	ErrorExpiredVerification
+	ErrorIoError
+	ErrorDeviceRevoked
+
+	ErrorConflict
+	ErrorUpgradeRequired
+)
+
+// Sentinel errors, one per ErrorCode. Every error returned by Tanker
+// functions matches the sentinel of its code:
+//
+//	if errors.Is(err, core.ErrDecryptionFailed) {
+//		...
+//	}
+var (
+	ErrInvalidArgument     error = ErrorInvalidArgument
+	ErrInternalError       error = ErrorInternalError
+	ErrNetworkError        error = ErrorNetworkError
+	ErrPreconditionFailed  error = ErrorPreconditionFailed
+	ErrOperationCanceled   error = ErrorOperationCanceled
+	ErrDecryptionFailed    error = ErrorDecryptionFailed
+	ErrGroupTooBig         error = ErrorGroupTooBig
+	ErrInvalidVerification error = ErrorInvalidVerification
+	ErrTooManyAttempts     error = ErrorTooManyAttempts
+	ErrExpiredVerification error = ErrorExpiredVerification
+	ErrIoError             error = ErrorIoError
+	ErrDeviceRevoked       error = ErrorDeviceRevoked
+	ErrConflict            error = ErrorConflict
+	ErrUpgradeRequired     error = ErrorUpgradeRequired
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	ErrorInvalidArgument:     "InvalidArgument",
+	ErrorInternalError:       "InternalError",
+	ErrorNetworkError:        "NetworkError",
+	ErrorPreconditionFailed:  "PreconditionFailed",
+	ErrorOperationCanceled:   "OperationCanceled",
+	ErrorDecryptionFailed:    "DecryptionFailed",
+	ErrorGroupTooBig:         "GroupTooBig",
+	ErrorInvalidVerification: "InvalidVerification",
+	ErrorTooManyAttempts:     "TooManyAttempts",
+	ErrorExpiredVerification: "ExpiredVerification",
+	ErrorIoError:             "IoError",
+	ErrorDeviceRevoked:       "DeviceRevoked",
+	ErrorConflict:            "Conflict",
+	ErrorUpgradeRequired:     "UpgradeRequired",
+}
+
+// String returns the name of the error code, e.g. "DecryptionFailed".
+func (c ErrorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorCode(%d)", uint32(c))
+}
+
+// Error makes ErrorCode usable as a sentinel error.
+func (c ErrorCode) Error() string {
+	return "tanker: " + c.String()
+}
+
+// Retryable reports whether an operation failing with this code may succeed
+// if it is attempted again: network errors and conflicts are transient, every
+// other error is permanent.
+func (c ErrorCode) Retryable() bool {
+	return c == ErrorNetworkError || c == ErrorConflict
+}
+
+// Error is the Tanker error interface. Cast the error returned by
+// Tanker functions to this interface to get more informations, or use
+// errors.As() when the error may have been wrapped.
+type Error interface {
+	error
+	Code() ErrorCode
+	// Operation returns the name of the failing operation, e.g. "Encrypt",
+	// it is empty when unknown.
+	Operation() string
+	// Message returns the error message, as reported by the native library.
+	Message() string
+	// Attempts returns the number of times the operation has been attempted,
+	// it is greater than 1 when the operation has been retried, see RetryPolicy.
+	Attempts() int
+}
+
+// IsRetryable reports whether err, or one of the errors it wraps, is a
+// Tanker error with a retryable code. See ErrorCode.Retryable().
+func IsRetryable(err error) bool {
+	var terror Error
+	return errors.As(err, &terror) && terror.Code().Retryable()
+}
+
+// NewError creates a Tanker error. Tanker functions create their own errors,
+// this is meant for fakes, such as the ones of the coremock package, which
+// need to return realistic errors.
+func NewError(code ErrorCode, operation string, message string) Error {
+	return &tankerError{code: code, operation: operation, message: message}
+}
+
+type tankerError struct {
+	code      ErrorCode
+	operation string
+	message   string
+	attempts  int
+	cause     error
+}
+
+// NewCanceledError creates an ErrorOperationCanceled error wrapping the
+// error of the context which caused the cancellation.
+func NewCanceledError(operation string, cause error) error {
+	return &tankerError{
+		code:      ErrorOperationCanceled,
+		operation: operation,
+		message:   "operation canceled: " + cause.Error(),
+		cause:     cause,
+	}
+}
+
+// WithOperation sets the operation name of err if it is a Tanker error
+// created by this package which does not have one yet.
+func WithOperation(err error, operation string) error {
+	terror, ok := err.(*tankerError)
+	if !ok || terror.operation != "" {
+		return err
+	}
+	named := *terror
+	named.operation = operation
+	return &named
+}
+
+// WithAttempts records the number of attempts in err if it is a Tanker error
+// created by this package.
+func WithAttempts(err error, attempts int) error {
+	terror, ok := err.(*tankerError)
+	if !ok || attempts < 2 {
+		return err
+	}
+	retried := *terror
+	retried.attempts = attempts
+	return &retried
+}
+
+func (e tankerError) Error() string {
+	message := e.message
+	if e.operation != "" {
+		message = e.operation + ": " + message
+	}
+	if e.attempts > 1 {
+		message += fmt.Sprintf(" (after %d attempts)", e.attempts)
+	}
+	return message
+}
+
+func (e tankerError) Code() ErrorCode {
+	return e.code
+}
+
+func (e tankerError) Operation() string {
+	return e.operation
+}
+
+func (e tankerError) Message() string {
+	return e.message
+}
+
+func (e tankerError) Attempts() int {
+	if e.attempts < 1 {
+		return 1
+	}
+	return e.attempts
+}
+
+// Unwrap returns the error which caused this one, if any.
+// For ErrorOperationCanceled errors, this is the context error.
+func (e tankerError) Unwrap() error {
+	return e.cause
+}
+
+// Is makes errors.Is() match the sentinel error of the code.
+func (e tankerError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && code == e.code
+}
+
+// GroupTooBigError is the Error returned with ErrorGroupTooBig by the group
+// functions, it carries the size which exceeded the limit:
+//
+//	var tooBig *core.GroupTooBigError
+//	if errors.As(err, &tooBig) {
+//		log.Printf("%d members are too many for a single request", tooBig.Size)
+//	}
+type GroupTooBigError struct {
+	tankerError
+	// Size is the number of members of the request: the members of the group
+	// for CreateGroup(), the members to add or to remove for the updates.
+	Size int
+	// MaxSize is the maximum number of members of a request, zero when it is
+	// unknown: the native library does not report it, only the fakes set it.
+	MaxSize int
+}
+
+// NewGroupTooBigError creates an ErrorGroupTooBig error. Like NewError, it is
+// meant for fakes.
+func NewGroupTooBigError(operation string, message string, size int, maxSize int) *GroupTooBigError {
+	return &GroupTooBigError{
+		tankerError: tankerError{code: ErrorGroupTooBig, operation: operation, message: message},
+		Size:        size,
+		MaxSize:     maxSize,
+	}
+}
+
+// WithGroupSize turns err into a GroupTooBigError of the given size if it is
+// an ErrorGroupTooBig Tanker error created by this package.
+func WithGroupSize(err error, size int) error {
+	terror, ok := err.(*tankerError)
+	if !ok || terror.code != ErrorGroupTooBig {
+		return err
+	}
+	return &GroupTooBigError{tankerError: *terror, Size: size}
+}
@@ -0,0 +1,46 @@
+package coretypes
+
+import "time"
+
+// OperationEvent describes a Tanker operation to an Observer.
+type OperationEvent struct {
+	// Operation is the name of the method, e.g. "Encrypt" for Encrypt() and
+	// EncryptContext()
+	Operation string
+	// Started is the time the operation started
+	Started time.Time
+	// Recipients is the number of users and groups the operation shares with,
+	// or the number of members a group operation adds and removes
+	Recipients int
+	// InputBytes is the size of the data to encrypt or decrypt
+	InputBytes int64
+
+	// The following fields are only set by OperationEnded
+
+	// Duration is the time the operation took, including the retries. For the
+	// streams, it lasts until the stream is closed
+	Duration time.Duration
+	// OutputBytes is the size of the encrypted or clear data produced
+	OutputBytes int64
+	// Err is the error of the operation, nil if it succeeded
+	Err error
+	// ErrorCode is the code of Err, zero if Err is nil or not a Tanker error
+	ErrorCode ErrorCode
+}
+
+// Observer receives the start and end events of the operations of a Tanker
+// instance, to collect metrics or traces. See TankerOptions.Observer.
+//
+// The observed operations are Start, Stop, Encrypt, EncryptTo, Decrypt, DecryptTo,
+// DecryptSecret, Share, CreateGroup, UpdateGroupMembers, EditGroupMembers,
+// GetDeviceList, RevokeDevice, CreateEncryptionSession, StreamEncrypt, StreamDecrypt
+// and the verification operations. The encryptions of the encryption sessions are
+// named "EncryptionSession.Encrypt" and "EncryptionSession.StreamEncrypt". Streams
+// end when they are closed, with the sizes of the data read and produced.
+//
+// The methods are called synchronously by the operations, possibly concurrently,
+// and must not block.
+type Observer interface {
+	OperationStarted(event OperationEvent)
+	OperationEnded(event OperationEvent)
+}
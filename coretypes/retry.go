@@ -0,0 +1,33 @@
+package coretypes
+
+import "time"
+
+// RetryPolicy configures the automatic retry of the idempotent operations:
+// Share, GetDeviceList, UpdateGroupMembers and GetVerificationMethods.
+// When one of them fails with a retryable error, it is attempted again
+// after an exponential backoff with jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Values lower than 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, it doubles after
+	// each attempt. The actual delay is randomly chosen between half and the
+	// whole of it. It must not be negative.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts, zero means no cap.
+	// It must not be negative.
+	MaxBackoff time.Duration
+	// RetryableCodes are the error codes which trigger a retry. When nil, the
+	// codes for which ErrorCode.Retryable() is true are retried.
+	RetryableCodes []ErrorCode
+}
+
+// DefaultRetryPolicy returns a policy making up to 4 attempts, starting with
+// a 200ms backoff capped at 5s, and retrying network errors and conflicts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+	}
+}
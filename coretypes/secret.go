@@ -0,0 +1,118 @@
+package coretypes
+
+import "sync"
+
+// SecretBuffer holds a secret, such as a passphrase, a verification key or decrypted
+// data, in memory allocated outside of the Go heap where the platform allows it: it
+// is never copied by the garbage collector, and it is zeroed by Destroy(). Lock() also
+// prevents it from being swapped to disk. Unlike a string, a SecretBuffer can be wiped
+// once it is no longer needed:
+//
+//	passphrase := core.NewSecretBufferFrom(input)
+//	defer passphrase.Destroy()
+//	err := tanker.VerifyIdentity(core.PassphraseVerification{SecretPassphrase: passphrase})
+//
+// The Tanker functions zero the C copies of the secrets they pass to the native library.
+type SecretBuffer struct {
+	mutex sync.Mutex
+	// data is the whole allocation, it is never empty so that it has an address
+	data   []byte
+	size   int
+	locked bool
+}
+
+// NewSecretBuffer creates a zero-filled SecretBuffer of the given size.
+func NewSecretBuffer(size int) *SecretBuffer {
+	if size < 0 {
+		size = 0
+	}
+	return &SecretBuffer{data: allocSecret(size + 1), size: size}
+}
+
+// NewSecretBufferFrom creates a SecretBuffer holding a copy of secret, which is
+// then zeroed.
+func NewSecretBufferFrom(secret []byte) *SecretBuffer {
+	b := NewSecretBuffer(len(secret))
+	copy(b.Bytes(), secret)
+	ZeroBytes(secret)
+	return b
+}
+
+// ZeroBytes overwrites data with zeros.
+func ZeroBytes(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+// Lock locks the memory of the SecretBuffer in RAM, so that it is not swapped to
+// disk. It fails with ErrorInternalError if the limit of locked memory is reached
+// or if the platform does not support it. The memory is unlocked by Destroy().
+func (b *SecretBuffer) Lock() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.data == nil {
+		return NewError(ErrorPreconditionFailed, "Lock", "the secret buffer has been destroyed")
+	}
+	if b.locked {
+		return nil
+	}
+	if err := lockSecret(b.data); err != nil {
+		return NewError(ErrorInternalError, "Lock", "cannot lock the secret buffer in memory: "+err.Error())
+	}
+	b.locked = true
+	return nil
+}
+
+// Bytes returns the content of the SecretBuffer. The slice refers to the memory
+// of the SecretBuffer, it must not be used after Destroy(), nor appended to.
+// It is nil once the SecretBuffer has been destroyed.
+func (b *SecretBuffer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.data == nil {
+		return nil
+	}
+	return b.data[:b.size:b.size]
+}
+
+// Len returns the size of the secret.
+func (b *SecretBuffer) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.size
+}
+
+// String hides the secret, so that it is not logged by mistake.
+func (b *SecretBuffer) String() string {
+	return "[secret]"
+}
+
+// Destroy zeroes and frees the memory of the SecretBuffer, which is no longer
+// usable. Destroying a SecretBuffer more than once has no effect.
+func (b *SecretBuffer) Destroy() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.data == nil {
+		return
+	}
+	ZeroBytes(b.data)
+	if b.locked {
+		_ = unlockSecret(b.data)
+	}
+	freeSecret(b.data)
+	b.data = nil
+	b.size = 0
+}
+
+// Truncate shrinks the secret to size bytes, zeroing the rest, e.g. to the size
+// of the clear data written by DecryptTo(). A larger size has no effect.
+func (b *SecretBuffer) Truncate(size int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.data == nil || size < 0 || size >= b.size {
+		return
+	}
+	ZeroBytes(b.data[size:b.size])
+	b.size = size
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package coretypes
+
+import "errors"
+
+// allocSecret returns size bytes of the Go heap, the platform has no support
+// for the memory of the secrets.
+func allocSecret(size int) []byte {
+	return make([]byte, size)
+}
+
+func freeSecret(data []byte) {}
+
+func lockSecret(data []byte) error {
+	return errors.New("not supported on this platform")
+}
+
+func unlockSecret(data []byte) error {
+	return nil
+}
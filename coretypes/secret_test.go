@@ -0,0 +1,48 @@
+package coretypes_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/TankerHQ/sdk-go/v2/coretypes"
+)
+
+var _ = Describe("SecretBuffer", func() {
+	It("Copies and zeroes the secret", func() {
+		source := []byte("passphrase")
+		secret := coretypes.NewSecretBufferFrom(source)
+		defer secret.Destroy()
+		Expect(source).To(Equal(make([]byte, len("passphrase"))))
+		Expect(secret.Bytes()).To(Equal([]byte("passphrase")))
+		Expect(secret.Len()).To(Equal(len("passphrase")))
+		Expect(fmt.Sprint(secret)).To(Equal("[secret]"))
+	})
+
+	It("Is unusable once destroyed", func() {
+		secret := coretypes.NewSecretBuffer(32)
+		Expect(secret.Bytes()).To(Equal(make([]byte, 32)))
+		if err := secret.Lock(); err != nil {
+			// The limit of locked memory may be reached in constrained environments
+			Expect(errors.Is(err, coretypes.ErrInternalError)).To(BeTrue())
+		}
+		secret.Destroy()
+		secret.Destroy()
+		Expect(secret.Bytes()).To(BeNil())
+		Expect(secret.Len()).To(Equal(0))
+		Expect(errors.Is(secret.Lock(), coretypes.ErrPreconditionFailed)).To(BeTrue())
+	})
+
+	It("Truncates the secret", func() {
+		secret := coretypes.NewSecretBufferFrom([]byte("passphrase"))
+		defer secret.Destroy()
+		data := secret.Bytes()
+		secret.Truncate(4)
+		Expect(secret.Bytes()).To(Equal([]byte("pass")))
+		Expect(data[4:]).To(Equal(make([]byte, len("phrase"))))
+		secret.Truncate(8)
+		Expect(secret.Len()).To(Equal(4))
+	})
+})
@@ -0,0 +1,27 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package coretypes
+
+import "syscall"
+
+// allocSecret returns size bytes of zeroed memory mapped outside of the Go heap.
+func allocSecret(size int) []byte {
+	data, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		panic("coretypes: cannot allocate a secret buffer: " + err.Error())
+	}
+	return data
+}
+
+func freeSecret(data []byte) {
+	_ = syscall.Munmap(data)
+}
+
+func lockSecret(data []byte) error {
+	return syscall.Mlock(data)
+}
+
+func unlockSecret(data []byte) error {
+	return syscall.Munlock(data)
+}
@@ -0,0 +1,46 @@
+package coretypes
+
+import (
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	memCommit     = 0x1000
+	memReserve    = 0x2000
+	memRelease    = 0x8000
+	pageReadWrite = 0x04
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procVirtualAlloc = kernel32.NewProc("VirtualAlloc")
+	procVirtualFree  = kernel32.NewProc("VirtualFree")
+)
+
+// allocSecret returns size bytes of zeroed memory allocated outside of the Go heap.
+func allocSecret(size int) []byte {
+	address, _, err := procVirtualAlloc.Call(0, uintptr(size), memCommit|memReserve, pageReadWrite)
+	if address == 0 {
+		panic("coretypes: cannot allocate a secret buffer: " + err.Error())
+	}
+	var data []byte
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	header.Data = address
+	header.Len = size
+	header.Cap = size
+	return data
+}
+
+func freeSecret(data []byte) {
+	_, _, _ = procVirtualFree.Call(uintptr(unsafe.Pointer(&data[0])), 0, memRelease)
+}
+
+func lockSecret(data []byte) error {
+	return syscall.VirtualLock(uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+}
+
+func unlockSecret(data []byte) error {
+	return syscall.VirtualUnlock(uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+}
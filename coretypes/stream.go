@@ -0,0 +1,44 @@
+package coretypes
+
+import "time"
+
+const (
+	// DefaultStreamChunkSize is the default StreamOptions.ChunkSize, the size of
+	// the clear chunks of the encryption format.
+	DefaultStreamChunkSize = 1024 * 1024
+	// DefaultStreamReadAhead is the default StreamOptions.ReadAhead.
+	DefaultStreamReadAhead = 1024 * 1024
+)
+
+// StreamOptions configures the buffers of the encryption and decryption streams.
+// The zero value uses the defaults.
+type StreamOptions struct {
+	// ChunkSize is the size of the reads an OutputStream makes from the native
+	// library, Read() calls with smaller buffers are served from a buffer of
+	// this size. Zero means DefaultStreamChunkSize.
+	ChunkSize int
+	// ReadAhead is the amount of data read from the source of a stream ahead of
+	// the needs of the native library, so that reading the source overlaps
+	// with encryption. Zero means DefaultStreamReadAhead, a negative value reads
+	// the source only when the native library needs data.
+	ReadAhead int
+	// Progress, if set, is called with the statistics of a stream each time
+	// the native library produces data, and once the end of the stream is
+	// reached. It is called by the goroutine reading the stream, and must not
+	// read from it.
+	Progress func(StreamStats)
+}
+
+// StreamStats are the statistics of an encryption or decryption stream.
+type StreamStats struct {
+	// BytesRead is the number of bytes read from the source of the stream,
+	// including the data read ahead.
+	BytesRead int64
+	// BytesProduced is the number of encrypted or clear bytes produced by the
+	// native library, including the data buffered by the stream.
+	BytesProduced int64
+	// Chunks is the number of reads of the native library which produced data.
+	Chunks int64
+	// Elapsed is the time since the creation of the stream, until it is closed.
+	Elapsed time.Duration
+}
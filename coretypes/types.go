@@ -0,0 +1,111 @@
+// Package coretypes holds the types of the core package which do not depend on
+// the native library. The core package re-exports all of them, applications
+// should use it rather than this package.
+//
+// Unlike core, coretypes builds without cgo, so that the fakes implementing
+// core.Client, such as the ones of the coretest and coremock packages, can be
+// used where the native library is not available.
+package coretypes
+
+// Status represents the Tanker current status.
+type Status uint32
+
+const (
+	StatusStopped Status = iota
+	StatusReady
+	StatusIdentityRegistrationNeeded
+	StatusIdentityVerificationNeeded
+)
+
+// EncryptionOptions contains user and group recipients to share with during an @Encrypt()
+type EncryptionOptions struct {
+	// ShareWithUsers is a list of the public identities to share with
+	ShareWithUsers []string
+	// ShareWithGroups is a list of group IDs to share with
+	ShareWithGroups []string
+	// ShareWithSelf must be true to allow the author to decrypt the resource
+	ShareWithSelf bool
+	// PaddingStep pads the clear data before encryption to hide its exact length,
+	// it defaults to PaddingAuto
+	PaddingStep Padding
+}
+
+// Padding is the padding scheme of the clear data. Besides PaddingAuto and
+// PaddingOff, any value greater than 1 pads the clear data to a multiple of
+// that value:
+//
+//	options := core.NewEncryptionOptions()
+//	options.PaddingStep = 500
+type Padding uint32
+
+const (
+	// PaddingAuto pads the clear data with a scheme chosen by the native library,
+	// which limits the overhead while hiding the exact length
+	PaddingAuto Padding = 0
+	// PaddingOff disables the padding
+	PaddingOff Padding = 1
+)
+
+// NewEncryptionOptions creates EncryptionOptions with default values
+func NewEncryptionOptions() EncryptionOptions {
+	return EncryptionOptions{
+		ShareWithSelf: true,
+	}
+}
+
+// SharingOptions contains user and group recipients to share with with @Share()
+type SharingOptions struct {
+	// ShareWithUsers is a list of the public identities to share with
+	ShareWithUsers []string
+	// ShareWithGroups is a list of group IDs to share with
+	ShareWithGroups []string
+}
+
+// NewSharingOptions creates SharingOptions with default values
+func NewSharingOptions() SharingOptions {
+	return SharingOptions{}
+}
+
+// DeviceDescription contains the id of a device and whether this device has been revoked.
+type DeviceDescription struct {
+	DeviceID  string
+	IsRevoked bool
+}
+
+// TankerOptions defines the options needed to create a new Tanker
+// instance with NewTanker().
+type TankerOptions struct {
+	// The Application ID you want to use.
+	AppID string
+	// An existing filesystem path to store persistent user data.
+	WritablePath string
+	// The url of the Tanker service. Should be left to nil.
+	Url *string
+	// The retry policy of the idempotent operations, nil disables retries.
+	// See DefaultRetryPolicy().
+	Retry *RetryPolicy
+	// The buffer sizes of the encryption and decryption streams.
+	Streams StreamOptions
+	// The Observer of the operations, to collect metrics or traces. See
+	// NewPrometheusObserver() and NewExpvarObserver().
+	Observer Observer
+}
+
+// GroupMembersUpdate lists the members to add to and to remove from a group.
+type GroupMembersUpdate struct {
+	// UsersToAdd is a list of the public identities to add to the group
+	UsersToAdd []string
+	// UsersToRemove is a list of the public identities to remove from the group
+	UsersToRemove []string
+}
+
+// EventHandler defines the function object type used by RegisterEventHandler().
+type EventHandler func()
+
+// EventType represents the type of event one can register and be notified of.
+type EventType uint32
+
+const (
+	EventSessionClosed EventType = 0
+	EventDeviceRevoked EventType = 1
+)
@@ -0,0 +1,69 @@
+package coretypes
+
+// This enumeration represents the different identity verification methods available.
+type VerificationMethodType uint32
+
+const (
+	VerificationMethodEmail VerificationMethodType = iota + 1
+	VerificationMethodPassphrase
+	VerificationMethodVerificationKey
+	VerificationMethodOidcIdToken
+)
+
+// VerificationMethod describes a type of method registered on the Tanker Server by a user.
+// It may contains the email registered if Type is a VerificationMethodEmail.
+type VerificationMethod struct {
+	Type  VerificationMethodType
+	Email *string
+}
+
+// AttachResult is returned by AttachProvisionalIdentity(). It contains a Tanker
+// Status and the verificationMethod the user is required to use to register the identity provided.
+type AttachResult struct {
+	Status Status
+	Method *VerificationMethod
+}
+
+// Verification is the interface of the verifications accepted by RegisterIdentity(),
+// VerifyIdentity(), SetVerificationMethod() and VerifyProvisionalIdentity().
+// It is implemented by EmailVerification, PassphraseVerification, KeyVerification
+// and OidcVerification, both as values and as pointers. It cannot be implemented
+// outside of this package.
+type Verification interface {
+	isVerification()
+}
+
+// An Email Verification. The VerificationCode is provided to the user
+// by email and must be used here with the Email address.
+type EmailVerification struct {
+	Email            string
+	VerificationCode string
+}
+
+// An Password Verification. The verificaiton password registered by the user
+// should be used here.
+type PassphraseVerification struct {
+	Passphrase string
+	// SecretPassphrase, if set, is used instead of Passphrase, so that the
+	// passphrase can be wiped from memory.
+	SecretPassphrase *SecretBuffer
+}
+
+// A KeyVerification. The unlock key generated by the user should be used here.
+type KeyVerification struct {
+	Key string
+	// SecretKey, if set, is used instead of Key, so that the key can be wiped
+	// from memory.
+	SecretKey *SecretBuffer
+}
+
+// A OidcVerification. The Open ID Connect ID token registered
+// by the user should be used here.
+type OidcVerification struct {
+	OidcIdToken string
+}
+
+func (EmailVerification) isVerification()      {}
+func (PassphraseVerification) isVerification() {}
+func (KeyVerification) isVerification()        {}
+func (OidcVerification) isVerification()       {}
@@ -1,6 +1,6 @@
 module github.com/TankerHQ/sdk-go/v2
 
-go 1.12
+go 1.13
 
 require (
 	github.com/TankerHQ/identity-go v0.0.0-20190828093422-8beae1b85772
@@ -16,7 +16,7 @@ func (device Device) Destroy() error {
 }
 
 func (device Device) CreateSession() (*core.Tanker, error) {
-	return core.NewTanker(core.TankerOptions{device.AppID, device.Path, &device.Url})
+	return core.NewTanker(core.TankerOptions{AppID: device.AppID, WritablePath: device.Path, Url: &device.Url})
 }
 
 func (device Device) Start() (*core.Tanker, error) {